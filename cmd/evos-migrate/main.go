@@ -1,7 +1,22 @@
 package main
 
-import "github.com/riskiramdan/evos/databases"
+import (
+	"github.com/riskiramdan/evos/config"
+	"github.com/riskiramdan/evos/databases"
+	"github.com/riskiramdan/evos/internal/logging"
+
+	"github.com/rs/zerolog/log"
+)
 
 func main() {
-	databases.MigrateUp()
+	cfg, err := config.FromFlags()
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to get configuration")
+	}
+	logger, err := logging.New(cfg.Log)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to create the logger")
+	}
+	log.Logger = logger
+	databases.MigrateUp(cfg)
 }
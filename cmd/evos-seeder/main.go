@@ -1,16 +1,27 @@
 package main
 
 import (
-	"log"
-
+	"github.com/riskiramdan/evos/config"
 	"github.com/riskiramdan/evos/databases"
+	"github.com/riskiramdan/evos/internal/logging"
 	"github.com/riskiramdan/evos/seeder"
+
+	"github.com/rs/zerolog/log"
 )
 
 func main() {
-	databases.MigrateUp()
-	err := seeder.SeedUp()
+	cfg, err := config.FromFlags()
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to get configuration")
+	}
+	logger, err := logging.New(cfg.Log)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to create the logger")
+	}
+	log.Logger = logger
+	databases.MigrateUp(cfg)
+	err = seeder.SeedUp(cfg)
 	if err != nil {
-		log.Printf("Error: %v\n", err)
+		log.Error().Err(err).Msg("failed to seed the database")
 	}
 }
@@ -1,76 +1,192 @@
 package main
 
 import (
-	"log"
+	"context"
+	"time"
 
 	"github.com/riskiramdan/evos/config"
 	"github.com/riskiramdan/evos/databases"
+	"github.com/riskiramdan/evos/internal/audit"
+	auditPg "github.com/riskiramdan/evos/internal/audit/postgres"
+	"github.com/riskiramdan/evos/internal/cache"
 	"github.com/riskiramdan/evos/internal/character"
+	characterCached "github.com/riskiramdan/evos/internal/character/cached"
 	characterPg "github.com/riskiramdan/evos/internal/character/postgres"
+	"github.com/riskiramdan/evos/internal/charactertype"
+	characterTypePg "github.com/riskiramdan/evos/internal/charactertype/postgres"
 	"github.com/riskiramdan/evos/internal/data"
 	"github.com/riskiramdan/evos/internal/hosts"
 	internalhttp "github.com/riskiramdan/evos/internal/http"
+	"github.com/riskiramdan/evos/internal/keyring"
+	"github.com/riskiramdan/evos/internal/logging"
+	"github.com/riskiramdan/evos/internal/metrics"
+	"github.com/riskiramdan/evos/internal/permission"
+	permissionPg "github.com/riskiramdan/evos/internal/permission/postgres"
+	"github.com/riskiramdan/evos/internal/session"
+	sessionPg "github.com/riskiramdan/evos/internal/session/postgres"
+	"github.com/riskiramdan/evos/internal/tracing"
 	"github.com/riskiramdan/evos/internal/user"
 	userPg "github.com/riskiramdan/evos/internal/user/postgres"
 	"github.com/riskiramdan/evos/seeder"
 	"github.com/riskiramdan/evos/util"
 
+	"github.com/go-redis/redis/v8"
 	"github.com/jmoiron/sqlx"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
 )
 
 // InternalServices represents all the internal domain services
 type InternalServices struct {
-	userService      user.ServiceInterface
-	characterService character.ServiceInterface
+	userService          user.ServiceInterface
+	characterService     character.ServiceInterface
+	characterTypeService charactertype.ServiceInterface
+	permissionService    permission.ServiceInterface
+	auditService         audit.ServiceInterface
 }
 
-func buildInternalServices(db *sqlx.DB) *InternalServices {
+func buildInternalServices(db *sqlx.DB, redisClient *redis.Client, cfg *config.Config, keyring *keyring.Keyring) *InternalServices {
+	auditPostgresStorage := auditPg.NewPostgresStorage(
+		data.NewPostgresStorage(db, "audit_log", audit.Logs{}),
+	)
+	auditService := audit.NewService(auditPostgresStorage)
+
 	userPostgresStorage := userPg.NewPostgresStorage(
 		data.NewPostgresStorage(db, "users", user.Users{}),
 	)
-	userService := user.NewService(userPostgresStorage)
+	sessionPostgresStorage := sessionPg.NewPostgresStorage(
+		data.NewPostgresStorage(db, "sessions", session.Sessions{}),
+	)
+	userService := user.NewService(
+		userPostgresStorage,
+		sessionPostgresStorage,
+		keyring,
+		cfg.JWT.AccessTokenTTL,
+		cfg.JWT.RefreshTokenTTL,
+		auditService,
+	)
+
+	characterCache := cache.New(redisClient, "evos:character")
+	characterTypePostgresStorage := characterCached.NewCharacterTypeStorage(
+		characterTypePg.NewPostgresStorage(
+			data.NewPostgresStorage(db, "charactersType", charactertype.CharacterTypes{}),
+		),
+		characterCache,
+	)
+	characterTypeService := charactertype.NewService(characterTypePostgresStorage, auditService)
 
-	characterPostgresStorage := characterPg.NewPostgresStorage(
-		data.NewPostgresStorage(db, "characters", character.Characters{}),
+	characterPostgresStorage := characterCached.NewStorage(
+		characterPg.NewPostgresStorage(
+			data.NewPostgresStorage(db, "characters", character.Characters{}),
+		),
+		characterCache,
+		cfg.Cache.TTL,
+		cfg.Cache.ListTTL,
 	)
-	characterService := character.NewService(characterPostgresStorage)
+	characterService := character.NewService(characterPostgresStorage, characterTypePostgresStorage, auditService)
+	permissionPostgresStorage := permissionPg.NewPostgresStorage(
+		data.NewPostgresStorage(db, "permissions", permission.Permissions{}),
+	)
+	permissionService := permission.NewService(permissionPostgresStorage, cfg.Cache.TTL)
 	return &InternalServices{
-		userService:      userService,
-		characterService: characterService,
+		userService:          userService,
+		characterService:     characterService,
+		characterTypeService: characterTypeService,
+		permissionService:    permissionService,
+		auditService:         auditService,
 	}
 }
 
 func main() {
 
-	config, err := config.GetConfiguration()
+	config, err := config.FromFlags()
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to get configuration")
+	}
+	logger, err := logging.New(config.Log)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to create the logger")
+	}
+	log.Logger = logger
+
+	shutdownTracing, err := tracing.New(context.Background(), config.Tracing)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to set the tracing up")
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			log.Error().Err(err).Msg("failed to flush the spans")
+		}
+	}()
+	db, err := sqlx.Open("postgres", config.DB.ConnectionString())
 	if err != nil {
-		log.Fatalln("failed to get configuration: ", err)
+		log.Fatal().Err(err).Msg("failed to open database x")
 	}
-	db, err := sqlx.Open("postgres", config.DBConnectionString)
+
+	keyring, err := keyring.NewFromConfig(config.JWT)
 	if err != nil {
-		log.Fatalln("failed to open database x: ", err)
+		log.Fatal().Err(err).Msg("failed to load jwt keys")
+	}
+
+	// the cache is disabled without redis, and reads through while redis is down
+	var redisClient *redis.Client
+	if config.Redis.Addr != "" {
+		redisClient = redis.NewClient(&redis.Options{
+			Addr:         config.Redis.Addr,
+			Password:     config.Redis.Password,
+			DB:           config.Redis.DB,
+			DialTimeout:  time.Second,
+			ReadTimeout:  500 * time.Millisecond,
+			WriteTimeout: 500 * time.Millisecond,
+		})
+		defer redisClient.Close()
 	}
 
 	util := &util.Utility{}
-	httpManager := &hosts.HTTPManager{}
+	httpManager, err := hosts.NewHTTPManager(config.HTTPClient)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to create the http client")
+	}
 	defer db.Close()
+	prometheus.MustRegister(metrics.NewDBStatsCollector(config.DB.Name, db))
 	dataManager := data.NewManager(db)
-	internalServices := buildInternalServices(db)
+	internalServices := buildInternalServices(db, redisClient, config, keyring)
 	// Migrate the db
-	databases.MigrateUp()
+	databases.MigrateUp(config)
 	// Seeder
-	err = seeder.SeedUp()
+	err = seeder.SeedUp(config)
 	if err != nil {
-		log.Printf("Error: %v\n", err)
+		log.Fatal().Err(err).Msg("failed to seed the database")
+	}
+
+	readinessChecks := map[string]internalhttp.Check{
+		"postgres": db.PingContext,
+		"migrations": func(ctx context.Context) error {
+			return databases.CheckSchema(ctx, db.DB)
+		},
+	}
+	if redisClient != nil {
+		readinessChecks["redis"] = func(ctx context.Context) error {
+			return redisClient.Ping(ctx).Err()
+		}
 	}
 
 	s := internalhttp.NewServer(
 		internalServices.userService,
 		internalServices.characterService,
+		internalServices.characterTypeService,
+		internalServices.permissionService,
+		internalServices.auditService,
+		keyring,
 		dataManager,
 		config,
 		util,
 		httpManager,
+		redisClient,
+		readinessChecks,
+		logger,
 	)
 	s.Serve()
 }
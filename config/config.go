@@ -2,57 +2,345 @@ package config
 
 import (
 	"fmt"
-	"os"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kelseyhightower/envconfig"
+	validator "gopkg.in/go-playground/validator.v9"
+	yaml "gopkg.in/yaml.v2"
 )
 
+// redacted replaces the secrets in the printed configuration
+const redacted = "******"
+
+// Log formats
 const (
-	dbhost     = "DB_HOST"
-	dbdriver   = "DB_DRIVER"
-	dbuser     = "DB_USER"
-	dbpassword = "DB_PASSWORD"
-	dbname     = "DB_NAME"
-	dbport     = "DB_PORT"
+	LogFormatJSON    = "json"
+	LogFormatConsole = "console"
 )
 
-// Config contains application configuration
+// Error formats
+const (
+	ErrorFormatProblem = "problem"
+	ErrorFormatLegacy  = "legacy"
+)
+
+// Tracing exporters
+const (
+	TracingExporterOTLP   = "otlp"
+	TracingExporterStdout = "stdout"
+	TracingExporterNone   = "none"
+)
+
+// ServerConfig configures the http server
+type ServerConfig struct {
+	// Port is the port the server listens on
+	Port int `yaml:"port" validate:"min=1,max=65535"`
+	// RequestTimeout is how long a request may take before its context is cancelled
+	RequestTimeout time.Duration `yaml:"requestTimeout" split_words:"true" validate:"min=1"`
+	// ShutdownDelay is how long the server keeps serving once the readiness probe
+	// fails on shutdown, so the orchestrator stops routing traffic to it first
+	ShutdownDelay time.Duration `yaml:"shutdownDelay" split_words:"true" validate:"min=0"`
+	// ShutdownTimeout is how long the in-flight requests are waited for on shutdown
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout" split_words:"true" validate:"min=1"`
+	// CORSAllowedOrigins lists the origins allowed to call the API, * allows any
+	CORSAllowedOrigins []string `yaml:"corsAllowedOrigins" split_words:"true" validate:"min=1"`
+	// ErrorFormat is the shape of the error responses: problem (RFC 7807), or legacy
+	// during the migration of the clients, which may still ask for application/problem+json
+	ErrorFormat string `yaml:"errorFormat" split_words:"true" validate:"oneof=problem legacy"`
+}
+
+// LogConfig configures the logs
+type LogConfig struct {
+	// Level is the minimum level logged
+	Level string `yaml:"level" validate:"oneof=debug info warn error"`
+	// Format is json, or console for a human readable output in development
+	Format string `yaml:"format" validate:"oneof=json console"`
+}
+
+// DBConfig configures the postgres database
+type DBConfig struct {
+	Driver   string `yaml:"driver" validate:"required"`
+	Host     string `yaml:"host" validate:"required"`
+	Port     int    `yaml:"port" validate:"min=1,max=65535"`
+	User     string `yaml:"user" validate:"required"`
+	Password string `yaml:"password"`
+	Name     string `yaml:"name" validate:"required"`
+	SSLMode  string `yaml:"sslMode" validate:"oneof=disable allow prefer require verify-ca verify-full"`
+}
+
+// ConnectionString builds the connection string of the database
+func (c DBConfig) ConnectionString() string {
+	u := url.URL{
+		Scheme:   c.Driver,
+		User:     url.UserPassword(c.User, c.Password),
+		Host:     fmt.Sprintf("%s:%d", c.Host, c.Port),
+		Path:     c.Name,
+		RawQuery: "sslmode=" + c.SSLMode,
+	}
+	return u.String()
+}
+
+// RedisConfig configures redis, it is optional
+type RedisConfig struct {
+	// Addr is the host:port of redis, empty disables it
+	Addr     string `yaml:"addr"`
+	Password string `yaml:"password"`
+	DB       int    `yaml:"db" validate:"min=0"`
+}
+
+// CacheConfig configures the cache of the reads
+type CacheConfig struct {
+	// TTL is how long a single cached entity is kept
+	TTL time.Duration `yaml:"ttl" validate:"min=1"`
+	// ListTTL is how long a cached listing page is kept
+	ListTTL time.Duration `yaml:"listTTL" split_words:"true" validate:"min=1"`
+}
+
+// JWTConfig configures the signing keys & the lifetime of the tokens
+type JWTConfig struct {
+	// KeyID is the kid of the signing key
+	KeyID string `yaml:"keyId" split_words:"true" validate:"required"`
+	// SigningKey is a HS256 secret or a PEM encoded RSA / Ed25519 private key,
+	// required unless SigningKeyFile or EphemeralKey is set
+	SigningKey string `yaml:"signingKey" split_words:"true" validate:"required_without_all=SigningKeyFile EphemeralKey"`
+	// SigningKeyFile is the file holding the signing key, it wins over SigningKey
+	SigningKeyFile string `yaml:"signingKeyFile" split_words:"true"`
+	// EphemeralKey signs with a key generated at startup when no signing key is set, for
+	// development only: the tokens don't survive a restart and aren't shared by the replicas
+	EphemeralKey bool `yaml:"ephemeralKey" split_words:"true"`
+	// VerificationKeys lists the retired keys still accepted as comma separated kid=path
+	VerificationKeys string `yaml:"verificationKeys" split_words:"true"`
+	// AccessTokenTTL is the lifetime of the access tokens
+	AccessTokenTTL time.Duration `yaml:"accessTokenTTL" split_words:"true" validate:"min=1"`
+	// RefreshTokenTTL is the lifetime of the refresh tokens, renewed on every refresh
+	RefreshTokenTTL time.Duration `yaml:"refreshTokenTTL" split_words:"true" validate:"gtfield=AccessTokenTTL"`
+}
+
+// RateLimitConfig configures the rate limits of the login & the registration
+type RateLimitConfig struct {
+	// Window is the window the rate limits are counted in
+	Window time.Duration `yaml:"window" validate:"min=1"`
+	// Login is the number of login attempts allowed per IP in the window
+	Login int `yaml:"login" validate:"min=1"`
+	// LoginPhone is the number of login attempts allowed per phone in the window
+	LoginPhone int `yaml:"loginPhone" split_words:"true" validate:"min=1"`
+	// Register is the number of registrations allowed per IP in the window
+	Register int `yaml:"register" validate:"min=1"`
+}
+
+// LockoutConfig configures the lockout of the accounts after failed logins
+type LockoutConfig struct {
+	// Threshold is the number of failed logins locking the account out
+	Threshold int `yaml:"threshold" validate:"min=1"`
+	// Duration is how long the first lockout lasts, it doubles with every further failure
+	Duration time.Duration `yaml:"duration" validate:"min=1"`
+	// MaxDuration caps how long a lockout lasts
+	MaxDuration time.Duration `yaml:"maxDuration" split_words:"true" validate:"gtefield=Duration"`
+}
+
+// HTTPClientConfig configures the client calling the external hosts
+type HTTPClientConfig struct {
+	// Debug logs the requests & the responses
+	Debug bool `yaml:"debug"`
+	// Timeout is how long a request may take, retries included
+	Timeout time.Duration `yaml:"timeout" validate:"min=1"`
+	// Retry is the number of retries of an idempotent request failing or answered with a server error
+	Retry int `yaml:"retry" validate:"min=0"`
+	// RetryBackoff is the base of the exponential backoff between the retries, jittered
+	RetryBackoff time.Duration `yaml:"retryBackoff" split_words:"true" validate:"min=1"`
+	// RetryMaxBackoff caps the backoff between the retries
+	RetryMaxBackoff time.Duration `yaml:"retryMaxBackoff" split_words:"true" validate:"gtefield=RetryBackoff"`
+	// MaxIdleConnsPerHost is the number of idle connections kept open to each host
+	MaxIdleConnsPerHost int `yaml:"maxIdleConnsPerHost" split_words:"true" validate:"min=1"`
+	// CAFile is a PEM file of the certificate authorities trusted on top of the system ones
+	CAFile string `yaml:"caFile" split_words:"true"`
+	// InsecureSkipVerify disables the verification of the certificates, for development only
+	InsecureSkipVerify bool `yaml:"insecureSkipVerify" split_words:"true"`
+	// BreakerThreshold is the number of consecutive failures opening the circuit of a host
+	BreakerThreshold int `yaml:"breakerThreshold" split_words:"true" validate:"min=1"`
+	// BreakerCooldown is how long the circuit of a host stays open before a request is tried again
+	BreakerCooldown time.Duration `yaml:"breakerCooldown" split_words:"true" validate:"min=1"`
+}
+
+// TracingConfig configures the OpenTelemetry tracing
+type TracingConfig struct {
+	// Exporter is otlp, stdout for development, or none to disable the tracing
+	Exporter string `yaml:"exporter" validate:"oneof=otlp stdout none"`
+	// Endpoint is the host:port of the OTLP/HTTP collector
+	Endpoint string `yaml:"endpoint" validate:"required"`
+	// Insecure sends the spans to the collector over plain http
+	Insecure bool `yaml:"insecure"`
+	// ServiceName names the service in the traces
+	ServiceName string `yaml:"serviceName" split_words:"true" validate:"required"`
+	// SampleRatio is the ratio of the traces started by evos that are sampled
+	SampleRatio float64 `yaml:"sampleRatio" split_words:"true" validate:"min=0,max=1"`
+}
+
+// Config contains application configuration.
+// The environment variables are named after the section & the field, e.g. DB_USER:
+// the fields have no envconfig tag, as envconfig falls back to the bare tag, e.g. USER,
+// when the prefixed variable is unset.
 type Config struct {
-	DBConnectionString string
-	RedisAddr          string
-	RedisPassword      string
-	RedisDB            int
-	ImagePath          string
-	AccountKey         string
-	SecretKey          string
-	CloudName          string
+	Server     ServerConfig     `yaml:"server" envconfig:"SERVER"`
+	Log        LogConfig        `yaml:"log" envconfig:"LOG"`
+	DB         DBConfig         `yaml:"db" envconfig:"DB"`
+	Redis      RedisConfig      `yaml:"redis" envconfig:"REDIS"`
+	Cache      CacheConfig      `yaml:"cache" envconfig:"CACHE"`
+	JWT        JWTConfig        `yaml:"jwt" envconfig:"JWT"`
+	RateLimit  RateLimitConfig  `yaml:"rateLimit" envconfig:"RATE_LIMIT"`
+	Lockout    LockoutConfig    `yaml:"lockout" envconfig:"LOCKOUT"`
+	HTTPClient HTTPClientConfig `yaml:"httpClient" envconfig:"HTTP_CLIENT"`
+	Tracing    TracingConfig    `yaml:"tracing" envconfig:"TRACING"`
 }
 
-var config *Config
+// Default returns the default configuration
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Port:               8083,
+			RequestTimeout:     60 * time.Second,
+			ShutdownDelay:      5 * time.Second,
+			ShutdownTimeout:    10 * time.Second,
+			CORSAllowedOrigins: []string{"*"},
+			ErrorFormat:        ErrorFormatProblem,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: LogFormatJSON,
+		},
+		DB: DBConfig{
+			Driver:   "postgres",
+			Host:     "127.0.0.1",
+			Port:     5432,
+			User:     "postgres",
+			Password: "qweasd123",
+			Name:     "evosdb",
+			SSLMode:  "disable",
+		},
+		Cache: CacheConfig{
+			TTL:     5 * time.Minute,
+			ListTTL: 30 * time.Second,
+		},
+		JWT: JWTConfig{
+			KeyID:           "default",
+			AccessTokenTTL:  15 * time.Minute,
+			RefreshTokenTTL: 30 * 24 * time.Hour,
+		},
+		RateLimit: RateLimitConfig{
+			Window:     time.Minute,
+			Login:      20,
+			LoginPhone: 5,
+			Register:   5,
+		},
+		Lockout: LockoutConfig{
+			Threshold:   5,
+			Duration:    time.Minute,
+			MaxDuration: time.Hour,
+		},
+		HTTPClient: HTTPClientConfig{
+			Timeout:             60 * time.Second,
+			Retry:               1,
+			RetryBackoff:        100 * time.Millisecond,
+			RetryMaxBackoff:     2 * time.Second,
+			MaxIdleConnsPerHost: 10,
+			BreakerThreshold:    5,
+			BreakerCooldown:     30 * time.Second,
+		},
+		Tracing: TracingConfig{
+			Exporter:    TracingExporterNone,
+			Endpoint:    "localhost:4318",
+			ServiceName: "evos",
+			SampleRatio: 1,
+		},
+	}
+}
+
+// Load loads the configuration: the defaults, overridden by the YAML file
+// at path if any, overridden by the environment. It is validated.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		ext := strings.ToLower(filepath.Ext(path))
+		if ext != ".yaml" && ext != ".yml" {
+			return nil, fmt.Errorf("unsupported configuration file %s, expected a .yaml file", path)
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error when reading the configuration file: %v", err)
+		}
+		err = yaml.UnmarshalStrict(b, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid configuration file %s: %v", path, err)
+		}
+	}
+
+	// the fields have no default tag, so the ones missing from the environment are kept
+	err := envconfig.Process("", cfg)
+	if err != nil {
+		return nil, err
+	}
 
-func getEnvOrDefault(env string, defaultVal string) string {
-	e := os.Getenv(env)
-	if e == "" {
-		return defaultVal
+	err = cfg.Validate()
+	if err != nil {
+		return nil, err
 	}
-	return e
+	return cfg, nil
 }
 
-// GetConfiguration , get application configuration based on set environment
-func GetConfiguration() (*Config, error) {
-	if config != nil {
-		return config, nil
+// Validate checks the configuration, listing every invalid field
+func (c *Config) Validate() error {
+	err := validator.New().Struct(c)
+	if err == nil {
+		return nil
 	}
+	errs, ok := err.(validator.ValidationErrors)
+	if !ok {
+		return err
+	}
+
+	fields := []string{}
+	for _, e := range errs {
+		fields = append(fields, fmt.Sprintf("%s (%v) must be %s", strings.TrimPrefix(e.Namespace(), "Config."), e.Value(), rule(e)))
+	}
+	return fmt.Errorf("invalid configuration: %s", strings.Join(fields, ", "))
+}
+
+func rule(e validator.FieldError) string {
+	if e.Tag() == "required_without_all" {
+		return "set without " + strings.Join(strings.Fields(e.Param()), " or ")
+	}
+	if e.Param() == "" {
+		return e.Tag()
+	}
+	return e.Tag() + " " + e.Param()
+}
 
-	dbDriver := getEnvOrDefault(dbdriver, "postgres")
-	dbUser := getEnvOrDefault(dbuser, "postgres")
-	dbPassword := getEnvOrDefault(dbpassword, "qweasd123")
-	dbHost := getEnvOrDefault(dbhost, "127.0.0.1")
-	dbPort := getEnvOrDefault(dbport, "5432")
-	dbName := getEnvOrDefault(dbname, "evosdb")
+// Redacted returns a copy of the configuration without its secrets, to be printed
+func (c *Config) Redacted() *Config {
+	r := *c
+	r.Server.CORSAllowedOrigins = append([]string{}, c.Server.CORSAllowedOrigins...)
+	if r.DB.Password != "" {
+		r.DB.Password = redacted
+	}
+	if r.Redis.Password != "" {
+		r.Redis.Password = redacted
+	}
+	if r.JWT.SigningKey != "" {
+		r.JWT.SigningKey = redacted
+	}
+	return &r
+}
 
-	conStr := fmt.Sprintf("%s://%s:%s@%s:%s/%s?sslmode=disable", dbDriver, dbUser, dbPassword, dbHost, dbPort, dbName)
-	// default configuration
-	config := &Config{
-		DBConnectionString: conStr,
+// YAML formats the configuration as YAML, in the format of the configuration file
+func (c *Config) YAML() (string, error) {
+	b, err := yaml.Marshal(c)
+	if err != nil {
+		return "", err
 	}
-	return config, nil
+	return string(b), nil
 }
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func setenv(t *testing.T, key string, value string) {
+	t.Helper()
+	previous, ok := os.LookupEnv(key)
+	os.Setenv(key, value)
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(key, previous)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+// The bare variables a shell or a container commonly sets must not override the configuration
+func TestLoadIgnoresUnprefixedEnv(t *testing.T) {
+	for key, value := range map[string]string{
+		"USER":     "root",
+		"PORT":     "1234",
+		"HOST":     "example.com",
+		"NAME":     "other",
+		"PASSWORD": "secret",
+		"ADDR":     "example.com:6379",
+		"DB":       "3",
+		"TTL":      "1s",
+		"DEBUG":    "true",
+	} {
+		setenv(t, key, value)
+	}
+	setenv(t, "JWT_SIGNING_KEY", "secret")
+
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Default()
+	want.JWT.SigningKey = "secret"
+	if !reflect.DeepEqual(cfg, want) {
+		t.Fatalf("got %+v, want the defaults %+v", cfg, want)
+	}
+}
+
+func TestLoadPrefixedEnv(t *testing.T) {
+	setenv(t, "JWT_SIGNING_KEY", "secret")
+	setenv(t, "DB_USER", "evos")
+	setenv(t, "DB_PORT", "6543")
+	setenv(t, "DB_SSLMODE", "require")
+	setenv(t, "SERVER_PORT", "9000")
+	setenv(t, "REDIS_ADDR", "redis:6379")
+	setenv(t, "CACHE_LIST_TTL", "1m")
+	setenv(t, "JWT_KEY_ID", "2024")
+	setenv(t, "HTTP_CLIENT_MAX_IDLE_CONNS_PER_HOST", "20")
+	setenv(t, "SERVER_CORS_ALLOWED_ORIGINS", "https://a.example,https://b.example")
+
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DB.User != "evos" || cfg.DB.Port != 6543 || cfg.DB.SSLMode != "require" {
+		t.Errorf("got db %+v", cfg.DB)
+	}
+	if cfg.Server.Port != 9000 || len(cfg.Server.CORSAllowedOrigins) != 2 {
+		t.Errorf("got server %+v", cfg.Server)
+	}
+	if cfg.Redis.Addr != "redis:6379" {
+		t.Errorf("got redis addr %s", cfg.Redis.Addr)
+	}
+	if cfg.Cache.ListTTL != time.Minute {
+		t.Errorf("got cache list ttl %s", cfg.Cache.ListTTL)
+	}
+	if cfg.JWT.KeyID != "2024" {
+		t.Errorf("got jwt key id %s", cfg.JWT.KeyID)
+	}
+	if cfg.HTTPClient.MaxIdleConnsPerHost != 20 {
+		t.Errorf("got max idle conns per host %d", cfg.HTTPClient.MaxIdleConnsPerHost)
+	}
+}
+
+func TestLoadWithoutSigningKey(t *testing.T) {
+	_, err := Load("")
+	if err == nil || !strings.Contains(err.Error(), "JWT.SigningKey") {
+		t.Fatalf("got %v, want the missing signing key", err)
+	}
+}
+
+func TestLoadEphemeralKey(t *testing.T) {
+	setenv(t, "JWT_EPHEMERAL_KEY", "true")
+
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.JWT.EphemeralKey {
+		t.Error("the ephemeral key is not allowed")
+	}
+}
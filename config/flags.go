@@ -0,0 +1,31 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// FromFlags loads the configuration of a command: --config names the YAML file,
+// CONFIG_FILE by default. With --print-config, the configuration is printed
+// without its secrets and the command exits.
+func FromFlags() (*Config, error) {
+	path := flag.String("config", os.Getenv("CONFIG_FILE"), "path of the YAML configuration file")
+	print := flag.Bool("print-config", false, "print the configuration, without its secrets, and exit")
+	flag.Parse()
+
+	cfg, err := Load(*path)
+	if err != nil {
+		return nil, err
+	}
+
+	if *print {
+		out, err := cfg.Redacted().YAML()
+		if err != nil {
+			return nil, err
+		}
+		fmt.Print(out)
+		os.Exit(0)
+	}
+	return cfg, nil
+}
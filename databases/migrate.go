@@ -2,43 +2,37 @@ package databases
 
 import (
 	"database/sql"
-	"fmt"
-	"log"
 
 	"github.com/riskiramdan/evos/config"
 
 	rice "github.com/GeertJohan/go.rice"
 	"github.com/golang-migrate/migrate"
 	"github.com/golang-migrate/migrate/database/postgres"
+	"github.com/rs/zerolog/log"
 )
 
 // MigrateUp migrates the database up
-func MigrateUp() {
+func MigrateUp(cfg *config.Config) {
 	// Setup the database
 	//
-	cfg, err := config.GetConfiguration()
+	db, err := sql.Open("postgres", cfg.DB.ConnectionString())
 	if err != nil {
-		log.Fatal("error when getting configuration: ", err)
-	}
-
-	db, err := sql.Open("postgres", cfg.DBConnectionString)
-	if err != nil {
-		log.Fatal("error when open postgres connection: ", err)
+		log.Fatal().Err(err).Msg("error when open postgres connection")
 	}
 
 	// Setup the source driver
 	//
 	sourceDriver := &RiceBoxSource{}
-	sourceDriver.PopulateMigrations(rice.MustFindBox("./migrations"))
+	err = sourceDriver.PopulateMigrations(rice.MustFindBox("./migrations"))
 	if err != nil {
-		log.Fatal("error when creating source driver: ", err)
+		log.Fatal().Err(err).Msg("error when creating source driver")
 	}
 
 	// Setup the database driver
 	//
 	driver, err := postgres.WithInstance(db, &postgres.Config{})
 	if err != nil {
-		log.Fatal("error when creating postgres instance: ", err)
+		log.Fatal().Err(err).Msg("error when creating postgres instance")
 	}
 
 	m, err := migrate.NewWithInstance(
@@ -46,15 +40,15 @@ func MigrateUp() {
 		"postgres", driver)
 
 	if err != nil {
-		log.Fatal("error when creating database instance: ", err)
+		log.Fatal().Err(err).Msg("error when creating database instance")
 	}
 
 	if err := m.Up(); err != nil {
 		if err.Error() != "no change" {
-			log.Fatal("error when migrate up: ", err)
+			log.Fatal().Err(err).Msg("error when migrate up")
 		}
 	}
-	fmt.Println("success migrate databases")
+	log.Info().Msg("success migrate databases")
 
 	defer m.Close()
 }
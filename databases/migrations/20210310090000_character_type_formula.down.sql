@@ -0,0 +1,3 @@
+ALTER TABLE "charactersType" DROP COLUMN IF EXISTS "thresholds";
+ALTER TABLE "charactersType" DROP COLUMN IF EXISTS "flatBonus";
+ALTER TABLE "charactersType" DROP COLUMN IF EXISTS "multiplier";
@@ -0,0 +1,8 @@
+-- Character type value formula ---------------------------------
+ALTER TABLE "charactersType" ADD COLUMN "multiplier" int NOT NULL DEFAULT 100;
+ALTER TABLE "charactersType" ADD COLUMN "flatBonus" int NOT NULL DEFAULT 0;
+ALTER TABLE "charactersType" ADD COLUMN "thresholds" jsonb NOT NULL DEFAULT '[]';
+
+UPDATE "charactersType" SET "multiplier" = 150 WHERE "id" = 1;
+UPDATE "charactersType" SET "multiplier" = 110, "flatBonus" = 2 WHERE "id" = 2;
+UPDATE "charactersType" SET "multiplier" = 300, "thresholds" = '[{"powerBelow": 20, "multiplier": 200}]' WHERE "id" = 3;
@@ -0,0 +1,2 @@
+drop table if exists "rolePermissions";
+drop table if exists "permissions";
@@ -0,0 +1,24 @@
+-- Table Definition ----------------------------------------------
+CREATE TABLE "permissions" (
+  "id" SERIAL PRIMARY KEY NOT NULL,
+  "name" varchar(80) NOT NULL UNIQUE,
+  "description" varchar(255),
+  "createdAt" timestamp NOT NULL DEFAULT (now()),
+  "createdBy" varchar(20) DEFAULT 'admin',
+  "updatedAt" timestamp NOT NULL DEFAULT (now()),
+  "updatedBy" varchar(20) DEFAULT 'admin',
+  "deletedAt" timestamp,
+  "deletedBy" varchar(20)
+);
+
+CREATE TABLE "rolePermissions" (
+  "id" SERIAL PRIMARY KEY NOT NULL,
+  "roleId" int NOT NULL,
+  "permissionId" int NOT NULL,
+  "createdAt" timestamp NOT NULL DEFAULT (now()),
+  "createdBy" varchar(20) DEFAULT 'admin',
+  UNIQUE ("roleId", "permissionId")
+);
+
+ALTER TABLE "rolePermissions" ADD FOREIGN KEY ("roleId") REFERENCES "roles" ("id");
+ALTER TABLE "rolePermissions" ADD FOREIGN KEY ("permissionId") REFERENCES "permissions" ("id");
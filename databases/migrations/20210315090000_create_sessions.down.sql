@@ -0,0 +1,4 @@
+ALTER TABLE "users" ADD COLUMN "token" varchar;
+ALTER TABLE "users" ADD COLUMN "tokenExpiredAt" timestamp;
+
+drop table if exists "sessions";
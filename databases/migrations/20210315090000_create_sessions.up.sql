@@ -0,0 +1,22 @@
+-- Table Definition ----------------------------------------------
+CREATE TABLE "sessions" (
+  "id" SERIAL PRIMARY KEY NOT NULL,
+  "userId" int NOT NULL,
+  "refreshTokenHash" varchar(64) NOT NULL UNIQUE,
+  "previousRefreshTokenHash" varchar(64),
+  "userAgent" varchar(255),
+  "ipAddress" varchar(64),
+  "expiredAt" timestamp NOT NULL,
+  "lastUsedAt" timestamp,
+  "revokedAt" timestamp,
+  "createdAt" timestamp NOT NULL DEFAULT (now()),
+  "updatedAt" timestamp NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX "sessions_userId_idx" ON "sessions" ("userId");
+CREATE INDEX "sessions_previousRefreshTokenHash_idx" ON "sessions" ("previousRefreshTokenHash");
+
+ALTER TABLE "sessions" ADD FOREIGN KEY ("userId") REFERENCES "users" ("id");
+
+ALTER TABLE "users" DROP COLUMN IF EXISTS "token";
+ALTER TABLE "users" DROP COLUMN IF EXISTS "tokenExpiredAt";
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS "characters_createdAt_id_idx";
+DROP INDEX IF EXISTS "users_createdAt_id_idx";
@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS "characters_createdAt_id_idx" ON "characters" ("createdAt" DESC, "id" DESC);
+CREATE INDEX IF NOT EXISTS "users_createdAt_id_idx" ON "users" ("createdAt" DESC, "id" DESC);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS "characters_name_unique_idx";
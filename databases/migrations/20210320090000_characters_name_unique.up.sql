@@ -0,0 +1 @@
+CREATE UNIQUE INDEX IF NOT EXISTS "characters_name_unique_idx" ON "characters" (LOWER("name")) WHERE "deletedAt" IS NULL;
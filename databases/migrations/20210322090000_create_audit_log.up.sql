@@ -0,0 +1,14 @@
+-- Table Definition ----------------------------------------------
+CREATE TABLE IF NOT EXISTS "audit_log" (
+  "id" SERIAL PRIMARY KEY NOT NULL,
+  "entity" varchar(40) NOT NULL,
+  "entityId" int NOT NULL,
+  "action" varchar(20) NOT NULL,
+  "userId" int,
+  "diff" jsonb NOT NULL DEFAULT '{}',
+  "requestId" varchar(100),
+  "createdAt" timestamp NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX IF NOT EXISTS "audit_log_entity_idx" ON "audit_log" ("entity", "entityId", "createdAt" DESC, "id" DESC);
+CREATE INDEX IF NOT EXISTS "audit_log_createdAt_idx" ON "audit_log" ("createdAt" DESC, "id" DESC);
@@ -0,0 +1,2 @@
+ALTER TABLE "characters" ADD COLUMN IF NOT EXISTS "version" int NOT NULL DEFAULT 1;
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "version" int NOT NULL DEFAULT 1;
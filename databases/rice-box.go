@@ -22,14 +22,112 @@ func init() {
 
 		Content: string("-- Table Definition ----------------------------------------------\nCREATE TABLE \"users\" (\n  \"id\" SERIAL PRIMARY KEY NOT NULL,\n  \"roleId\" int NOT NULL,\n  \"name\" varchar(80) NOT NULL,\n  \"phone\" varchar(80) NOT NULL,\n  \"password\" varchar NOT NULL,\n  \"token\" varchar,\n  \"tokenExpiredAt\" timestamp,\n  \"createdAt\" timestamp NOT NULL DEFAULT (now()),\n  \"createdBy\" varchar(20) DEFAULT 'admin',\n  \"updatedAt\" timestamp NOT NULL DEFAULT (now()),\n  \"updatedBy\" varchar(20) DEFAULT 'admin',\n  \"deletedAt\" timestamp,\n  \"deletedBy\" varchar(20)\n);\n\nCREATE TABLE \"roles\" (\n  \"id\" SERIAL PRIMARY KEY NOT NULL,\n  \"name\" varchar(80) NOT NULL,\n  \"createdAt\" timestamp NOT NULL DEFAULT (now()),\n  \"createdBy\" varchar(20) DEFAULT 'admin',\n  \"updatedAt\" timestamp NOT NULL DEFAULT (now()),\n  \"updatedBy\" varchar(20) DEFAULT 'admin',\n  \"deletedAt\" timestamp,\n  \"deletedBy\" varchar(20)\n);\n\nCREATE TABLE \"characters\" (\n  \"id\" SERIAL PRIMARY KEY NOT NULL,\n  \"characterTypeID\" int NOT NULL,\n  \"name\" varchar(80) NOT NULL,\n  \"power\" int NOT NULL,\n  \"createdAt\" timestamp NOT NULL DEFAULT (now()),\n  \"createdBy\" varchar(20) DEFAULT 'admin',\n  \"updatedAt\" timestamp NOT NULL DEFAULT (now()),\n  \"updatedBy\" varchar(20) DEFAULT 'admin',\n  \"deletedAt\" timestamp,\n  \"deletedBy\" varchar(20)\n);\n\nCREATE TABLE \"charactersType\" (\n  \"id\" SERIAL PRIMARY KEY NOT NULL,\n  \"name\" varchar(80) NOT NULL,\n  \"code\" int NOT NULL,\n  \"createdAt\" timestamp NOT NULL DEFAULT (now()),\n  \"createdBy\" varchar(20) DEFAULT 'admin',\n  \"updatedAt\" timestamp NOT NULL DEFAULT (now()),\n  \"updatedBy\" varchar(20) DEFAULT 'admin',\n  \"deletedAt\" timestamp,\n  \"deletedBy\" varchar(20)\n);\n\nALTER TABLE \"users\" ADD FOREIGN KEY (\"roleId\") REFERENCES \"roles\" (\"id\");\nALTER TABLE \"characters\" ADD FOREIGN KEY (\"characterTypeID\") REFERENCES \"charactersType\" (\"id\");\n"),
 	}
+	file4 := &embedded.EmbeddedFile{
+		Filename:    "20210310090000_character_type_formula.down.sql",
+		FileModTime: time.Unix(1615366800, 0),
+
+		Content: string("ALTER TABLE \"charactersType\" DROP COLUMN IF EXISTS \"thresholds\";\nALTER TABLE \"charactersType\" DROP COLUMN IF EXISTS \"flatBonus\";\nALTER TABLE \"charactersType\" DROP COLUMN IF EXISTS \"multiplier\";\n"),
+	}
+	file5 := &embedded.EmbeddedFile{
+		Filename:    "20210310090000_character_type_formula.up.sql",
+		FileModTime: time.Unix(1615366800, 0),
+
+		Content: string("-- Character type value formula ---------------------------------\nALTER TABLE \"charactersType\" ADD COLUMN \"multiplier\" int NOT NULL DEFAULT 100;\nALTER TABLE \"charactersType\" ADD COLUMN \"flatBonus\" int NOT NULL DEFAULT 0;\nALTER TABLE \"charactersType\" ADD COLUMN \"thresholds\" jsonb NOT NULL DEFAULT '[]';\n\nUPDATE \"charactersType\" SET \"multiplier\" = 150 WHERE \"id\" = 1;\nUPDATE \"charactersType\" SET \"multiplier\" = 110, \"flatBonus\" = 2 WHERE \"id\" = 2;\nUPDATE \"charactersType\" SET \"multiplier\" = 300, \"thresholds\" = '[{\"powerBelow\": 20, \"multiplier\": 200}]' WHERE \"id\" = 3;\n"),
+	}
+	file6 := &embedded.EmbeddedFile{
+		Filename:    "20210312090000_create_permissions.down.sql",
+		FileModTime: time.Unix(1615539600, 0),
+
+		Content: string("drop table if exists \"rolePermissions\";\ndrop table if exists \"permissions\";\n"),
+	}
+	file7 := &embedded.EmbeddedFile{
+		Filename:    "20210312090000_create_permissions.up.sql",
+		FileModTime: time.Unix(1615539600, 0),
+
+		Content: string("-- Table Definition ----------------------------------------------\nCREATE TABLE \"permissions\" (\n  \"id\" SERIAL PRIMARY KEY NOT NULL,\n  \"name\" varchar(80) NOT NULL UNIQUE,\n  \"description\" varchar(255),\n  \"createdAt\" timestamp NOT NULL DEFAULT (now()),\n  \"createdBy\" varchar(20) DEFAULT 'admin',\n  \"updatedAt\" timestamp NOT NULL DEFAULT (now()),\n  \"updatedBy\" varchar(20) DEFAULT 'admin',\n  \"deletedAt\" timestamp,\n  \"deletedBy\" varchar(20)\n);\n\nCREATE TABLE \"rolePermissions\" (\n  \"id\" SERIAL PRIMARY KEY NOT NULL,\n  \"roleId\" int NOT NULL,\n  \"permissionId\" int NOT NULL,\n  \"createdAt\" timestamp NOT NULL DEFAULT (now()),\n  \"createdBy\" varchar(20) DEFAULT 'admin',\n  UNIQUE (\"roleId\", \"permissionId\")\n);\n\nALTER TABLE \"rolePermissions\" ADD FOREIGN KEY (\"roleId\") REFERENCES \"roles\" (\"id\");\nALTER TABLE \"rolePermissions\" ADD FOREIGN KEY (\"permissionId\") REFERENCES \"permissions\" (\"id\");\n"),
+	}
+	file8 := &embedded.EmbeddedFile{
+		Filename:    "20210315090000_create_sessions.down.sql",
+		FileModTime: time.Unix(1615798800, 0),
+
+		Content: string("ALTER TABLE \"users\" ADD COLUMN \"token\" varchar;\nALTER TABLE \"users\" ADD COLUMN \"tokenExpiredAt\" timestamp;\n\ndrop table if exists \"sessions\";\n"),
+	}
+	file9 := &embedded.EmbeddedFile{
+		Filename:    "20210315090000_create_sessions.up.sql",
+		FileModTime: time.Unix(1615798800, 0),
+
+		Content: string("-- Table Definition ----------------------------------------------\nCREATE TABLE \"sessions\" (\n  \"id\" SERIAL PRIMARY KEY NOT NULL,\n  \"userId\" int NOT NULL,\n  \"refreshTokenHash\" varchar(64) NOT NULL UNIQUE,\n  \"previousRefreshTokenHash\" varchar(64),\n  \"userAgent\" varchar(255),\n  \"ipAddress\" varchar(64),\n  \"expiredAt\" timestamp NOT NULL,\n  \"lastUsedAt\" timestamp,\n  \"revokedAt\" timestamp,\n  \"createdAt\" timestamp NOT NULL DEFAULT (now()),\n  \"updatedAt\" timestamp NOT NULL DEFAULT (now())\n);\n\nCREATE INDEX \"sessions_userId_idx\" ON \"sessions\" (\"userId\");\nCREATE INDEX \"sessions_previousRefreshTokenHash_idx\" ON \"sessions\" (\"previousRefreshTokenHash\");\n\nALTER TABLE \"sessions\" ADD FOREIGN KEY (\"userId\") REFERENCES \"users\" (\"id\");\n\nALTER TABLE \"users\" DROP COLUMN IF EXISTS \"token\";\nALTER TABLE \"users\" DROP COLUMN IF EXISTS \"tokenExpiredAt\";\n"),
+	}
+	file10 := &embedded.EmbeddedFile{
+		Filename:    "20210318090000_keyset_pagination_indexes.down.sql",
+		FileModTime: time.Unix(1616058000, 0),
+
+		Content: string("DROP INDEX IF EXISTS \"characters_createdAt_id_idx\";\nDROP INDEX IF EXISTS \"users_createdAt_id_idx\";\n"),
+	}
+	file11 := &embedded.EmbeddedFile{
+		Filename:    "20210318090000_keyset_pagination_indexes.up.sql",
+		FileModTime: time.Unix(1616058000, 0),
+
+		Content: string("CREATE INDEX IF NOT EXISTS \"characters_createdAt_id_idx\" ON \"characters\" (\"createdAt\" DESC, \"id\" DESC);\nCREATE INDEX IF NOT EXISTS \"users_createdAt_id_idx\" ON \"users\" (\"createdAt\" DESC, \"id\" DESC);\n"),
+	}
+	file12 := &embedded.EmbeddedFile{
+		Filename:    "20210320090000_characters_name_unique.down.sql",
+		FileModTime: time.Unix(1616230800, 0),
+
+		Content: string("DROP INDEX IF EXISTS \"characters_name_unique_idx\";\n"),
+	}
+	file13 := &embedded.EmbeddedFile{
+		Filename:    "20210320090000_characters_name_unique.up.sql",
+		FileModTime: time.Unix(1616230800, 0),
+
+		Content: string("CREATE UNIQUE INDEX IF NOT EXISTS \"characters_name_unique_idx\" ON \"characters\" (LOWER(\"name\")) WHERE \"deletedAt\" IS NULL;\n"),
+	}
+	file14 := &embedded.EmbeddedFile{
+		Filename:    "20210322090000_create_audit_log.down.sql",
+		FileModTime: time.Unix(1616403600, 0),
+
+		Content: string("DROP TABLE IF EXISTS \"audit_log\";\n"),
+	}
+	file15 := &embedded.EmbeddedFile{
+		Filename:    "20210322090000_create_audit_log.up.sql",
+		FileModTime: time.Unix(1616403600, 0),
+
+		Content: string("-- Table Definition ----------------------------------------------\nCREATE TABLE IF NOT EXISTS \"audit_log\" (\n  \"id\" SERIAL PRIMARY KEY NOT NULL,\n  \"entity\" varchar(40) NOT NULL,\n  \"entityId\" int NOT NULL,\n  \"action\" varchar(20) NOT NULL,\n  \"userId\" int,\n  \"diff\" jsonb NOT NULL DEFAULT '{}',\n  \"requestId\" varchar(100),\n  \"createdAt\" timestamp NOT NULL DEFAULT (now())\n);\n\nCREATE INDEX IF NOT EXISTS \"audit_log_entity_idx\" ON \"audit_log\" (\"entity\", \"entityId\", \"createdAt\" DESC, \"id\" DESC);\nCREATE INDEX IF NOT EXISTS \"audit_log_createdAt_idx\" ON \"audit_log\" (\"createdAt\" DESC, \"id\" DESC);\n"),
+	}
+	file16 := &embedded.EmbeddedFile{
+		Filename:    "20210324090000_optimistic_locking.down.sql",
+		FileModTime: time.Unix(1616576400, 0),
+
+		Content: string("ALTER TABLE \"characters\" DROP COLUMN IF EXISTS \"version\";\nALTER TABLE \"users\" DROP COLUMN IF EXISTS \"version\";\n"),
+	}
+	file17 := &embedded.EmbeddedFile{
+		Filename:    "20210324090000_optimistic_locking.up.sql",
+		FileModTime: time.Unix(1616576400, 0),
+
+		Content: string("ALTER TABLE \"characters\" ADD COLUMN IF NOT EXISTS \"version\" int NOT NULL DEFAULT 1;\nALTER TABLE \"users\" ADD COLUMN IF NOT EXISTS \"version\" int NOT NULL DEFAULT 1;\n"),
+	}
 
 	// define dirs
 	dir1 := &embedded.EmbeddedDir{
 		Filename:   "",
-		DirModTime: time.Unix(1614942987, 0),
+		DirModTime: time.Unix(1616576400, 0),
 		ChildFiles: []*embedded.EmbeddedFile{
-			file2, // "20200205205811_create_table.down.sql"
-			file3, // "20200205205811_create_table.up.sql"
+			file2,  // "20200205205811_create_table.down.sql"
+			file3,  // "20200205205811_create_table.up.sql"
+			file4,  // "20210310090000_character_type_formula.down.sql"
+			file5,  // "20210310090000_character_type_formula.up.sql"
+			file6,  // "20210312090000_create_permissions.down.sql"
+			file7,  // "20210312090000_create_permissions.up.sql"
+			file8,  // "20210315090000_create_sessions.down.sql"
+			file9,  // "20210315090000_create_sessions.up.sql"
+			file10, // "20210318090000_keyset_pagination_indexes.down.sql"
+			file11, // "20210318090000_keyset_pagination_indexes.up.sql"
+			file12, // "20210320090000_characters_name_unique.down.sql"
+			file13, // "20210320090000_characters_name_unique.up.sql"
+			file14, // "20210322090000_create_audit_log.down.sql"
+			file15, // "20210322090000_create_audit_log.up.sql"
+			file16, // "20210324090000_optimistic_locking.down.sql"
+			file17, // "20210324090000_optimistic_locking.up.sql"
 
 		},
 	}
@@ -40,13 +138,27 @@ func init() {
 	// register embeddedBox
 	embedded.RegisterEmbeddedBox(`./migrations`, &embedded.EmbeddedBox{
 		Name: `./migrations`,
-		Time: time.Unix(1614942987, 0),
+		Time: time.Unix(1616576400, 0),
 		Dirs: map[string]*embedded.EmbeddedDir{
 			"": dir1,
 		},
 		Files: map[string]*embedded.EmbeddedFile{
-			"20200205205811_create_table.down.sql": file2,
-			"20200205205811_create_table.up.sql":   file3,
+			"20200205205811_create_table.down.sql":              file2,
+			"20200205205811_create_table.up.sql":                file3,
+			"20210310090000_character_type_formula.down.sql":    file4,
+			"20210310090000_character_type_formula.up.sql":      file5,
+			"20210312090000_create_permissions.down.sql":        file6,
+			"20210312090000_create_permissions.up.sql":          file7,
+			"20210315090000_create_sessions.down.sql":           file8,
+			"20210315090000_create_sessions.up.sql":             file9,
+			"20210318090000_keyset_pagination_indexes.down.sql": file10,
+			"20210318090000_keyset_pagination_indexes.up.sql":   file11,
+			"20210320090000_characters_name_unique.down.sql":    file12,
+			"20210320090000_characters_name_unique.up.sql":      file13,
+			"20210322090000_create_audit_log.down.sql":          file14,
+			"20210322090000_create_audit_log.up.sql":            file15,
+			"20210324090000_optimistic_locking.down.sql":        file16,
+			"20210324090000_optimistic_locking.up.sql":          file17,
 		},
 	})
 }
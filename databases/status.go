@@ -0,0 +1,71 @@
+package databases
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	rice "github.com/GeertJohan/go.rice"
+)
+
+var (
+	// ErrDirtySchema error when a migration failed halfway and the schema must be fixed by hand
+	ErrDirtySchema = errors.New("dirty schema")
+	// ErrSchemaVersion error when the schema is not at the version of the embedded migrations
+	ErrSchemaVersion = errors.New("schema version mismatch")
+)
+
+var (
+	latestOnce    sync.Once
+	latestVersion uint
+	latestErr     error
+)
+
+// LatestVersion returns the version of the last embedded migration
+func LatestVersion() (uint, error) {
+	latestOnce.Do(func() {
+		sourceDriver := &RiceBoxSource{}
+		latestErr = sourceDriver.PopulateMigrations(rice.MustFindBox("./migrations"))
+		if latestErr != nil {
+			return
+		}
+		version, err := sourceDriver.First()
+		for err == nil {
+			latestVersion = version
+			version, err = sourceDriver.Next(version)
+		}
+		if err != os.ErrNotExist {
+			latestErr = err
+		}
+	})
+	return latestVersion, latestErr
+}
+
+// CheckSchema checks that the schema of the database is at the version of the
+// embedded migrations, and that no migration failed halfway
+func CheckSchema(ctx context.Context, db *sql.DB) error {
+	latest, err := LatestVersion()
+	if err != nil {
+		return err
+	}
+
+	var version uint
+	var dirty bool
+	err = db.QueryRowContext(ctx, `SELECT "version", "dirty" FROM "schema_migrations" LIMIT 1`).Scan(&version, &dirty)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("%w: no migration applied, expected %d", ErrSchemaVersion, latest)
+	}
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("%w at version %d", ErrDirtySchema, version)
+	}
+	if version != latest {
+		return fmt.Errorf("%w: at %d, expected %d", ErrSchemaVersion, version, latest)
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package appcontext
 
 import (
 	"context"
+	"strconv"
 )
 
 type contextKey string
@@ -46,14 +47,14 @@ const (
 	// KeyWarehouseProvider represents the Current Client in http server context
 	KeyWarehouseProvider contextKey = "WarehouseProvider"
 
-	// KeyLogString represents the key Log String in server context
-	KeyLogString contextKey = "KeyLogString"
-
-	// KeyAllLog represents the key Log String in server context
-	KeyAllLog contextKey = "KeyAllLog"
-
 	// KeyIsAdmin represents the key Log String in server context
 	KeyIsAdmin contextKey = "Admin"
+
+	// KeyRoleID represents the role of the current logged-in user
+	KeyRoleID contextKey = "RoleID"
+
+	// KeyRequestID represents the id of the current request
+	KeyRequestID contextKey = "RequestID"
 )
 
 // Owner gets the data owner from the context
@@ -116,6 +117,36 @@ func UserID(ctx context.Context) int {
 	return 0
 }
 
+// RoleID gets the role of the current logged-in user from the context
+func RoleID(ctx context.Context) int {
+	roleID := ctx.Value(KeyRoleID)
+	if roleID != nil {
+		v := roleID.(int)
+		return v
+	}
+	return 0
+}
+
+// RequestID gets the id of the current request from the context
+func RequestID(ctx context.Context) string {
+	requestID := ctx.Value(KeyRequestID)
+	if requestID != nil {
+		v := requestID.(string)
+		return v
+	}
+	return ""
+}
+
+// Actor gets the current logged-in user as recorded in the
+// "createdBy", "updatedBy" & "deletedBy" columns
+func Actor(ctx context.Context) string {
+	userID := UserID(ctx)
+	if userID == 0 {
+		return "system"
+	}
+	return strconv.Itoa(userID)
+}
+
 // WarehouseID gets current prefered warehouseID of CustomerID
 func WarehouseID(ctx context.Context) int {
 	warehouseID := ctx.Value(KeyWarehouseID)
@@ -184,26 +215,6 @@ func WarehouseProvider(ctx context.Context) *int {
 	return nil
 }
 
-// LogString gets log String from context
-func LogString(ctx context.Context) *string {
-	logString := ctx.Value(KeyLogString)
-	if logString != nil {
-		v := logString.(*string)
-		return v
-	}
-	return nil
-}
-
-// AllLog gets log String from context
-func AllLog(ctx context.Context) *string {
-	logString := ctx.Value(KeyAllLog)
-	if logString != nil {
-		v := logString.(string)
-		return &v
-	}
-	return nil
-}
-
 // IsAdmin gets admin status from context
 func IsAdmin(ctx context.Context) bool {
 	IsAdmin := ctx.Value(KeyIsAdmin)
@@ -0,0 +1,208 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"github.com/riskiramdan/evos/internal/appcontext"
+	"github.com/riskiramdan/evos/internal/data"
+	"github.com/riskiramdan/evos/internal/types"
+)
+
+// Audited entities
+const (
+	EntityCharacter     = "character"
+	EntityCharacterType = "characterType"
+	EntityUser          = "user"
+)
+
+// Audited actions
+const (
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionDelete  = "delete"
+	ActionRestore = "restore"
+)
+
+// Logs audit log entry, one per change of an entity
+type Logs struct {
+	ID        int            `json:"id" db:"id"`
+	Entity    string         `json:"entity" db:"entity"`
+	EntityID  int            `json:"entityId" db:"entityId"`
+	Action    string         `json:"action" db:"action"`
+	UserID    *int           `json:"userId" db:"userId"`
+	Diff      types.Metadata `json:"diff" db:"diff"`
+	RequestID *string        `json:"requestId" db:"requestId"`
+	CreatedAt time.Time      `json:"createdAt" db:"createdAt"`
+}
+
+// Change is a change of an entity to record, Before is nil on creation and After on deletion
+type Change struct {
+	Entity   string
+	EntityID int
+	Action   string
+	Before   interface{}
+	After    interface{}
+}
+
+// FindAllLogsParams params for find all
+type FindAllLogsParams struct {
+	Entity    string       `json:"entity"`
+	EntityID  int          `json:"entityId"`
+	Limit     int          `json:"limit"`
+	After     *data.Cursor `json:"after"`
+	WithTotal bool         `json:"withTotal"`
+}
+
+// Storage represents the audit log storage interface
+type Storage interface {
+	FindAll(ctx context.Context, params *FindAllLogsParams) ([]*Logs, *types.Error)
+	Count(ctx context.Context, params *FindAllLogsParams) (int, *types.Error)
+	InsertMany(ctx context.Context, logs []*Logs) *types.Error
+}
+
+// ServiceInterface represents the audit service interface
+type ServiceInterface interface {
+	Record(ctx context.Context, changes ...*Change) *types.Error
+	ListLogs(ctx context.Context, params *FindAllLogsParams) ([]*Logs, *data.Page, *types.Error)
+}
+
+// Service is the domain logic implementation of audit Service interface
+type Service struct {
+	auditStorage Storage
+}
+
+// Diff returns the fields whose json value differs between before and after,
+// as {"field": {"old": ..., "new": ...}}. A nil side has every field null.
+func Diff(before interface{}, after interface{}) (types.Metadata, error) {
+	oldFields, err := jsonFields(before)
+	if err != nil {
+		return nil, err
+	}
+	newFields, err := jsonFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := types.Metadata{}
+	for field, v := range oldFields {
+		if !reflect.DeepEqual(v, newFields[field]) {
+			diff[field] = map[string]interface{}{"old": v, "new": newFields[field]}
+		}
+	}
+	for field, v := range newFields {
+		if _, ok := oldFields[field]; !ok && v != nil {
+			diff[field] = map[string]interface{}{"old": nil, "new": v}
+		}
+	}
+	return diff, nil
+}
+
+func jsonFields(v interface{}) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+	if v == nil || (reflect.ValueOf(v).Kind() == reflect.Ptr && reflect.ValueOf(v).IsNil()) {
+		return fields, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(b, &fields)
+	if err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// Record records the changes, with the current user & request id.
+// It must run in the transaction of the changes, so they are only
+// recorded when they are committed.
+func (s *Service) Record(ctx context.Context, changes ...*Change) *types.Error {
+	if len(changes) < 1 {
+		return nil
+	}
+
+	var userID *int
+	if id := appcontext.UserID(ctx); id != 0 {
+		userID = &id
+	}
+	var requestID *string
+	if id := appcontext.RequestID(ctx); id != "" {
+		requestID = &id
+	}
+
+	now := time.Now()
+	logs := []*Logs{}
+	for _, change := range changes {
+		diff, errDiff := Diff(change.Before, change.After)
+		if errDiff != nil {
+			return &types.Error{
+				Path:    ".AuditService->Record()",
+				Message: errDiff.Error(),
+				Error:   errDiff,
+				Type:    "golang-error",
+			}
+		}
+		logs = append(logs, &Logs{
+			Entity:    change.Entity,
+			EntityID:  change.EntityID,
+			Action:    change.Action,
+			UserID:    userID,
+			Diff:      diff,
+			RequestID: requestID,
+			CreatedAt: now,
+		})
+	}
+
+	err := s.auditStorage.InsertMany(ctx, logs)
+	if err != nil {
+		err.Path = ".AuditService->Record()" + err.Path
+		return err
+	}
+
+	return nil
+}
+
+// ListLogs is listing the audit logs, newest first, a page after the params cursor.
+// The total is only counted when asked for.
+func (s *Service) ListLogs(ctx context.Context, params *FindAllLogsParams) ([]*Logs, *data.Page, *types.Error) {
+	limit := params.Limit
+	if limit > 0 {
+		// one more row tells whether there is a next page
+		params.Limit = limit + 1
+	}
+	logs, err := s.auditStorage.FindAll(ctx, params)
+	params.Limit = limit
+	if err != nil {
+		err.Path = ".AuditService->ListLogs()" + err.Path
+		return nil, nil, err
+	}
+
+	page := &data.Page{}
+	if limit > 0 && len(logs) > limit {
+		logs = logs[:limit]
+		last := logs[limit-1]
+		page.NextCursor = data.NewCursor(data.DefaultSort, []interface{}{last.CreatedAt}, last.ID).Encode()
+	}
+	if params.WithTotal {
+		count, err := s.auditStorage.Count(ctx, params)
+		if err != nil {
+			err.Path = ".AuditService->ListLogs()" + err.Path
+			return nil, nil, err
+		}
+		page.Total = &count
+	}
+
+	return logs, page, nil
+}
+
+// NewService creates a new audit AppService
+func NewService(
+	auditStorage Storage,
+) *Service {
+	return &Service{
+		auditStorage: auditStorage,
+	}
+}
@@ -0,0 +1,114 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/riskiramdan/evos/internal/audit"
+	"github.com/riskiramdan/evos/internal/data"
+	"github.com/riskiramdan/evos/internal/types"
+)
+
+// Storage implements the audit log storage service interface
+type Storage struct {
+	Storage data.GenericStorage
+}
+
+// sortColumns maps the sort fields to their column
+var sortColumns = map[string]string{
+	"createdAt": `"createdAt"`,
+}
+
+func filter(params *audit.FindAllLogsParams) (string, map[string]interface{}) {
+	where := `true`
+
+	if params.Entity != "" {
+		where += ` AND "entity" = :entity`
+	}
+	if params.EntityID != 0 {
+		where += ` AND "entityId" = :entityId`
+	}
+
+	return where, map[string]interface{}{
+		"entity":   params.Entity,
+		"entityId": params.EntityID,
+	}
+}
+
+// FindAll find all audit logs, newest first
+func (s *Storage) FindAll(ctx context.Context, params *audit.FindAllLogsParams) ([]*audit.Logs, *types.Error) {
+
+	logs := []*audit.Logs{}
+	where, arg := filter(params)
+
+	if params.After != nil {
+		after, err := data.DefaultSort.After(sortColumns, params.After, arg)
+		if err != nil {
+			return nil, &types.Error{
+				Path:    ".AuditStorage->FindAll()",
+				Message: err.Error(),
+				Error:   err,
+				Type:    "validation-error",
+			}
+		}
+		where += ` AND ` + after
+	}
+	where = fmt.Sprintf(`%s %s`, where, data.DefaultSort.Order(sortColumns))
+	if params.Limit != 0 {
+		where += ` LIMIT :limit`
+		arg["limit"] = params.Limit
+	}
+
+	err := s.Storage.Where(ctx, &logs, where, arg)
+	if err != nil {
+		return nil, &types.Error{
+			Path:    ".AuditStorage->FindAll()",
+			Message: err.Error(),
+			Error:   err,
+			Type:    "pq-error",
+		}
+	}
+
+	return logs, nil
+}
+
+// Count count the audit logs matching the params, ignoring the pagination
+func (s *Storage) Count(ctx context.Context, params *audit.FindAllLogsParams) (int, *types.Error) {
+	where, arg := filter(params)
+
+	count, err := s.Storage.Count(ctx, where, arg)
+	if err != nil {
+		return 0, &types.Error{
+			Path:    ".AuditStorage->Count()",
+			Message: err.Error(),
+			Error:   err,
+			Type:    "pq-error",
+		}
+	}
+
+	return count, nil
+}
+
+// InsertMany insert the audit logs, in chunks, without filling them back
+func (s *Storage) InsertMany(ctx context.Context, logs []*audit.Logs) *types.Error {
+	err := s.Storage.InsertMany(ctx, logs, "")
+	if err != nil {
+		return &types.Error{
+			Path:    ".AuditStorage->InsertMany()",
+			Message: err.Error(),
+			Error:   err,
+			Type:    "pq-error",
+		}
+	}
+
+	return nil
+}
+
+// NewPostgresStorage creates new audit log repository service
+func NewPostgresStorage(
+	storage data.GenericStorage,
+) *Storage {
+	return &Storage{
+		Storage: storage,
+	}
+}
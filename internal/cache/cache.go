@@ -0,0 +1,209 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/riskiramdan/evos/internal/logging"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/rs/zerolog"
+)
+
+// downFor is how long redis is skipped after an error, so a redis outage
+// doesn't add its timeouts to every request
+const downFor = 5 * time.Second
+
+// retryEvery is how often an invalidation that could not reach redis is retried
+const retryEvery = time.Second
+
+// errPending is returned while an invalidation is pending, the cache can't be trusted until then
+var errPending = errors.New("cache invalidation pending")
+
+// Cache is a redis cache of a namespace, invalidated as a whole by key versioning:
+// every key embeds the current generation of the namespace, and invalidating
+// increments it, so the entries of the previous generations are never read again
+// and expire with their TTL.
+//
+// Redis is optional: a nil client or a redis error falls back to a cache miss,
+// the callers then read from their storage.
+type Cache struct {
+	client    *redis.Client
+	namespace string
+	// retryEvery is how often a pending invalidation is retried
+	retryEvery time.Duration
+
+	mu        sync.Mutex
+	downUntil time.Time
+	// pending is set while an invalidation could not reach redis. It is retried in the
+	// background until redis takes it, so the other replicas stop serving the entries
+	// it invalidates as soon as redis is reachable, and the cache is not read meanwhile.
+	pending bool
+}
+
+// Slot is where a value read from the storage on a cache miss is cached: its key under
+// the generation read before the miss. A value read before an invalidation is cached
+// under the previous generation, where it is never read.
+type Slot struct {
+	key string
+}
+
+// New creates the cache of the namespace, client may be nil to disable it
+func New(client *redis.Client, namespace string) *Cache {
+	return &Cache{
+		client:     client,
+		namespace:  namespace,
+		retryEvery: retryEvery,
+	}
+}
+
+// Key builds a cache key from the parts, hashing the ones that are not strings
+func Key(parts ...interface{}) string {
+	key := ""
+	for i, part := range parts {
+		if i > 0 {
+			key += ":"
+		}
+		switch v := part.(type) {
+		case string:
+			key += v
+		case int:
+			key += fmt.Sprint(v)
+		default:
+			b, _ := json.Marshal(v)
+			sum := sha1.Sum(b)
+			key += hex.EncodeToString(sum[:])
+		}
+	}
+	return key
+}
+
+func (c *Cache) available() bool {
+	if c.client == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return time.Now().After(c.downUntil)
+}
+
+func (c *Cache) failed(ctx context.Context, err error) {
+	logging.FromContext(ctx).Warn().Err(err).Str("namespace", c.namespace).Msg("cache unavailable, reading through")
+	c.mu.Lock()
+	c.downUntil = time.Now().Add(downFor)
+	c.mu.Unlock()
+}
+
+func (c *Cache) generationKey() string {
+	return c.namespace + ":generation"
+}
+
+// generation returns the current generation of the namespace
+func (c *Cache) generation(ctx context.Context) (int64, error) {
+	c.mu.Lock()
+	pending := c.pending
+	c.mu.Unlock()
+	if pending {
+		return 0, errPending
+	}
+
+	generation, err := c.client.Get(ctx, c.generationKey()).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return generation, err
+}
+
+// Get reads the cached value of the key into dest, and tells whether it was found.
+// On a miss, the value read from the storage is cached in the returned slot.
+func (c *Cache) Get(ctx context.Context, key string, dest interface{}) (Slot, bool) {
+	if !c.available() {
+		return Slot{}, false
+	}
+
+	generation, err := c.generation(ctx)
+	if err == errPending {
+		return Slot{}, false
+	}
+	if err != nil {
+		c.failed(ctx, err)
+		return Slot{}, false
+	}
+	slot := Slot{key: fmt.Sprintf("%s:%d:%s", c.namespace, generation, key)}
+	b, err := c.client.Get(ctx, slot.key).Bytes()
+	if err == redis.Nil {
+		return slot, false
+	}
+	if err != nil {
+		c.failed(ctx, err)
+		return Slot{}, false
+	}
+
+	if json.Unmarshal(b, dest) != nil {
+		return slot, false
+	}
+	return slot, true
+}
+
+// Set caches the value in the slot of a miss for the ttl
+func (c *Cache) Set(ctx context.Context, slot Slot, value interface{}, ttl time.Duration) {
+	if slot.key == "" || !c.available() {
+		return
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	err = c.client.Set(ctx, slot.key, b, ttl).Err()
+	if err != nil {
+		c.failed(ctx, err)
+	}
+}
+
+// Invalidate drops every entry of the namespace by moving to the next generation.
+// When redis can't be reached, the invalidation is retried in the background until it is.
+func (c *Cache) Invalidate(ctx context.Context) {
+	if c.client == nil {
+		return
+	}
+
+	err := c.client.Incr(ctx, c.generationKey()).Err()
+	if err == nil {
+		return
+	}
+	c.failed(ctx, err)
+
+	c.mu.Lock()
+	retrying := c.pending
+	c.pending = true
+	c.mu.Unlock()
+	if !retrying {
+		go c.retryInvalidate(logging.FromContext(ctx))
+	}
+}
+
+// retryInvalidate retries a pending invalidation until redis takes it, logging with
+// the logger of the invalidating request
+func (c *Cache) retryInvalidate(logger *zerolog.Logger) {
+	ticker := time.NewTicker(c.retryEvery)
+	defer ticker.Stop()
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), c.retryEvery)
+		err := c.client.Incr(ctx, c.generationKey()).Err()
+		cancel()
+		if err == nil {
+			c.mu.Lock()
+			c.pending = false
+			c.mu.Unlock()
+			logger.Info().Str("namespace", c.namespace).Msg("pending cache invalidation done")
+			return
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/riskiramdan/evos/internal/logging"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/rs/zerolog"
+)
+
+func newTestCache(t *testing.T) (*Cache, *miniredis.Miniredis) {
+	t.Helper()
+	server, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+
+	c := New(client, "test")
+	c.retryEvery = 10 * time.Millisecond
+	return c, server
+}
+
+func TestGetSet(t *testing.T) {
+	ctx := context.Background()
+	c, _ := newTestCache(t)
+
+	value := 0
+	slot, found := c.Get(ctx, "k", &value)
+	if found {
+		t.Fatal("found before being set")
+	}
+	c.Set(ctx, slot, 42, time.Minute)
+
+	_, found = c.Get(ctx, "k", &value)
+	if !found || value != 42 {
+		t.Fatalf("got %d, %v, want 42, true", value, found)
+	}
+}
+
+func TestInvalidate(t *testing.T) {
+	ctx := context.Background()
+	c, _ := newTestCache(t)
+
+	value := 0
+	slot, _ := c.Get(ctx, "k", &value)
+	c.Set(ctx, slot, 42, time.Minute)
+	c.Invalidate(ctx)
+
+	if _, found := c.Get(ctx, "k", &value); found {
+		t.Fatal("found after the invalidation")
+	}
+}
+
+// A value read before an invalidation must not be cached after it
+func TestSetAfterInvalidate(t *testing.T) {
+	ctx := context.Background()
+	c, _ := newTestCache(t)
+
+	value := 0
+	slot, _ := c.Get(ctx, "k", &value)
+	// a write commits while the storage is read
+	c.Invalidate(ctx)
+	c.Set(ctx, slot, 42, time.Minute)
+
+	if _, found := c.Get(ctx, "k", &value); found {
+		t.Fatal("the stale value is served")
+	}
+}
+
+func TestNoClient(t *testing.T) {
+	ctx := context.Background()
+	c := New(nil, "test")
+
+	value := 0
+	slot, found := c.Get(ctx, "k", &value)
+	c.Set(ctx, slot, 42, time.Minute)
+	c.Invalidate(ctx)
+	if _, found = c.Get(ctx, "k", &value); found {
+		t.Fatal("found without redis")
+	}
+}
+
+// An invalidation that could not reach redis is retried once it is back,
+// the other replicas then stop serving the invalidated entries
+func TestPendingInvalidation(t *testing.T) {
+	ctx := context.Background()
+	c, server := newTestCache(t)
+	replica := New(c.client, "test")
+
+	value := 0
+	slot, _ := replica.Get(ctx, "k", &value)
+	replica.Set(ctx, slot, 42, time.Minute)
+
+	server.Close()
+	c.Invalidate(ctx)
+	if _, found := c.Get(ctx, "k", &value); found {
+		t.Fatal("read while the invalidation is pending")
+	}
+	if err := server.Restart(); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		c.mu.Lock()
+		pending := c.pending
+		c.mu.Unlock()
+		if !pending {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the invalidation is still pending")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, found := replica.Get(ctx, "k", &value); found {
+		t.Fatal("the replica serves the invalidated entry")
+	}
+}
+
+// An unavailable redis is logged with the logger of the request
+func TestFailedLogsWithRequestLogger(t *testing.T) {
+	c, server := newTestCache(t)
+	var buf bytes.Buffer
+	ctx := logging.WithLogger(context.Background(), zerolog.New(&buf).With().Str("requestId", "test-1").Logger())
+
+	server.Close()
+	value := 0
+	c.Get(ctx, "k", &value)
+
+	if !strings.Contains(buf.String(), `"requestId":"test-1"`) || !strings.Contains(buf.String(), "cache unavailable") {
+		t.Fatalf("got log %q", buf.String())
+	}
+}
@@ -0,0 +1,184 @@
+package character
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/riskiramdan/evos/internal/appcontext"
+	"github.com/riskiramdan/evos/internal/audit"
+	"github.com/riskiramdan/evos/internal/charactertype"
+	"github.com/riskiramdan/evos/internal/data"
+	"github.com/riskiramdan/evos/internal/metrics"
+	"github.com/riskiramdan/evos/internal/types"
+)
+
+// Status of a bulk row
+const (
+	BulkCreated = "created"
+	BulkUpdated = "updated"
+	BulkFailed  = "failed"
+)
+
+// BulkRow is a valid row of a bulk import, Row is its position in the import starting at 1
+type BulkRow struct {
+	Row    int
+	Params *TransactionParams
+}
+
+// BulkResult reports what happened to a row of a bulk import
+type BulkResult struct {
+	Row       int
+	Status    string
+	Character *Characters
+	Error     error
+}
+
+// BulkCreateCharacters creates the characters of the rows in bulk. The rows with an invalid
+// character type or a name already taken fail, the others are inserted together.
+// With upsert the characters whose name is taken are updated instead.
+func (s *Service) BulkCreateCharacters(ctx context.Context, rows []*BulkRow, upsert bool) ([]*BulkResult, *types.Error) {
+	ctx, span := tracer.Start(ctx, "CharacterService.BulkCreateCharacters")
+	defer span.End()
+
+	results := []*BulkResult{}
+	if len(rows) < 1 {
+		return results, nil
+	}
+
+	ids := []int{}
+	names := []string{}
+	for _, row := range rows {
+		if !containsInt(ids, row.Params.CharacterTypeID) {
+			ids = append(ids, row.Params.CharacterTypeID)
+		}
+		names = append(names, row.Params.Name)
+	}
+
+	characterTypes, err := s.characterTypeStorage.FindAll(ctx, &charactertype.FindAllCharacterTypeParams{
+		IDs: ids,
+	})
+	if err != nil {
+		err.Path = ".characterservice->BulkCreateCharacters()" + err.Path
+		return nil, err
+	}
+	validTypes := map[int]bool{}
+	for _, v := range characterTypes {
+		validTypes[v.ID] = true
+	}
+
+	existing, err := s.characterStorage.FindAll(ctx, &FindAllCharacterParams{
+		Names: names,
+	})
+	if err != nil {
+		err.Path = ".characterservice->BulkCreateCharacters()" + err.Path
+		return nil, err
+	}
+	taken := map[string]*Characters{}
+	for _, v := range existing {
+		taken[strings.ToLower(v.Name)] = v
+	}
+
+	now := time.Now()
+	actor := appcontext.Actor(ctx)
+	seen := map[string]bool{}
+	characters := []*Characters{}
+	for _, row := range rows {
+		result := &BulkResult{Row: row.Row, Status: BulkCreated}
+		results = append(results, result)
+
+		name := strings.ToLower(row.Params.Name)
+		switch {
+		case !validTypes[row.Params.CharacterTypeID]:
+			result.Status, result.Error = BulkFailed, ErrInvalidCharacterType
+		case seen[name]:
+			result.Status, result.Error = BulkFailed, ErrDuplicateName
+		case taken[name] != nil && !upsert:
+			result.Status, result.Error = BulkFailed, ErrCharacterExists
+		case row.Params.Power == nil:
+			result.Status, result.Error = BulkFailed, ErrInvalidPower
+		}
+		seen[name] = true
+		if result.Status == BulkFailed {
+			continue
+		}
+		if taken[name] != nil {
+			result.Status = BulkUpdated
+		}
+
+		result.Character = &Characters{
+			Name:            row.Params.Name,
+			CharacterTypeID: row.Params.CharacterTypeID,
+			Power:           *row.Params.Power,
+			CreatedBy:       actor,
+			CreatedAt:       now,
+			UpdatedBy:       actor,
+			UpdatedAt:       &now,
+		}
+		characters = append(characters, result.Character)
+	}
+	if len(characters) < 1 {
+		return results, nil
+	}
+
+	if upsert {
+		err = s.characterStorage.UpsertMany(ctx, characters)
+	} else {
+		err = s.characterStorage.InsertMany(ctx, characters)
+	}
+	if err != nil {
+		err.Path = ".characterservice->BulkCreateCharacters()" + err.Path
+		return nil, err
+	}
+
+	// the characters of the results are filled back with the inserted rows
+	err = s.calculateValues(ctx, append(characters, existing...))
+	if err != nil {
+		err.Path = ".characterservice->BulkCreateCharacters()" + err.Path
+		return nil, err
+	}
+
+	changes := []*audit.Change{}
+	for _, result := range results {
+		if result.Status == BulkFailed {
+			continue
+		}
+		change := &audit.Change{
+			Entity:   audit.EntityCharacter,
+			EntityID: result.Character.ID,
+			Action:   audit.ActionCreate,
+			After:    result.Character,
+		}
+		if result.Status == BulkUpdated {
+			change.Action = audit.ActionUpdate
+			change.Before = taken[strings.ToLower(result.Character.Name)]
+		}
+		changes = append(changes, change)
+	}
+	err = s.auditService.Record(ctx, changes...)
+	if err != nil {
+		err.Path = ".characterservice->BulkCreateCharacters()" + err.Path
+		return nil, err
+	}
+
+	created := 0
+	for _, change := range changes {
+		if change.Action == audit.ActionCreate {
+			created++
+		}
+	}
+	data.AfterCommit(ctx, func() {
+		metrics.CharactersCreated.Add(float64(created))
+	})
+
+	return results, nil
+}
+
+func containsInt(list []int, v int) bool {
+	for _, i := range list {
+		if i == v {
+			return true
+		}
+	}
+	return false
+}
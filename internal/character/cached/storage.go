@@ -0,0 +1,218 @@
+package cached
+
+import (
+	"context"
+	"time"
+
+	"github.com/riskiramdan/evos/internal/cache"
+	"github.com/riskiramdan/evos/internal/character"
+	"github.com/riskiramdan/evos/internal/charactertype"
+	"github.com/riskiramdan/evos/internal/data"
+	"github.com/riskiramdan/evos/internal/logging"
+	"github.com/riskiramdan/evos/internal/types"
+)
+
+// Storage decorates a character storage with a cache of the reads.
+// Every write invalidates the whole cache once committed, and the reads made
+// inside a transaction go straight to the storage, as they may see uncommitted rows.
+type Storage struct {
+	storage character.Storage
+	cache   *cache.Cache
+	itemTTL time.Duration
+	listTTL time.Duration
+}
+
+func inTransaction(ctx context.Context) bool {
+	_, ok := data.TxFromContext(ctx)
+	return ok
+}
+
+// detached returns a context that is not cancelled with the request of ctx,
+// logging with its logger
+func detached(ctx context.Context) context.Context {
+	return logging.WithLogger(context.Background(), *logging.FromContext(ctx))
+}
+
+// invalidate drops the cache once the write is committed
+func (s *Storage) invalidate(ctx context.Context) {
+	data.AfterCommit(ctx, func() {
+		s.cache.Invalidate(detached(ctx))
+	})
+}
+
+// FindAll find all characters, cached for the list TTL
+func (s *Storage) FindAll(ctx context.Context, params *character.FindAllCharacterParams) ([]*character.Characters, *types.Error) {
+	if inTransaction(ctx) {
+		return s.storage.FindAll(ctx, params)
+	}
+
+	key := cache.Key("list", params)
+	characters := []*character.Characters{}
+	slot, found := s.cache.Get(ctx, key, &characters)
+	if found {
+		return characters, nil
+	}
+
+	characters, err := s.storage.FindAll(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	s.cache.Set(ctx, slot, characters, s.listTTL)
+
+	return characters, nil
+}
+
+// Count count the characters matching the params, cached for the list TTL
+func (s *Storage) Count(ctx context.Context, params *character.FindAllCharacterParams) (int, *types.Error) {
+	if inTransaction(ctx) {
+		return s.storage.Count(ctx, params)
+	}
+
+	key := cache.Key("count", params)
+	count := 0
+	slot, found := s.cache.Get(ctx, key, &count)
+	if found {
+		return count, nil
+	}
+
+	count, err := s.storage.Count(ctx, params)
+	if err != nil {
+		return 0, err
+	}
+	s.cache.Set(ctx, slot, count, s.listTTL)
+
+	return count, nil
+}
+
+// FindByID find character by its id, cached for the item TTL
+func (s *Storage) FindByID(ctx context.Context, characterID int) (*character.Characters, *types.Error) {
+	if inTransaction(ctx) {
+		return s.storage.FindByID(ctx, characterID)
+	}
+
+	key := cache.Key("id", characterID)
+	cached := &character.Characters{}
+	slot, found := s.cache.Get(ctx, key, cached)
+	if found {
+		return cached, nil
+	}
+
+	character, err := s.storage.FindByID(ctx, characterID)
+	if err != nil {
+		return nil, err
+	}
+	s.cache.Set(ctx, slot, character, s.itemTTL)
+
+	return character, nil
+}
+
+// FindDeletedByID find deleted character by its id, never cached
+func (s *Storage) FindDeletedByID(ctx context.Context, characterID int) (*character.Characters, *types.Error) {
+	return s.storage.FindDeletedByID(ctx, characterID)
+}
+
+// Insert insert character
+func (s *Storage) Insert(ctx context.Context, character *character.Characters) (*character.Characters, *types.Error) {
+	character, err := s.storage.Insert(ctx, character)
+	if err != nil {
+		return nil, err
+	}
+	s.invalidate(ctx)
+	return character, nil
+}
+
+// Update update character
+func (s *Storage) Update(ctx context.Context, character *character.Characters) (*character.Characters, *types.Error) {
+	character, err := s.storage.Update(ctx, character)
+	if err != nil {
+		return nil, err
+	}
+	s.invalidate(ctx)
+	return character, nil
+}
+
+// Delete soft delete character
+func (s *Storage) Delete(ctx context.Context, characterID int, deletedBy string) *types.Error {
+	err := s.storage.Delete(ctx, characterID, deletedBy)
+	if err != nil {
+		return err
+	}
+	s.invalidate(ctx)
+	return nil
+}
+
+// Restore restore a soft deleted character
+func (s *Storage) Restore(ctx context.Context, characterID int) *types.Error {
+	err := s.storage.Restore(ctx, characterID)
+	if err != nil {
+		return err
+	}
+	s.invalidate(ctx)
+	return nil
+}
+
+// InsertMany insert characters in bulk
+func (s *Storage) InsertMany(ctx context.Context, characters []*character.Characters) *types.Error {
+	err := s.storage.InsertMany(ctx, characters)
+	if err != nil {
+		return err
+	}
+	s.invalidate(ctx)
+	return nil
+}
+
+// UpsertMany insert or update characters in bulk
+func (s *Storage) UpsertMany(ctx context.Context, characters []*character.Characters) *types.Error {
+	err := s.storage.UpsertMany(ctx, characters)
+	if err != nil {
+		return err
+	}
+	s.invalidate(ctx)
+	return nil
+}
+
+// NewStorage creates the cached character storage
+func NewStorage(
+	storage character.Storage,
+	cache *cache.Cache,
+	itemTTL time.Duration,
+	listTTL time.Duration,
+) *Storage {
+	return &Storage{
+		storage: storage,
+		cache:   cache,
+		itemTTL: itemTTL,
+		listTTL: listTTL,
+	}
+}
+
+// CharacterTypeStorage decorates a character type storage to invalidate the
+// cached characters when a character type is updated, as the value of
+// the characters (filtered & sorted on in the listings) depends on it
+type CharacterTypeStorage struct {
+	charactertype.Storage
+	cache *cache.Cache
+}
+
+// Update update character type
+func (s *CharacterTypeStorage) Update(ctx context.Context, characterType *charactertype.CharacterTypes) (*charactertype.CharacterTypes, *types.Error) {
+	characterType, err := s.Storage.Update(ctx, characterType)
+	if err != nil {
+		return nil, err
+	}
+	data.AfterCommit(ctx, func() {
+		s.cache.Invalidate(detached(ctx))
+	})
+	return characterType, nil
+}
+
+// NewCharacterTypeStorage creates the character type storage invalidating the cached characters
+func NewCharacterTypeStorage(
+	storage charactertype.Storage,
+	cache *cache.Cache,
+) *CharacterTypeStorage {
+	return &CharacterTypeStorage{
+		Storage: storage,
+		cache:   cache,
+	}
+}
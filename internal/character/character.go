@@ -5,16 +5,27 @@ import (
 	"errors"
 	"time"
 
+	"github.com/riskiramdan/evos/internal/appcontext"
+	"github.com/riskiramdan/evos/internal/audit"
+	"github.com/riskiramdan/evos/internal/charactertype"
 	"github.com/riskiramdan/evos/internal/data"
+	"github.com/riskiramdan/evos/internal/metrics"
 	"github.com/riskiramdan/evos/internal/types"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // Errors
 var (
-	ErrInvalidPower    = errors.New("Invalid Power")
-	ErrCharacterExists = errors.New("Character already exists")
+	ErrInvalidPower         = types.NewDomainError(types.KindValidation, "InvalidPower", "Invalid Power")
+	ErrCharacterExists      = types.NewDomainError(types.KindConflict, "CharacterExists", "Character already exists")
+	ErrInvalidCharacterType = types.NewDomainError(types.KindValidation, "InvalidCharacterType", "Invalid character type")
+	ErrDuplicateName        = types.NewDomainError(types.KindValidation, "DuplicateName", "Name appears more than once")
 )
 
+var tracer = otel.Tracer("github.com/riskiramdan/evos/internal/character")
+
 // Characters character
 type Characters struct {
 	ID              int        `json:"id" db:"id"`
@@ -26,113 +37,214 @@ type Characters struct {
 	CreatedBy       string     `json:"createdBy" db:"createdBy"`
 	UpdatedAt       *time.Time `json:"updatedAt" db:"updatedAt"`
 	UpdatedBy       string     `json:"updatedBy" db:"updatedBy"`
+	DeletedAt       *time.Time `json:"deletedAt,omitempty" db:"deletedAt"`
+	DeletedBy       *string    `json:"deletedBy,omitempty" db:"deletedBy"`
+	Version         int        `json:"version" db:"version"`
 }
 
 //FindAllCharacterParams params for find all
 type FindAllCharacterParams struct {
-	ID    int    `json:"id"`
-	Page  int    `json:"page"`
-	Limit int    `json:"limit"`
-	Name  string `json:"name"`
+	ID               int          `json:"id"`
+	Limit            int          `json:"limit"`
+	After            *data.Cursor `json:"after"`
+	WithTotal        bool         `json:"withTotal"`
+	Name             string       `json:"name"`
+	Names            []string     `json:"names"`
+	Deleted          bool         `json:"deleted"`
+	CharacterTypeIDs []int        `json:"characterTypeIds"`
+	PowerMin         *int         `json:"powerMin"`
+	PowerMax         *int         `json:"powerMax"`
+	ValueMin         *int         `json:"valueMin"`
+	ValueMax         *int         `json:"valueMax"`
+	CreatedFrom      *time.Time   `json:"createdFrom"`
+	CreatedTo        *time.Time   `json:"createdTo"`
+	UpdatedFrom      *time.Time   `json:"updatedFrom"`
+	UpdatedTo        *time.Time   `json:"updatedTo"`
+	CreatedBy        string       `json:"createdBy"`
+	Sort             data.Sort    `json:"sort"`
+}
+
+// SortFields are the fields the characters can be sorted by
+var SortFields = []string{"id", "name", "power", "value", "characterTypeID", "createdAt", "updatedAt"}
+
+// SortValue returns the value of the character for a sort field,
+// the value must have been calculated before
+func (c *Characters) SortValue(field string) interface{} {
+	switch field {
+	case "id":
+		return c.ID
+	case "name":
+		return c.Name
+	case "power":
+		return c.Power
+	case "value":
+		return c.Value
+	case "characterTypeID":
+		return c.CharacterTypeID
+	case "updatedAt":
+		// characters never updated are sorted by their creation
+		if c.UpdatedAt != nil {
+			return *c.UpdatedAt
+		}
+		return c.CreatedAt
+	}
+	return c.CreatedAt
 }
 
 // TransactionParams params for transaction
 type TransactionParams struct {
-	CharacterTypeID int    `json:"characterTypeID,omitempty"`
-	Name            string `json:"name"`
-	Power           *int   `json:"power,omitempty"`
+	CharacterTypeID int    `json:"characterTypeID,omitempty" validate:"required,min=1"`
+	Name            string `json:"name" validate:"required,max=80"`
+	Power           *int   `json:"power,omitempty" validate:"required,min=0,max=1000000"`
+	// Version is the version the update is based on, it fails with data.ErrConflict on any other
+	Version *int `json:"version,omitempty" validate:"omitempty,min=1"`
 }
 
 // Storage represents the character storage interface
 type Storage interface {
 	FindAll(ctx context.Context, params *FindAllCharacterParams) ([]*Characters, *types.Error)
+	Count(ctx context.Context, params *FindAllCharacterParams) (int, *types.Error)
 	FindByID(ctx context.Context, characterID int) (*Characters, *types.Error)
 	Insert(ctx context.Context, character *Characters) (*Characters, *types.Error)
 	Update(ctx context.Context, character *Characters) (*Characters, *types.Error)
-	Delete(ctx context.Context, characterID int) *types.Error
+	FindDeletedByID(ctx context.Context, characterID int) (*Characters, *types.Error)
+	Delete(ctx context.Context, characterID int, deletedBy string) *types.Error
+	Restore(ctx context.Context, characterID int) *types.Error
+	InsertMany(ctx context.Context, characters []*Characters) *types.Error
+	UpsertMany(ctx context.Context, characters []*Characters) *types.Error
 }
 
 // ServiceInterface represents the character service interface
 type ServiceInterface interface {
-	ListCharacters(ctx context.Context, params *FindAllCharacterParams) ([]*Characters, int, *types.Error)
+	ListCharacters(ctx context.Context, params *FindAllCharacterParams) ([]*Characters, *data.Page, *types.Error)
 	GetCharacter(ctx context.Context, characterID int) (*Characters, *types.Error)
 	CreateCharacter(ctx context.Context, params *TransactionParams) (*Characters, *types.Error)
 	UpdateCharacter(ctx context.Context, characterID int, params *TransactionParams) (*Characters, *types.Error)
+	DeleteCharacter(ctx context.Context, characterID int) *types.Error
+	RestoreCharacter(ctx context.Context, characterID int) (*Characters, *types.Error)
+	BulkCreateCharacters(ctx context.Context, rows []*BulkRow, upsert bool) ([]*BulkResult, *types.Error)
 }
 
 // Service is the domain logic implementation of character Service interface
 type Service struct {
-	characterStorage Storage
+	characterStorage     Storage
+	characterTypeStorage charactertype.Storage
+	auditService         audit.ServiceInterface
 }
 
-func calculateValue(power, characterTypeID int) int {
-	value := 0
-	if characterTypeID == 0 {
-		return value
+func (s *Service) calculateValues(ctx context.Context, characters []*Characters) *types.Error {
+	ctx, span := tracer.Start(ctx, "CharacterService.calculateValues")
+	defer span.End()
+	span.SetAttributes(attribute.Int("characters", len(characters)))
+
+	ids := []int{}
+	for _, v := range characters {
+		ids = append(ids, v.CharacterTypeID)
+	}
+	if len(ids) < 1 {
+		return nil
+	}
+
+	characterTypes, err := s.characterTypeStorage.FindAll(ctx, &charactertype.FindAllCharacterTypeParams{
+		IDs: ids,
+	})
+	if err != nil {
+		err.Path = ".characterservice->calculateValues()" + err.Path
+		return err
 	}
-	switch characterTypeID {
-	case 1:
-		{
-			value = calculatePercentage(power, 150)
-		}
-	case 2:
-		{
-			value = 2 + calculatePercentage(power, 110)
-		}
-	case 3:
-		{
-			value = calculatePercentage(power, 300)
-			if power < 20 {
-				value = calculatePercentage(power, 200)
-			}
-		}
-	default:
-		{
-			value = 0
+
+	formulas := map[int]*charactertype.CharacterTypes{}
+	for _, v := range characterTypes {
+		formulas[v.ID] = v
+	}
+	for _, v := range characters {
+		v.Value = 0
+		if formula, ok := formulas[v.CharacterTypeID]; ok {
+			v.Value = formula.CalculateValue(v.Power)
 		}
 	}
-	return value
-}
 
-func calculatePercentage(value, amount int) int {
-	return value * amount / 100
+	return nil
 }
 
-// ListCharacters is listing characters
-func (s *Service) ListCharacters(ctx context.Context, params *FindAllCharacterParams) ([]*Characters, int, *types.Error) {
+// ListCharacters is listing characters, a page after the params cursor.
+// The total is only counted when asked for.
+func (s *Service) ListCharacters(ctx context.Context, params *FindAllCharacterParams) ([]*Characters, *data.Page, *types.Error) {
+	ctx, span := tracer.Start(ctx, "CharacterService.ListCharacters")
+	defer span.End()
+
+	if len(params.Sort) == 0 {
+		params.Sort = data.DefaultSort
+	}
+
+	limit := params.Limit
+	if limit > 0 {
+		// one more row tells whether there is a next page
+		params.Limit = limit + 1
+	}
 	characters, err := s.characterStorage.FindAll(ctx, params)
+	params.Limit = limit
 	if err != nil {
 		err.Path = ".characterservice->Listcharacters()" + err.Path
-		return nil, 0, err
+		return nil, nil, err
 	}
-	params.Page = 0
-	params.Limit = 0
-	allcharacters, err := s.characterStorage.FindAll(ctx, params)
+
+	page := &data.Page{}
+	hasNext := limit > 0 && len(characters) > limit
+	if hasNext {
+		characters = characters[:limit]
+	}
+	if params.WithTotal {
+		count, err := s.characterStorage.Count(ctx, params)
+		if err != nil {
+			err.Path = ".characterservice->Listcharacters()" + err.Path
+			return nil, nil, err
+		}
+		page.Total = &count
+	}
+
+	err = s.calculateValues(ctx, characters)
 	if err != nil {
 		err.Path = ".characterservice->Listcharacters()" + err.Path
-		return nil, 0, err
+		return nil, nil, err
 	}
 
-	for _, v := range characters {
-		v.Value = calculateValue(v.Power, v.CharacterTypeID)
+	if hasNext {
+		last := characters[limit-1]
+		values := []interface{}{}
+		for _, f := range params.Sort {
+			values = append(values, last.SortValue(f.Name))
+		}
+		page.NextCursor = data.NewCursor(params.Sort, values, last.ID).Encode()
 	}
 
-	return characters, len(allcharacters), nil
+	return characters, page, nil
 }
 
 // GetCharacter is get character
 func (s *Service) GetCharacter(ctx context.Context, characterID int) (*Characters, *types.Error) {
+	ctx, span := tracer.Start(ctx, "CharacterService.GetCharacter")
+	defer span.End()
+
 	character, err := s.characterStorage.FindByID(ctx, characterID)
 	if err != nil {
 		err.Path = ".characterservice->GetCharacter()" + err.Path
 		return nil, err
 	}
+	err = s.calculateValues(ctx, []*Characters{character})
+	if err != nil {
+		err.Path = ".characterservice->GetCharacter()" + err.Path
+		return nil, err
+	}
 
 	return character, nil
 }
 
 // CreateCharacter create character
 func (s *Service) CreateCharacter(ctx context.Context, params *TransactionParams) (*Characters, *types.Error) {
+	ctx, span := tracer.Start(ctx, "CharacterService.CreateCharacter")
+	defer span.End()
+
 	characters, _, errType := s.ListCharacters(ctx, &FindAllCharacterParams{
 		Name: params.Name,
 	})
@@ -149,15 +261,39 @@ func (s *Service) CreateCharacter(ctx context.Context, params *TransactionParams
 		}
 	}
 
+	if params.Power == nil {
+		return nil, &types.Error{
+			Path:    ".characterservice->CreateCharacter()",
+			Message: ErrInvalidPower.Error(),
+			Error:   ErrInvalidPower,
+			Type:    "validation-error",
+		}
+	}
+
+	_, errType = s.characterTypeStorage.FindByID(ctx, params.CharacterTypeID)
+	if errType != nil {
+		if errors.Is(errType.Error, data.ErrNotFound) {
+			return nil, &types.Error{
+				Path:    ".characterservice->CreateCharacter()",
+				Message: ErrInvalidCharacterType.Error(),
+				Error:   ErrInvalidCharacterType,
+				Type:    "validation-error",
+			}
+		}
+		errType.Path = ".characterservice->CreateCharacter()" + errType.Path
+		return nil, errType
+	}
+
 	now := time.Now()
+	actor := appcontext.Actor(ctx)
 
 	character := &Characters{
 		Name:            params.Name,
 		CharacterTypeID: params.CharacterTypeID,
 		Power:           *params.Power,
-		CreatedBy:       "Admin",
+		CreatedBy:       actor,
 		CreatedAt:       now,
-		UpdatedBy:       "Admin",
+		UpdatedBy:       actor,
 		UpdatedAt:       &now,
 	}
 
@@ -166,29 +302,62 @@ func (s *Service) CreateCharacter(ctx context.Context, params *TransactionParams
 		errType.Path = ".characterservice->CreateCharacter()" + errType.Path
 		return nil, errType
 	}
+	errType = s.calculateValues(ctx, []*Characters{character})
+	if errType != nil {
+		errType.Path = ".characterservice->CreateCharacter()" + errType.Path
+		return nil, errType
+	}
+
+	errType = s.auditService.Record(ctx, &audit.Change{
+		Entity:   audit.EntityCharacter,
+		EntityID: character.ID,
+		Action:   audit.ActionCreate,
+		After:    character,
+	})
+	if errType != nil {
+		errType.Path = ".characterservice->CreateCharacter()" + errType.Path
+		return nil, errType
+	}
+	data.AfterCommit(ctx, metrics.CharactersCreated.Inc)
 
 	return character, nil
 }
 
 // UpdateCharacter update a character
 func (s *Service) UpdateCharacter(ctx context.Context, characterID int, params *TransactionParams) (*Characters, *types.Error) {
+	ctx, span := tracer.Start(ctx, "CharacterService.UpdateCharacter")
+	defer span.End()
+
 	character, err := s.GetCharacter(ctx, characterID)
 	if err != nil {
 		err.Path = ".CharacterService->UpdateCharacter()" + err.Path
 		return nil, err
 	}
+	if params.Version != nil && *params.Version != character.Version {
+		return nil, &types.Error{
+			Path:    ".CharacterService->UpdateCharacter()",
+			Message: data.ErrConflict.Error(),
+			Error:   data.ErrConflict,
+			Type:    "validation-error",
+		}
+	}
+	before := *character
 
-	if params.Name != "" {
+	if params.Name != "" && params.Name != character.Name {
+		// the exact name, case insensitively, as the unique index on LOWER("name") compares them
 		characters, _, err := s.ListCharacters(ctx, &FindAllCharacterParams{
-			Name: params.Name,
+			Names: []string{params.Name},
 		})
 		if err != nil {
 			err.Path = ".CharacterService->UpdateCharacter()" + err.Path
 			return nil, err
 		}
-		if len(characters) > 0 {
+		for _, c := range characters {
+			if c.ID == characterID {
+				continue
+			}
 			return nil, &types.Error{
-				Path:    ".CharacterService->CreateCharacter()",
+				Path:    ".CharacterService->UpdateCharacter()",
 				Message: data.ErrAlreadyExist.Error(),
 				Error:   data.ErrAlreadyExist,
 				Type:    "validation-error",
@@ -196,18 +365,143 @@ func (s *Service) UpdateCharacter(ctx context.Context, characterID int, params *
 		}
 		character.Name = params.Name
 	}
+	if params.CharacterTypeID != 0 && params.CharacterTypeID != character.CharacterTypeID {
+		_, err = s.characterTypeStorage.FindByID(ctx, params.CharacterTypeID)
+		if err != nil {
+			if errors.Is(err.Error, data.ErrNotFound) {
+				return nil, &types.Error{
+					Path:    ".CharacterService->UpdateCharacter()",
+					Message: ErrInvalidCharacterType.Error(),
+					Error:   ErrInvalidCharacterType,
+					Type:    "validation-error",
+				}
+			}
+			err.Path = ".CharacterService->UpdateCharacter()" + err.Path
+			return nil, err
+		}
+		character.CharacterTypeID = params.CharacterTypeID
+	}
 	if params.Power != nil {
 		character.Power = *params.Power
 	}
 
 	now := time.Now()
 	character.UpdatedAt = &now
+	character.UpdatedBy = appcontext.Actor(ctx)
 
 	character, err = s.characterStorage.Update(ctx, character)
 	if err != nil {
 		err.Path = ".CharacterService->UpdateCharacter()" + err.Path
 		return nil, err
 	}
+	err = s.calculateValues(ctx, []*Characters{character})
+	if err != nil {
+		err.Path = ".CharacterService->UpdateCharacter()" + err.Path
+		return nil, err
+	}
+
+	err = s.auditService.Record(ctx, &audit.Change{
+		Entity:   audit.EntityCharacter,
+		EntityID: character.ID,
+		Action:   audit.ActionUpdate,
+		Before:   &before,
+		After:    character,
+	})
+	if err != nil {
+		err.Path = ".CharacterService->UpdateCharacter()" + err.Path
+		return nil, err
+	}
+
+	return character, nil
+}
+
+// DeleteCharacter soft deletes a character, recording the current user as the deleter
+func (s *Service) DeleteCharacter(ctx context.Context, characterID int) *types.Error {
+	ctx, span := tracer.Start(ctx, "CharacterService.DeleteCharacter")
+	defer span.End()
+
+	character, err := s.GetCharacter(ctx, characterID)
+	if err != nil {
+		err.Path = ".CharacterService->DeleteCharacter()" + err.Path
+		return err
+	}
+
+	err = s.characterStorage.Delete(ctx, characterID, appcontext.Actor(ctx))
+	if err != nil {
+		err.Path = ".CharacterService->DeleteCharacter()" + err.Path
+		return err
+	}
+
+	err = s.auditService.Record(ctx, &audit.Change{
+		Entity:   audit.EntityCharacter,
+		EntityID: characterID,
+		Action:   audit.ActionDelete,
+		Before:   character,
+	})
+	if err != nil {
+		err.Path = ".CharacterService->DeleteCharacter()" + err.Path
+		return err
+	}
+
+	return nil
+}
+
+// RestoreCharacter restores a soft deleted character
+func (s *Service) RestoreCharacter(ctx context.Context, characterID int) (*Characters, *types.Error) {
+	ctx, span := tracer.Start(ctx, "CharacterService.RestoreCharacter")
+	defer span.End()
+
+	deleted, err := s.characterStorage.FindDeletedByID(ctx, characterID)
+	if err != nil {
+		err.Path = ".CharacterService->RestoreCharacter()" + err.Path
+		return nil, err
+	}
+
+	// the exact name, case insensitively, as the unique index on LOWER("name") compares them
+	characters, _, err := s.ListCharacters(ctx, &FindAllCharacterParams{
+		Names: []string{deleted.Name},
+	})
+	if err != nil {
+		err.Path = ".CharacterService->RestoreCharacter()" + err.Path
+		return nil, err
+	}
+	if len(characters) > 0 {
+		return nil, &types.Error{
+			Path:    ".CharacterService->RestoreCharacter()",
+			Message: ErrCharacterExists.Error(),
+			Error:   ErrCharacterExists,
+			Type:    "validation-error",
+		}
+	}
+
+	err = s.characterStorage.Restore(ctx, characterID)
+	if err != nil {
+		err.Path = ".CharacterService->RestoreCharacter()" + err.Path
+		return nil, err
+	}
+
+	character, err := s.GetCharacter(ctx, characterID)
+	if err != nil {
+		err.Path = ".CharacterService->RestoreCharacter()" + err.Path
+		return nil, err
+	}
+	err = s.calculateValues(ctx, []*Characters{deleted})
+	if err != nil {
+		err.Path = ".CharacterService->RestoreCharacter()" + err.Path
+		return nil, err
+	}
+
+	err = s.auditService.Record(ctx, &audit.Change{
+		Entity:   audit.EntityCharacter,
+		EntityID: characterID,
+		Action:   audit.ActionRestore,
+		Before:   deleted,
+		After:    character,
+	})
+	if err != nil {
+		err.Path = ".CharacterService->RestoreCharacter()" + err.Path
+		return nil, err
+	}
 
 	return character, nil
 }
@@ -215,8 +509,12 @@ func (s *Service) UpdateCharacter(ctx context.Context, characterID int, params *
 // NewService creates a new character AppService
 func NewService(
 	characterStorage Storage,
+	characterTypeStorage charactertype.Storage,
+	auditService audit.ServiceInterface,
 ) *Service {
 	return &Service{
-		characterStorage: characterStorage,
+		characterStorage:     characterStorage,
+		characterTypeStorage: characterTypeStorage,
+		auditService:         auditService,
 	}
 }
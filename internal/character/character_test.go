@@ -0,0 +1,241 @@
+package character
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/riskiramdan/evos/internal/audit"
+	"github.com/riskiramdan/evos/internal/charactertype"
+	"github.com/riskiramdan/evos/internal/data"
+	"github.com/riskiramdan/evos/internal/types"
+)
+
+// memStorage keeps the characters in memory, filtering them by id, exact names & deletion
+type memStorage struct {
+	Storage
+	characters map[int]*Characters
+}
+
+func (s *memStorage) FindAll(ctx context.Context, params *FindAllCharacterParams) ([]*Characters, *types.Error) {
+	names := map[string]bool{}
+	for _, n := range params.Names {
+		names[strings.ToLower(n)] = true
+	}
+	characters := []*Characters{}
+	for _, c := range s.characters {
+		if (c.DeletedAt != nil) != params.Deleted {
+			continue
+		}
+		if params.ID != 0 && c.ID != params.ID {
+			continue
+		}
+		if params.Name != "" && !strings.Contains(strings.ToLower(c.Name), strings.ToLower(params.Name)) {
+			continue
+		}
+		if len(names) > 0 && !names[strings.ToLower(c.Name)] {
+			continue
+		}
+		copied := *c
+		characters = append(characters, &copied)
+	}
+	return characters, nil
+}
+
+func (s *memStorage) find(characterID int, deleted bool) (*Characters, *types.Error) {
+	c, ok := s.characters[characterID]
+	if !ok || (c.DeletedAt != nil) != deleted {
+		return nil, &types.Error{Path: ".memStorage->find()", Message: data.ErrNotFound.Error(), Error: data.ErrNotFound}
+	}
+	copied := *c
+	return &copied, nil
+}
+
+func (s *memStorage) FindByID(ctx context.Context, characterID int) (*Characters, *types.Error) {
+	return s.find(characterID, false)
+}
+
+func (s *memStorage) FindDeletedByID(ctx context.Context, characterID int) (*Characters, *types.Error) {
+	return s.find(characterID, true)
+}
+
+func (s *memStorage) Update(ctx context.Context, character *Characters) (*Characters, *types.Error) {
+	if s.characters[character.ID].Version != character.Version {
+		return nil, &types.Error{Path: ".memStorage->Update()", Message: data.ErrConflict.Error(), Error: data.ErrConflict}
+	}
+	character.Version++
+	copied := *character
+	s.characters[character.ID] = &copied
+	return character, nil
+}
+
+func (s *memStorage) Restore(ctx context.Context, characterID int) *types.Error {
+	s.characters[characterID].DeletedAt = nil
+	return nil
+}
+
+// memTypeStorage keeps the character types in memory
+type memTypeStorage struct {
+	charactertype.Storage
+	characterTypes map[int]*charactertype.CharacterTypes
+}
+
+func (s *memTypeStorage) FindAll(ctx context.Context, params *charactertype.FindAllCharacterTypeParams) ([]*charactertype.CharacterTypes, *types.Error) {
+	characterTypes := []*charactertype.CharacterTypes{}
+	for _, id := range params.IDs {
+		if t, ok := s.characterTypes[id]; ok {
+			characterTypes = append(characterTypes, t)
+		}
+	}
+	return characterTypes, nil
+}
+
+func (s *memTypeStorage) FindByID(ctx context.Context, characterTypeID int) (*charactertype.CharacterTypes, *types.Error) {
+	t, ok := s.characterTypes[characterTypeID]
+	if !ok {
+		return nil, &types.Error{Path: ".memTypeStorage->FindByID()", Message: data.ErrNotFound.Error(), Error: data.ErrNotFound}
+	}
+	return t, nil
+}
+
+type nopAudit struct {
+	audit.ServiceInterface
+}
+
+func (a *nopAudit) Record(ctx context.Context, changes ...*audit.Change) *types.Error {
+	return nil
+}
+
+func newTestService(characters ...*Characters) (*Service, *memStorage) {
+	storage := &memStorage{characters: map[int]*Characters{}}
+	for _, c := range characters {
+		storage.characters[c.ID] = c
+	}
+	typeStorage := &memTypeStorage{characterTypes: map[int]*charactertype.CharacterTypes{
+		1: {ID: 1, Name: "Wizard", Multiplier: 150},
+		2: {ID: 2, Name: "Elf", Multiplier: 110},
+	}}
+	return NewService(storage, typeStorage, &nopAudit{}), storage
+}
+
+func isError(err *types.Error, target error) bool {
+	return err != nil && errors.Is(err.Error, target)
+}
+
+// A live character whose name only contains the one of the deleted character doesn't block its restore
+func TestRestoreCharacterSimilarName(t *testing.T) {
+	now := time.Now()
+	s, _ := newTestService(
+		&Characters{ID: 1, Name: "Gandalf", CharacterTypeID: 1, Version: 1},
+		&Characters{ID: 2, Name: "Gan", CharacterTypeID: 1, Version: 1, DeletedAt: &now},
+	)
+
+	character, err := s.RestoreCharacter(context.Background(), 2)
+	if err != nil {
+		t.Fatal(err.Error)
+	}
+	if character.Name != "Gan" {
+		t.Errorf("got %s", character.Name)
+	}
+}
+
+func TestRestoreCharacterTakenName(t *testing.T) {
+	now := time.Now()
+	s, _ := newTestService(
+		&Characters{ID: 1, Name: "gandalf", CharacterTypeID: 1, Version: 1},
+		&Characters{ID: 2, Name: "Gandalf", CharacterTypeID: 1, Version: 1, DeletedAt: &now},
+	)
+
+	_, err := s.RestoreCharacter(context.Background(), 2)
+	if !isError(err, ErrCharacterExists) {
+		t.Fatalf("got %v, want ErrCharacterExists", err)
+	}
+}
+
+func TestUpdateCharacterType(t *testing.T) {
+	s, storage := newTestService(&Characters{ID: 1, Name: "Legolas", CharacterTypeID: 1, Power: 100, Version: 1})
+
+	character, err := s.UpdateCharacter(context.Background(), 1, &TransactionParams{CharacterTypeID: 2})
+	if err != nil {
+		t.Fatal(err.Error)
+	}
+	if character.CharacterTypeID != 2 || storage.characters[1].CharacterTypeID != 2 {
+		t.Fatalf("got character type %d, want 2", character.CharacterTypeID)
+	}
+	// the value follows the formula of the new type
+	if character.Value != (&charactertype.CharacterTypes{Multiplier: 110}).CalculateValue(100) {
+		t.Errorf("got value %d", character.Value)
+	}
+}
+
+func TestUpdateCharacterUnknownType(t *testing.T) {
+	s, storage := newTestService(&Characters{ID: 1, Name: "Legolas", CharacterTypeID: 1, Power: 100, Version: 1})
+
+	_, err := s.UpdateCharacter(context.Background(), 1, &TransactionParams{CharacterTypeID: 9})
+	if !isError(err, ErrInvalidCharacterType) {
+		t.Fatalf("got %v, want ErrInvalidCharacterType", err)
+	}
+	if storage.characters[1].CharacterTypeID != 1 {
+		t.Error("the character is updated")
+	}
+}
+
+// A client sending the character it read back, its version included, updates it
+func TestUpdateCharacterCurrentName(t *testing.T) {
+	s, _ := newTestService(&Characters{ID: 1, Name: "Gandalf", CharacterTypeID: 1, Power: 100, Version: 3})
+
+	power, version := 200, 3
+	character, err := s.UpdateCharacter(context.Background(), 1, &TransactionParams{
+		Name:            "Gandalf",
+		CharacterTypeID: 1,
+		Power:           &power,
+		Version:         &version,
+	})
+	if err != nil {
+		t.Fatal(err.Error)
+	}
+	if character.Power != 200 || character.Version != 4 {
+		t.Errorf("got power %d, version %d", character.Power, character.Version)
+	}
+}
+
+func TestUpdateCharacterNameCase(t *testing.T) {
+	s, _ := newTestService(&Characters{ID: 1, Name: "gandalf", CharacterTypeID: 1, Version: 1})
+
+	character, err := s.UpdateCharacter(context.Background(), 1, &TransactionParams{Name: "Gandalf"})
+	if err != nil {
+		t.Fatal(err.Error)
+	}
+	if character.Name != "Gandalf" {
+		t.Errorf("got %s", character.Name)
+	}
+}
+
+// A name contained in the one of another character is free
+func TestUpdateCharacterSimilarName(t *testing.T) {
+	s, _ := newTestService(
+		&Characters{ID: 1, Name: "Gandalf", CharacterTypeID: 1, Version: 1},
+		&Characters{ID: 2, Name: "Gandalf2", CharacterTypeID: 1, Version: 1},
+	)
+
+	if _, err := s.UpdateCharacter(context.Background(), 2, &TransactionParams{Name: "Gan"}); err != nil {
+		t.Fatal(err.Error)
+	}
+}
+
+func TestUpdateCharacterTakenName(t *testing.T) {
+	s, _ := newTestService(
+		&Characters{ID: 1, Name: "Gandalf", CharacterTypeID: 1, Version: 1},
+		&Characters{ID: 2, Name: "Gandalf2", CharacterTypeID: 1, Version: 1},
+	)
+
+	_, err := s.UpdateCharacter(context.Background(), 2, &TransactionParams{Name: "GANDALF"})
+	if !isError(err, data.ErrAlreadyExist) {
+		t.Fatalf("got %v, want ErrAlreadyExist", err)
+	}
+	if err.Path != ".CharacterService->UpdateCharacter()" {
+		t.Errorf("got path %s", err.Path)
+	}
+}
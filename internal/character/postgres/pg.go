@@ -3,6 +3,7 @@ package postgres
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/riskiramdan/evos/internal/character"
 	"github.com/riskiramdan/evos/internal/data"
@@ -14,30 +15,127 @@ type Storage struct {
 	Storage data.GenericStorage
 }
 
-// FindAll find all characters
-func (s *Storage) FindAll(ctx context.Context, params *character.FindAllCharacterParams) ([]*character.Characters, *types.Error) {
+// valueColumn calculates the character value in SQL the same way as
+// charactertype.CalculateValue, so the characters can be filtered & sorted by value
+const valueColumn = `COALESCE((
+	SELECT ct."flatBonus" + CAST("characters"."power" AS bigint) * COALESCE((
+		SELECT CAST(t->>'multiplier' AS int)
+		FROM jsonb_array_elements(ct."thresholds") t
+		WHERE "characters"."power" < CAST(t->>'powerBelow' AS int)
+		ORDER BY CAST(t->>'powerBelow' AS int) ASC
+		LIMIT 1
+	), ct."multiplier") / 100
+	FROM "charactersType" ct
+	WHERE ct."id" = "characters"."characterTypeID" AND ct."deletedAt" IS NULL
+), 0)`
 
-	characters := []*character.Characters{}
+// sortColumns maps the sort fields to their SQL expression
+var sortColumns = map[string]string{
+	"id":              `"id"`,
+	"name":            `"name"`,
+	"power":           `"power"`,
+	"value":           valueColumn,
+	"characterTypeID": `"characterTypeID"`,
+	"createdAt":       `"createdAt"`,
+	"updatedAt":       `COALESCE("updatedAt", "createdAt")`,
+}
+
+func filter(params *character.FindAllCharacterParams) (string, map[string]interface{}) {
 	where := `"deletedAt" IS NULL`
+	if params.Deleted {
+		where = `"deletedAt" IS NOT NULL`
+	}
+	arg := map[string]interface{}{}
 
 	if params.ID != 0 {
 		where += ` AND "id" = :id`
+		arg["id"] = params.ID
 	}
 	if params.Name != "" {
 		where += ` AND "name" ILIKE :name`
+		arg["name"] = "%" + params.Name + "%"
+	}
+	if len(params.Names) > 0 {
+		names := []string{}
+		for _, n := range params.Names {
+			names = append(names, strings.ToLower(n))
+		}
+		where += ` AND LOWER("name") IN (:names)`
+		arg["names"] = names
+	}
+	if len(params.CharacterTypeIDs) > 0 {
+		where += ` AND "characterTypeID" IN (:characterTypeIds)`
+		arg["characterTypeIds"] = params.CharacterTypeIDs
+	}
+	if params.PowerMin != nil {
+		where += ` AND "power" >= :powerMin`
+		arg["powerMin"] = *params.PowerMin
+	}
+	if params.PowerMax != nil {
+		where += ` AND "power" <= :powerMax`
+		arg["powerMax"] = *params.PowerMax
+	}
+	if params.ValueMin != nil {
+		where += ` AND ` + valueColumn + ` >= :valueMin`
+		arg["valueMin"] = *params.ValueMin
 	}
-	if params.Page != 0 && params.Limit != 0 {
-		where = fmt.Sprintf(`%s ORDER BY "createdAt" DESC LIMIT :limit OFFSET :offset`, where)
-	} else {
-		where = fmt.Sprintf(`%s ORDER BY "createdAt" DESC`, where)
+	if params.ValueMax != nil {
+		where += ` AND ` + valueColumn + ` <= :valueMax`
+		arg["valueMax"] = *params.ValueMax
+	}
+	if params.CreatedFrom != nil {
+		where += ` AND "createdAt" >= :createdFrom`
+		arg["createdFrom"] = *params.CreatedFrom
+	}
+	if params.CreatedTo != nil {
+		where += ` AND "createdAt" < :createdTo`
+		arg["createdTo"] = *params.CreatedTo
+	}
+	if params.UpdatedFrom != nil {
+		where += ` AND "updatedAt" >= :updatedFrom`
+		arg["updatedFrom"] = *params.UpdatedFrom
+	}
+	if params.UpdatedTo != nil {
+		where += ` AND "updatedAt" < :updatedTo`
+		arg["updatedTo"] = *params.UpdatedTo
+	}
+	if params.CreatedBy != "" {
+		where += ` AND "createdBy" = :createdBy`
+		arg["createdBy"] = params.CreatedBy
 	}
 
-	err := s.Storage.Where(ctx, &characters, where, map[string]interface{}{
-		"id":     params.ID,
-		"limit":  params.Limit,
-		"name":   "%" + params.Name + "%",
-		"offset": ((params.Page - 1) * params.Limit),
-	})
+	return where, arg
+}
+
+// FindAll find all characters
+func (s *Storage) FindAll(ctx context.Context, params *character.FindAllCharacterParams) ([]*character.Characters, *types.Error) {
+
+	characters := []*character.Characters{}
+	where, arg := filter(params)
+
+	sort := params.Sort
+	if len(sort) == 0 {
+		sort = data.DefaultSort
+	}
+	if params.After != nil {
+		after, err := sort.After(sortColumns, params.After, arg)
+		if err != nil {
+			return nil, &types.Error{
+				Path:    ".CharacterStorage->FindAll()",
+				Message: err.Error(),
+				Error:   err,
+				Type:    "validation-error",
+			}
+		}
+		where += ` AND ` + after
+	}
+	where = fmt.Sprintf(`%s %s`, where, sort.Order(sortColumns))
+	if params.Limit != 0 {
+		where += ` LIMIT :limit`
+		arg["limit"] = params.Limit
+	}
+
+	err := s.Storage.Where(ctx, &characters, where, arg)
 	if err != nil {
 		return nil, &types.Error{
 			Path:    ".CharacterStorage->FindAll()",
@@ -50,6 +148,23 @@ func (s *Storage) FindAll(ctx context.Context, params *character.FindAllCharacte
 	return characters, nil
 }
 
+// Count count the characters matching the params, ignoring the pagination
+func (s *Storage) Count(ctx context.Context, params *character.FindAllCharacterParams) (int, *types.Error) {
+	where, arg := filter(params)
+
+	count, err := s.Storage.Count(ctx, where, arg)
+	if err != nil {
+		return 0, &types.Error{
+			Path:    ".CharacterStorage->Count()",
+			Message: err.Error(),
+			Error:   err,
+			Type:    "pq-error",
+		}
+	}
+
+	return count, nil
+}
+
 // FindByID find character by its id
 func (s *Storage) FindByID(ctx context.Context, characterID int) (*character.Characters, *types.Error) {
 	characters, err := s.FindAll(ctx, &character.FindAllCharacterParams{
@@ -102,9 +217,32 @@ func (s *Storage) Update(ctx context.Context, character *character.Characters) (
 	return character, nil
 }
 
+// FindDeletedByID find a soft deleted character by its id
+func (s *Storage) FindDeletedByID(ctx context.Context, characterID int) (*character.Characters, *types.Error) {
+	characters, err := s.FindAll(ctx, &character.FindAllCharacterParams{
+		ID:      characterID,
+		Deleted: true,
+	})
+	if err != nil {
+		err.Path = ".CharacterStorage->FindDeletedByID()" + err.Path
+		return nil, err
+	}
+
+	if len(characters) < 1 || characters[0].ID != characterID {
+		return nil, &types.Error{
+			Path:    ".CharacterStorage->FindDeletedByID()",
+			Message: data.ErrNotFound.Error(),
+			Error:   data.ErrNotFound,
+			Type:    "pq-error",
+		}
+	}
+
+	return characters[0], nil
+}
+
 // Delete delete a character
-func (s *Storage) Delete(ctx context.Context, characterID int) *types.Error {
-	err := s.Storage.Delete(ctx, characterID)
+func (s *Storage) Delete(ctx context.Context, characterID int, deletedBy string) *types.Error {
+	err := s.Storage.Delete(ctx, characterID, deletedBy)
 	if err != nil {
 		return &types.Error{
 			Path:    ".CharacterStorage->Delete()",
@@ -117,6 +255,56 @@ func (s *Storage) Delete(ctx context.Context, characterID int) *types.Error {
 	return nil
 }
 
+// Restore restore a soft deleted character
+func (s *Storage) Restore(ctx context.Context, characterID int) *types.Error {
+	err := s.Storage.Restore(ctx, characterID)
+	if err != nil {
+		return &types.Error{
+			Path:    ".CharacterStorage->Restore()",
+			Message: err.Error(),
+			Error:   err,
+			Type:    "pq-error",
+		}
+	}
+
+	return nil
+}
+
+// InsertMany insert characters in bulk
+func (s *Storage) InsertMany(ctx context.Context, characters []*character.Characters) *types.Error {
+	err := s.Storage.InsertMany(ctx, characters, "name")
+	if err != nil {
+		return &types.Error{
+			Path:    ".CharacterStorage->InsertMany()",
+			Message: err.Error(),
+			Error:   err,
+			Type:    "pq-error",
+		}
+	}
+
+	return nil
+}
+
+// UpsertMany insert characters in bulk, updating the type & power
+// of the existing characters with the same name
+func (s *Storage) UpsertMany(ctx context.Context, characters []*character.Characters) *types.Error {
+	err := s.Storage.UpsertMany(ctx, characters,
+		`(LOWER("name")) WHERE "deletedAt" IS NULL`,
+		[]string{"characterTypeID", "power", "updatedAt", "updatedBy"},
+		"name",
+	)
+	if err != nil {
+		return &types.Error{
+			Path:    ".CharacterStorage->UpsertMany()",
+			Message: err.Error(),
+			Error:   err,
+			Type:    "pq-error",
+		}
+	}
+
+	return nil
+}
+
 // NewPostgresStorage creates new character repository service
 func NewPostgresStorage(
 	storage data.GenericStorage,
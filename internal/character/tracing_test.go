@@ -0,0 +1,60 @@
+package character
+
+import (
+	"context"
+	"testing"
+
+	"github.com/riskiramdan/evos/internal/charactertype"
+	"github.com/riskiramdan/evos/internal/tracing/tracingtest"
+	"github.com/riskiramdan/evos/internal/types"
+
+	"go.opentelemetry.io/otel"
+)
+
+type fakeStorage struct {
+	Storage
+}
+
+func (s *fakeStorage) FindByID(ctx context.Context, characterID int) (*Characters, *types.Error) {
+	return &Characters{ID: characterID, Name: "Thor", CharacterTypeID: 1, Power: 10}, nil
+}
+
+type fakeTypeStorage struct {
+	charactertype.Storage
+}
+
+func (s *fakeTypeStorage) FindAll(ctx context.Context, params *charactertype.FindAllCharacterTypeParams) ([]*charactertype.CharacterTypes, *types.Error) {
+	return []*charactertype.CharacterTypes{{ID: 1, Multiplier: 150}}, nil
+}
+
+func TestServiceSpans(t *testing.T) {
+	spans := tracingtest.Record()
+	s := NewService(&fakeStorage{}, &fakeTypeStorage{}, nil)
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "GET /character/{characterId}")
+	_, err := s.GetCharacter(ctx, 9999)
+	parent.End()
+	if err != nil {
+		t.Fatal(err.Error)
+	}
+
+	ended := spans()
+	get := tracingtest.Find(ended, "CharacterService.GetCharacter")
+	if get == nil {
+		t.Fatal("no GetCharacter span")
+	}
+	if get.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Error("the service span is not a child of the request span")
+	}
+
+	values := tracingtest.Find(ended, "CharacterService.calculateValues")
+	if values == nil {
+		t.Fatal("no calculateValues span")
+	}
+	if values.Parent().SpanID() != get.SpanContext().SpanID() {
+		t.Error("the calculateValues span is not a child of the GetCharacter span")
+	}
+	if got := tracingtest.Attribute(values, "characters"); got != "1" {
+		t.Errorf("got characters %q, want 1", got)
+	}
+}
@@ -0,0 +1,346 @@
+package charactertype
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/riskiramdan/evos/internal/appcontext"
+	"github.com/riskiramdan/evos/internal/audit"
+	"github.com/riskiramdan/evos/internal/data"
+	"github.com/riskiramdan/evos/internal/types"
+)
+
+// Errors
+var (
+	ErrCharacterTypeExists = types.NewDomainError(types.KindConflict, "CharacterTypeExists", "Character type already exists")
+	ErrInvalidMultiplier   = types.NewDomainError(types.KindValidation, "InvalidMultiplier", "Invalid multiplier")
+	ErrInvalidThreshold    = types.NewDomainError(types.KindValidation, "InvalidThreshold", "Invalid threshold")
+)
+
+// DefaultMultiplier is the multiplier (in percent) used when none is given
+const DefaultMultiplier = 100
+
+// Threshold represents an alternate multiplier applied
+// when the character power is below PowerBelow
+type Threshold struct {
+	PowerBelow int `json:"powerBelow"`
+	Multiplier int `json:"multiplier"`
+}
+
+// Thresholds is an ADT to store the power thresholds as JSONB
+type Thresholds []Threshold
+
+// Value override value's function for Thresholds (ADT) type
+func (t Thresholds) Value() (driver.Value, error) {
+	if t == nil {
+		t = Thresholds{}
+	}
+	j, err := json.Marshal(t)
+	return string(j), err
+}
+
+// Scan override scan's function for Thresholds (ADT) type
+func (t *Thresholds) Scan(src interface{}) error {
+	var source []byte
+	switch v := src.(type) {
+	case []byte:
+		source = v
+	case string:
+		source = []byte(v)
+	case nil:
+		*t = Thresholds{}
+		return nil
+	default:
+		return errors.New("Type assertion .([]byte) failed")
+	}
+
+	return json.Unmarshal(source, t)
+}
+
+// CharacterTypes character type
+type CharacterTypes struct {
+	ID         int        `json:"id" db:"id"`
+	Name       string     `json:"name" db:"name"`
+	Code       int        `json:"code" db:"code"`
+	Multiplier int        `json:"multiplier" db:"multiplier"`
+	FlatBonus  int        `json:"flatBonus" db:"flatBonus"`
+	Thresholds Thresholds `json:"thresholds" db:"thresholds"`
+	CreatedAt  time.Time  `json:"createdAt" db:"createdAt"`
+	CreatedBy  string     `json:"createdBy" db:"createdBy"`
+	UpdatedAt  *time.Time `json:"updatedAt" db:"updatedAt"`
+	UpdatedBy  string     `json:"updatedBy" db:"updatedBy"`
+}
+
+// CalculateValue calculates the value of a character with the given power.
+// The lowest threshold the power is below replaces the multiplier,
+// and the flat bonus is added on top of the percentage.
+func (c *CharacterTypes) CalculateValue(power int) int {
+	multiplier := c.Multiplier
+	thresholds := make(Thresholds, len(c.Thresholds))
+	copy(thresholds, c.Thresholds)
+	sort.Slice(thresholds, func(i, j int) bool {
+		return thresholds[i].PowerBelow < thresholds[j].PowerBelow
+	})
+	for _, t := range thresholds {
+		if power < t.PowerBelow {
+			multiplier = t.Multiplier
+			break
+		}
+	}
+
+	return c.FlatBonus + calculatePercentage(power, multiplier)
+}
+
+func calculatePercentage(value, amount int) int {
+	return value * amount / 100
+}
+
+// FindAllCharacterTypeParams params for find all
+type FindAllCharacterTypeParams struct {
+	ID    int    `json:"id"`
+	IDs   []int  `json:"ids"`
+	Page  int    `json:"page"`
+	Limit int    `json:"limit"`
+	Name  string `json:"name"`
+}
+
+// TransactionParams params for transaction
+type TransactionParams struct {
+	Name       string      `json:"name" validate:"required,max=80"`
+	Code       *int        `json:"code,omitempty" validate:"omitempty,min=0"`
+	Multiplier *int        `json:"multiplier,omitempty" validate:"omitempty,min=0"`
+	FlatBonus  *int        `json:"flatBonus,omitempty"`
+	Thresholds *Thresholds `json:"thresholds,omitempty"`
+}
+
+// Storage represents the character type storage interface
+type Storage interface {
+	FindAll(ctx context.Context, params *FindAllCharacterTypeParams) ([]*CharacterTypes, *types.Error)
+	Count(ctx context.Context, params *FindAllCharacterTypeParams) (int, *types.Error)
+	FindByID(ctx context.Context, characterTypeID int) (*CharacterTypes, *types.Error)
+	FindByName(ctx context.Context, name string) (*CharacterTypes, *types.Error)
+	Insert(ctx context.Context, characterType *CharacterTypes) (*CharacterTypes, *types.Error)
+	Update(ctx context.Context, characterType *CharacterTypes) (*CharacterTypes, *types.Error)
+}
+
+// ServiceInterface represents the character type service interface
+type ServiceInterface interface {
+	ListCharacterTypes(ctx context.Context, params *FindAllCharacterTypeParams) ([]*CharacterTypes, int, *types.Error)
+	GetCharacterType(ctx context.Context, characterTypeID int) (*CharacterTypes, *types.Error)
+	CreateCharacterType(ctx context.Context, params *TransactionParams) (*CharacterTypes, *types.Error)
+	UpdateCharacterType(ctx context.Context, characterTypeID int, params *TransactionParams) (*CharacterTypes, *types.Error)
+}
+
+// Service is the domain logic implementation of character type Service interface
+type Service struct {
+	characterTypeStorage Storage
+	auditService         audit.ServiceInterface
+}
+
+func validateFormula(multiplier int, thresholds Thresholds) *types.Error {
+	if multiplier < 0 {
+		return &types.Error{
+			Path:    ".CharacterTypeService->validateFormula()",
+			Message: ErrInvalidMultiplier.Error(),
+			Error:   ErrInvalidMultiplier,
+			Type:    "validation-error",
+		}
+	}
+
+	powers := map[int]bool{}
+	for _, t := range thresholds {
+		if t.PowerBelow <= 0 || t.Multiplier < 0 || powers[t.PowerBelow] {
+			return &types.Error{
+				Path:    ".CharacterTypeService->validateFormula()",
+				Message: ErrInvalidThreshold.Error(),
+				Error:   ErrInvalidThreshold,
+				Type:    "validation-error",
+			}
+		}
+		powers[t.PowerBelow] = true
+	}
+
+	return nil
+}
+
+// ListCharacterTypes is listing character types
+func (s *Service) ListCharacterTypes(ctx context.Context, params *FindAllCharacterTypeParams) ([]*CharacterTypes, int, *types.Error) {
+	characterTypes, err := s.characterTypeStorage.FindAll(ctx, params)
+	if err != nil {
+		err.Path = ".CharacterTypeService->ListCharacterTypes()" + err.Path
+		return nil, 0, err
+	}
+	count, err := s.characterTypeStorage.Count(ctx, params)
+	if err != nil {
+		err.Path = ".CharacterTypeService->ListCharacterTypes()" + err.Path
+		return nil, 0, err
+	}
+
+	return characterTypes, count, nil
+}
+
+// GetCharacterType is get character type
+func (s *Service) GetCharacterType(ctx context.Context, characterTypeID int) (*CharacterTypes, *types.Error) {
+	characterType, err := s.characterTypeStorage.FindByID(ctx, characterTypeID)
+	if err != nil {
+		err.Path = ".CharacterTypeService->GetCharacterType()" + err.Path
+		return nil, err
+	}
+
+	return characterType, nil
+}
+
+func (s *Service) checkNameAvailable(ctx context.Context, name string, exceptID int) *types.Error {
+	existing, err := s.characterTypeStorage.FindByName(ctx, name)
+	if err != nil {
+		if errors.Is(err.Error, data.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	if existing.ID != exceptID {
+		return &types.Error{
+			Path:    ".CharacterTypeService->checkNameAvailable()",
+			Message: ErrCharacterTypeExists.Error(),
+			Error:   ErrCharacterTypeExists,
+			Type:    "validation-error",
+		}
+	}
+
+	return nil
+}
+
+// CreateCharacterType create character type
+func (s *Service) CreateCharacterType(ctx context.Context, params *TransactionParams) (*CharacterTypes, *types.Error) {
+	errType := s.checkNameAvailable(ctx, params.Name, 0)
+	if errType != nil {
+		errType.Path = ".CharacterTypeService->CreateCharacterType()" + errType.Path
+		return nil, errType
+	}
+
+	now := time.Now()
+	actor := appcontext.Actor(ctx)
+
+	characterType := &CharacterTypes{
+		Name:       params.Name,
+		Multiplier: DefaultMultiplier,
+		Thresholds: Thresholds{},
+		CreatedBy:  actor,
+		CreatedAt:  now,
+		UpdatedBy:  actor,
+		UpdatedAt:  &now,
+	}
+	if params.Code != nil {
+		characterType.Code = *params.Code
+	}
+	if params.Multiplier != nil {
+		characterType.Multiplier = *params.Multiplier
+	}
+	if params.FlatBonus != nil {
+		characterType.FlatBonus = *params.FlatBonus
+	}
+	if params.Thresholds != nil {
+		characterType.Thresholds = *params.Thresholds
+	}
+
+	errType = validateFormula(characterType.Multiplier, characterType.Thresholds)
+	if errType != nil {
+		errType.Path = ".CharacterTypeService->CreateCharacterType()" + errType.Path
+		return nil, errType
+	}
+
+	characterType, errType = s.characterTypeStorage.Insert(ctx, characterType)
+	if errType != nil {
+		errType.Path = ".CharacterTypeService->CreateCharacterType()" + errType.Path
+		return nil, errType
+	}
+
+	errType = s.auditService.Record(ctx, &audit.Change{
+		Entity:   audit.EntityCharacterType,
+		EntityID: characterType.ID,
+		Action:   audit.ActionCreate,
+		After:    characterType,
+	})
+	if errType != nil {
+		errType.Path = ".CharacterTypeService->CreateCharacterType()" + errType.Path
+		return nil, errType
+	}
+
+	return characterType, nil
+}
+
+// UpdateCharacterType update a character type
+func (s *Service) UpdateCharacterType(ctx context.Context, characterTypeID int, params *TransactionParams) (*CharacterTypes, *types.Error) {
+	characterType, err := s.GetCharacterType(ctx, characterTypeID)
+	if err != nil {
+		err.Path = ".CharacterTypeService->UpdateCharacterType()" + err.Path
+		return nil, err
+	}
+	before := *characterType
+
+	if params.Name != "" {
+		err = s.checkNameAvailable(ctx, params.Name, characterTypeID)
+		if err != nil {
+			err.Path = ".CharacterTypeService->UpdateCharacterType()" + err.Path
+			return nil, err
+		}
+		characterType.Name = params.Name
+	}
+	if params.Code != nil {
+		characterType.Code = *params.Code
+	}
+	if params.Multiplier != nil {
+		characterType.Multiplier = *params.Multiplier
+	}
+	if params.FlatBonus != nil {
+		characterType.FlatBonus = *params.FlatBonus
+	}
+	if params.Thresholds != nil {
+		characterType.Thresholds = *params.Thresholds
+	}
+
+	err = validateFormula(characterType.Multiplier, characterType.Thresholds)
+	if err != nil {
+		err.Path = ".CharacterTypeService->UpdateCharacterType()" + err.Path
+		return nil, err
+	}
+
+	now := time.Now()
+	characterType.UpdatedAt = &now
+	characterType.UpdatedBy = appcontext.Actor(ctx)
+
+	characterType, err = s.characterTypeStorage.Update(ctx, characterType)
+	if err != nil {
+		err.Path = ".CharacterTypeService->UpdateCharacterType()" + err.Path
+		return nil, err
+	}
+
+	err = s.auditService.Record(ctx, &audit.Change{
+		Entity:   audit.EntityCharacterType,
+		EntityID: characterType.ID,
+		Action:   audit.ActionUpdate,
+		Before:   &before,
+		After:    characterType,
+	})
+	if err != nil {
+		err.Path = ".CharacterTypeService->UpdateCharacterType()" + err.Path
+		return nil, err
+	}
+
+	return characterType, nil
+}
+
+// NewService creates a new character type AppService
+func NewService(
+	characterTypeStorage Storage,
+	auditService audit.ServiceInterface,
+) *Service {
+	return &Service{
+		characterTypeStorage: characterTypeStorage,
+		auditService:         auditService,
+	}
+}
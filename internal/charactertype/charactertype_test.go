@@ -0,0 +1,50 @@
+package charactertype
+
+import (
+	"context"
+	"testing"
+
+	"github.com/riskiramdan/evos/internal/types"
+)
+
+// pagedStorage holds total character types, counting the queries
+type pagedStorage struct {
+	Storage
+	total   int
+	findAll int
+	count   int
+}
+
+func (s *pagedStorage) FindAll(ctx context.Context, params *FindAllCharacterTypeParams) ([]*CharacterTypes, *types.Error) {
+	s.findAll++
+	characterTypes := []*CharacterTypes{}
+	for i := 0; i < params.Limit && (params.Page-1)*params.Limit+i < s.total; i++ {
+		characterTypes = append(characterTypes, &CharacterTypes{ID: (params.Page-1)*params.Limit + i + 1})
+	}
+	return characterTypes, nil
+}
+
+func (s *pagedStorage) Count(ctx context.Context, params *FindAllCharacterTypeParams) (int, *types.Error) {
+	s.count++
+	return s.total, nil
+}
+
+// The total is counted, not read as a listing of every character type
+func TestListCharacterTypesCounts(t *testing.T) {
+	storage := &pagedStorage{total: 25}
+	s := NewService(storage, nil)
+
+	characterTypes, total, err := s.ListCharacterTypes(context.Background(), &FindAllCharacterTypeParams{Page: 2, Limit: 10})
+	if err != nil {
+		t.Fatal(err.Error)
+	}
+	if len(characterTypes) != 10 || characterTypes[0].ID != 11 {
+		t.Errorf("got %d character types from %d", len(characterTypes), characterTypes[0].ID)
+	}
+	if total != 25 {
+		t.Errorf("got total %d, want 25", total)
+	}
+	if storage.findAll != 1 || storage.count != 1 {
+		t.Errorf("got %d listings & %d counts, want 1 of each", storage.findAll, storage.count)
+	}
+}
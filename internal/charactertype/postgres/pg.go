@@ -0,0 +1,162 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/riskiramdan/evos/internal/charactertype"
+	"github.com/riskiramdan/evos/internal/data"
+	"github.com/riskiramdan/evos/internal/types"
+)
+
+// Storage implements the character type storage service interface
+type Storage struct {
+	Storage data.GenericStorage
+}
+
+func filter(params *charactertype.FindAllCharacterTypeParams) (string, map[string]interface{}) {
+	where := `"deletedAt" IS NULL`
+
+	if params.ID != 0 {
+		where += ` AND "id" = :id`
+	}
+	if len(params.IDs) > 0 {
+		where += ` AND "id" IN (:ids)`
+	}
+	if params.Name != "" {
+		where += ` AND LOWER("name") = LOWER(:name)`
+	}
+
+	return where, map[string]interface{}{
+		"id":   params.ID,
+		"ids":  params.IDs,
+		"name": params.Name,
+	}
+}
+
+// FindAll find all character types
+func (s *Storage) FindAll(ctx context.Context, params *charactertype.FindAllCharacterTypeParams) ([]*charactertype.CharacterTypes, *types.Error) {
+
+	characterTypes := []*charactertype.CharacterTypes{}
+	where, arg := filter(params)
+
+	if params.Page != 0 && params.Limit != 0 {
+		where = fmt.Sprintf(`%s ORDER BY "id" ASC LIMIT :limit OFFSET :offset`, where)
+		arg["limit"] = params.Limit
+		arg["offset"] = (params.Page - 1) * params.Limit
+	} else {
+		where = fmt.Sprintf(`%s ORDER BY "id" ASC`, where)
+	}
+
+	err := s.Storage.Where(ctx, &characterTypes, where, arg)
+	if err != nil {
+		return nil, &types.Error{
+			Path:    ".CharacterTypeStorage->FindAll()",
+			Message: err.Error(),
+			Error:   err,
+			Type:    "pq-error",
+		}
+	}
+
+	return characterTypes, nil
+}
+
+// Count count the character types matching the params, ignoring the pagination
+func (s *Storage) Count(ctx context.Context, params *charactertype.FindAllCharacterTypeParams) (int, *types.Error) {
+	where, arg := filter(params)
+
+	count, err := s.Storage.Count(ctx, where, arg)
+	if err != nil {
+		return 0, &types.Error{
+			Path:    ".CharacterTypeStorage->Count()",
+			Message: err.Error(),
+			Error:   err,
+			Type:    "pq-error",
+		}
+	}
+
+	return count, nil
+}
+
+// FindByID find character type by its id
+func (s *Storage) FindByID(ctx context.Context, characterTypeID int) (*charactertype.CharacterTypes, *types.Error) {
+	characterTypes, err := s.FindAll(ctx, &charactertype.FindAllCharacterTypeParams{
+		ID: characterTypeID,
+	})
+	if err != nil {
+		err.Path = ".CharacterTypeStorage->FindByID()" + err.Path
+		return nil, err
+	}
+
+	if len(characterTypes) < 1 || characterTypes[0].ID != characterTypeID {
+		return nil, &types.Error{
+			Path:    ".CharacterTypeStorage->FindByID()",
+			Message: data.ErrNotFound.Error(),
+			Error:   data.ErrNotFound,
+			Type:    "pq-error",
+		}
+	}
+
+	return characterTypes[0], nil
+}
+
+// FindByName find character type by its name, case insensitive
+func (s *Storage) FindByName(ctx context.Context, name string) (*charactertype.CharacterTypes, *types.Error) {
+	characterTypes, err := s.FindAll(ctx, &charactertype.FindAllCharacterTypeParams{
+		Name: name,
+	})
+	if err != nil {
+		err.Path = ".CharacterTypeStorage->FindByName()" + err.Path
+		return nil, err
+	}
+
+	if len(characterTypes) < 1 {
+		return nil, &types.Error{
+			Path:    ".CharacterTypeStorage->FindByName()",
+			Message: data.ErrNotFound.Error(),
+			Error:   data.ErrNotFound,
+			Type:    "pq-error",
+		}
+	}
+
+	return characterTypes[0], nil
+}
+
+// Insert insert character type
+func (s *Storage) Insert(ctx context.Context, characterType *charactertype.CharacterTypes) (*charactertype.CharacterTypes, *types.Error) {
+	err := s.Storage.Insert(ctx, characterType)
+	if err != nil {
+		return nil, &types.Error{
+			Path:    ".CharacterTypeStorage->Insert()",
+			Message: err.Error(),
+			Error:   err,
+			Type:    "pq-error",
+		}
+	}
+
+	return characterType, nil
+}
+
+// Update update character type
+func (s *Storage) Update(ctx context.Context, characterType *charactertype.CharacterTypes) (*charactertype.CharacterTypes, *types.Error) {
+	err := s.Storage.Update(ctx, characterType)
+	if err != nil {
+		return nil, &types.Error{
+			Path:    ".CharacterTypeStorage->Update()",
+			Message: err.Error(),
+			Error:   err,
+			Type:    "pq-error",
+		}
+	}
+
+	return characterType, nil
+}
+
+// NewPostgresStorage creates new character type repository service
+func NewPostgresStorage(
+	storage data.GenericStorage,
+) *Storage {
+	return &Storage{
+		Storage: storage,
+	}
+}
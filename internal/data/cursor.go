@@ -0,0 +1,61 @@
+package data
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/riskiramdan/evos/internal/types"
+)
+
+// ErrInvalidCursor declare specific error for a cursor that can't be decoded
+var ErrInvalidCursor = types.NewDomainError(types.KindBadRequest, "InvalidCursor", "invalid cursor")
+
+// Cursor is the position of the last row of a page: the values of its sort fields and its id.
+// The clients only get its opaque encoded form.
+type Cursor struct {
+	Sort   string        `json:"s"`
+	Values []interface{} `json:"v"`
+	ID     int           `json:"i"`
+}
+
+// NewCursor creates the cursor positioned on the row with the given sort values & id
+func NewCursor(sort Sort, values []interface{}, id int) *Cursor {
+	return &Cursor{
+		Sort:   sort.String(),
+		Values: values,
+		ID:     id,
+	}
+}
+
+// Encode encodes the cursor as an url safe string
+func (c *Cursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor decodes a cursor encoded by Encode
+func DecodeCursor(s string) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	c := &Cursor{}
+	// numbers are kept as strings, so postgres casts them to the column type
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	err = decoder.Decode(c)
+	if err != nil || c.ID == 0 {
+		return nil, ErrInvalidCursor
+	}
+
+	return c, nil
+}
+
+// Page holds the cursor of the next page of a listing, empty on the last page,
+// and the total number of rows when it was asked for
+type Page struct {
+	NextCursor string
+	Total      *int
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/riskiramdan/evos/internal/tracing"
+
 	"github.com/jmoiron/sqlx"
 )
 
@@ -12,15 +14,20 @@ type Manager struct {
 	db *sqlx.DB
 }
 
-// RunInTransaction runs the f with the transaction queryable inside the context
-func (m *Manager) RunInTransaction(ctx context.Context, f func(tctx context.Context) error) error {
+// RunInTransaction runs the f with the transaction queryable inside the context,
+// then the AfterCommit hooks registered by f once committed
+func (m *Manager) RunInTransaction(ctx context.Context, f func(tctx context.Context) error) (err error) {
+	ctx, span := tracer.Start(ctx, "transaction")
+	defer func() { tracing.End(span, err) }()
+
 	tx, err := m.db.Beginx()
 	if err != nil {
 		tx.Rollback()
 		return fmt.Errorf("error when creating transction: %v", err)
 	}
 
-	ctx = NewContext(ctx, tx)
+	hooks := []func(){}
+	ctx = context.WithValue(NewContext(ctx, tx), afterCommitKey, &hooks)
 	err = f(ctx)
 	if err != nil {
 		tx.Rollback()
@@ -31,6 +38,9 @@ func (m *Manager) RunInTransaction(ctx context.Context, f func(tctx context.Cont
 	if err != nil {
 		return fmt.Errorf("error when committing transaction: %v", err)
 	}
+	for _, hook := range hooks {
+		hook()
+	}
 
 	return nil
 }
@@ -10,7 +10,8 @@ import (
 type key int
 
 const (
-	txKey key = 0
+	txKey          key = 0
+	afterCommitKey key = 1
 )
 
 // Queryer represents the database commands interface
@@ -33,3 +34,14 @@ func TxFromContext(ctx context.Context) (Queryer, bool) {
 	q, ok := ctx.Value(txKey).(Queryer)
 	return q, ok
 }
+
+// AfterCommit runs f once the transaction of the context is committed, it never runs
+// when the transaction is rolled back. Outside of a transaction f runs right away.
+func AfterCommit(ctx context.Context, f func()) {
+	hooks, ok := ctx.Value(afterCommitKey).(*[]func())
+	if !ok {
+		f()
+		return
+	}
+	*hooks = append(*hooks, f)
+}
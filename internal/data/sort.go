@@ -0,0 +1,107 @@
+package data
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/riskiramdan/evos/internal/types"
+)
+
+// ErrInvalidSort declare specific error for a sort on a field that is not allowed
+var ErrInvalidSort = types.NewDomainError(types.KindBadRequest, "InvalidSort", "invalid sort")
+
+// SortField is a field of a listing ordering
+type SortField struct {
+	Name string
+	Desc bool
+}
+
+// Sort is the ordering of a listing, the rows are always ordered
+// by "id" DESC last so the ordering is total and can be paginated
+type Sort []SortField
+
+// DefaultSort orders the newest rows first
+var DefaultSort = Sort{{Name: "createdAt", Desc: true}}
+
+// ParseSort parses a sort like "power,-name", a leading - sorts descending.
+// Only the allowed field names are accepted.
+func ParseSort(s string, allowed []string) (Sort, error) {
+	sort := Sort{}
+	seen := map[string]bool{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		field := SortField{Name: strings.TrimPrefix(part, "-"), Desc: strings.HasPrefix(part, "-")}
+		if !contains(allowed, field.Name) || seen[field.Name] {
+			return nil, ErrInvalidSort
+		}
+		seen[field.Name] = true
+		sort = append(sort, field)
+	}
+	return sort, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// String formats the sort as parsed by ParseSort
+func (s Sort) String() string {
+	parts := []string{}
+	for _, f := range s {
+		if f.Desc {
+			parts = append(parts, "-"+f.Name)
+		} else {
+			parts = append(parts, f.Name)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// Order returns the ORDER BY clause of the sort,
+// columns maps the field names to their SQL expression
+func (s Sort) Order(columns map[string]string) string {
+	parts := []string{}
+	for _, f := range s {
+		direction := "ASC"
+		if f.Desc {
+			direction = "DESC"
+		}
+		parts = append(parts, fmt.Sprintf("%s %s", columns[f.Name], direction))
+	}
+	parts = append(parts, `"id" DESC`)
+	return "ORDER BY " + strings.Join(parts, ", ")
+}
+
+// After returns the condition selecting the rows coming after the cursor in the sort order,
+// adding its arguments to arg. The cursor must have been built for the same sort.
+func (s Sort) After(columns map[string]string, c *Cursor, arg map[string]interface{}) (string, error) {
+	if c.Sort != s.String() || len(c.Values) != len(s) {
+		return "", ErrInvalidCursor
+	}
+
+	// (a > :a) OR (a = :a AND b < :b) OR ... OR (a = :a AND b = :b AND id < :id)
+	alternatives := []string{}
+	equals := []string{}
+	for i, f := range s {
+		param := fmt.Sprintf("cursor%d", i)
+		arg[param] = c.Values[i]
+
+		op := ">"
+		if f.Desc {
+			op = "<"
+		}
+		condition := append(append([]string{}, equals...), fmt.Sprintf("%s %s :%s", columns[f.Name], op, param))
+		alternatives = append(alternatives, "("+strings.Join(condition, " AND ")+")")
+		equals = append(equals, fmt.Sprintf("%s = :%s", columns[f.Name], param))
+	}
+	arg["cursorId"] = c.ID
+	condition := append(equals, `"id" < :cursorId`)
+	alternatives = append(alternatives, "("+strings.Join(condition, " AND ")+")")
+
+	return "(" + strings.Join(alternatives, " OR ") + ")", nil
+}
@@ -10,14 +10,20 @@ import (
 	"strings"
 	"time"
 
+	"github.com/riskiramdan/evos/internal/tracing"
+	"github.com/riskiramdan/evos/internal/types"
+
 	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 //ErrNotEnough declare specific error for Not Enough
 //ErrExisted declare specific error for data already exist
+//ErrConflict declare specific error for an update of an element changed since it was read
 var (
-	ErrNotFound     = fmt.Errorf("data is not found")
-	ErrAlreadyExist = fmt.Errorf("data already exists")
+	ErrNotFound     = types.NewDomainError(types.KindNotFound, "NotFound", "data is not found")
+	ErrAlreadyExist = types.NewDomainError(types.KindConflict, "AlreadyExists", "data already exists")
+	ErrConflict     = types.NewDomainError(types.KindConflict, "VersionConflict", "data has been modified")
 )
 
 // GenericStorage represents the generic Storage
@@ -26,12 +32,15 @@ type GenericStorage interface {
 	Single(ctx context.Context, elem interface{}, where string, arg map[string]interface{}) error
 	Where(ctx context.Context, elems interface{}, where string, arg map[string]interface{}) error
 	SelectWithQuery(ctx context.Context, elem interface{}, query string, args map[string]interface{}) error
+	Count(ctx context.Context, where string, arg map[string]interface{}) (int, error)
 	FindByID(ctx context.Context, elem interface{}, id interface{}) error
 	FindAll(ctx context.Context, elems interface{}, page int, limit int) error
 	Insert(ctx context.Context, elem interface{}) error
-	// InsertMany(ctx context.Context, elem interface{}) error
+	InsertMany(ctx context.Context, elems interface{}, key string) error
+	UpsertMany(ctx context.Context, elems interface{}, conflictTarget string, updateFields []string, key string) error
 	Update(ctx context.Context, elem interface{}) error
-	Delete(ctx context.Context, id interface{}) error
+	Delete(ctx context.Context, id interface{}, deletedBy string) error
+	Restore(ctx context.Context, id interface{}) error
 	DeleteHard(ctx context.Context, id interface{}) error
 }
 
@@ -44,18 +53,23 @@ type PostgresStorage struct {
 	insertFields    string
 	insertParams    string
 	updateSetFields string
+	versioned       bool
 }
 
 // Single queries an element according to the query & argument provided
-func (r *PostgresStorage) Single(ctx context.Context, elem interface{}, where string, arg map[string]interface{}) error {
+func (r *PostgresStorage) Single(ctx context.Context, elem interface{}, where string, arg map[string]interface{}) (err error) {
+	ctx, span := r.startSpan(ctx, "SELECT")
+	defer func() { tracing.End(span, err, ErrNotFound) }()
+
 	db := r.db
 	tx, ok := TxFromContext(ctx)
 	if ok {
 		db = tx
 	}
 
-	statement, err := db.PrepareNamed(fmt.Sprintf(`SELECT %s FROM "%s" WHERE %s`,
-		r.selectFields, r.tableName, where))
+	query := fmt.Sprintf(`SELECT %s FROM "%s" WHERE %s`, r.selectFields, r.tableName, where)
+	setStatement(span, query)
+	statement, err := db.PrepareNamed(query)
 	if err != nil {
 		return err
 	}
@@ -73,7 +87,10 @@ func (r *PostgresStorage) Single(ctx context.Context, elem interface{}, where st
 }
 
 // Where queries the elements according to the query & argument provided
-func (r *PostgresStorage) Where(ctx context.Context, elems interface{}, where string, arg map[string]interface{}) error {
+func (r *PostgresStorage) Where(ctx context.Context, elems interface{}, where string, arg map[string]interface{}) (err error) {
+	ctx, span := r.startSpan(ctx, "SELECT")
+	defer func() { tracing.End(span, err) }()
+
 	db := r.db
 	tx, ok := TxFromContext(ctx)
 	if ok {
@@ -81,6 +98,7 @@ func (r *PostgresStorage) Where(ctx context.Context, elems interface{}, where st
 	}
 
 	query := fmt.Sprintf(`SELECT %s FROM "%s" WHERE %s`, r.selectFields, r.tableName, where)
+	setStatement(span, query)
 	query, args, err := sqlx.Named(query, arg)
 	if err != nil {
 		return err
@@ -102,13 +120,18 @@ func (r *PostgresStorage) Where(ctx context.Context, elems interface{}, where st
 }
 
 // SelectWithQuery Customizable Query for Select
-func (r *PostgresStorage) SelectWithQuery(ctx context.Context, elems interface{}, query string, arg map[string]interface{}) error {
+func (r *PostgresStorage) SelectWithQuery(ctx context.Context, elems interface{}, query string, arg map[string]interface{}) (err error) {
+	ctx, span := r.startSpan(ctx, "SELECT")
+	defer func() { tracing.End(span, err) }()
+
 	db := r.db
 	tx, ok := TxFromContext(ctx)
 	if ok {
 		db = tx
 	}
 
+	setStatement(span, query)
+
 	query, args, err := sqlx.Named(query, arg)
 	if err != nil {
 		return err
@@ -129,6 +152,40 @@ func (r *PostgresStorage) SelectWithQuery(ctx context.Context, elems interface{}
 	return nil
 }
 
+// Count counts the elements matching the query & argument provided
+func (r *PostgresStorage) Count(ctx context.Context, where string, arg map[string]interface{}) (_ int, err error) {
+	ctx, span := r.startSpan(ctx, "SELECT")
+	defer func() { tracing.End(span, err) }()
+
+	db := r.db
+	tx, ok := TxFromContext(ctx)
+	if ok {
+		db = tx
+	}
+
+	query := fmt.Sprintf(`SELECT COUNT(*) FROM "%s" WHERE %s`, r.tableName, where)
+	setStatement(span, query)
+	query, args, err := sqlx.Named(query, arg)
+	if err != nil {
+		return 0, err
+	}
+
+	query, args, err = sqlx.In(query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	query = db.Rebind(query)
+
+	var count int
+	err = db.Get(&count, query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
 // FindByID finds an element by its id
 // it's defined in this project context that
 // the element id column in the db should be "id"
@@ -166,7 +223,10 @@ func (r *PostgresStorage) FindAll(ctx context.Context, elems interface{}, page i
 // It will set the "owner" field of the element with the current account in the context if exists.
 // It will set the "createdAt" and "updatedAt" fields with current time.
 // If immutable set true, it won't insert the updatedAt
-func (r *PostgresStorage) Insert(ctx context.Context, elem interface{}) error {
+func (r *PostgresStorage) Insert(ctx context.Context, elem interface{}) (err error) {
+	ctx, span := r.startSpan(ctx, "INSERT")
+	defer func() { tracing.End(span, err) }()
+
 	db := r.db
 	tx, ok := TxFromContext(ctx)
 	if ok {
@@ -177,6 +237,7 @@ func (r *PostgresStorage) Insert(ctx context.Context, elem interface{}) error {
 	INSERT INTO "%s"(%s)
 	VALUES (%s)
 	RETURNING %s`, r.tableName, r.insertFields, r.insertParams, r.selectFields)
+	setStatement(span, query)
 	statement, err := db.PrepareNamed(query)
 	if err != nil {
 		return err
@@ -192,95 +253,158 @@ func (r *PostgresStorage) Insert(ctx context.Context, elem interface{}) error {
 	return nil
 }
 
-// // InsertMany is function for creating many datas into specific table in database.
-// func (r *PostgresStorage) InsertMany(ctx context.Context, elem interface{}) error {
-// 	currentAccount := appcontext.CurrentAccount(ctx)
-// 	db := r.db
-// 	tx, ok := TxFromContext(ctx)
-// 	if ok {
-// 		db = tx
-// 	}
-
-// 	sqlStr := fmt.Sprintf(`
-// 	INSERT INTO "%s"(%s)
-// 	VALUES `, r.tableName, r.insertFields)
-
-// 	var dbArgs map[string]interface{}
-
-// 	datas := reflect.ValueOf(elem)
-
-// 	insertFields := strings.Split(r.insertFields, ",")
-// 	limit := 60000 / len(insertFields)
-// 	indexData := 0
-// 	if datas.Kind() == reflect.Slice {
-// 		for i := 0; i < datas.Len(); i++ {
-// 			sqlStr += fmt.Sprintf("(%s),", insertParams(r.elemType, r.isImmutable, i+1))
-// 			arg := r.insertArgs(currentAccount, 0, datas.Index(i), i+1)
-// 			if indexData == 0 {
-// 				dbArgs = arg
-// 			} else {
-// 				for k, v := range arg {
-// 					dbArgs[k] = v
-// 				}
-// 			}
-// 			indexData++
-// 			if indexData == limit {
-// 				err := r.insertData(ctx, sqlStr, dbArgs)
-// 				if err != nil {
-// 					return err
-// 				}
-
-// 				indexData = 0
-// 				sqlStr = fmt.Sprintf(`
-// 				INSERT INTO "%s"(%s)
-// 				VALUES `, r.tableName, r.insertFields)
-// 				dbArgs = map[string]interface{}{}
-// 			}
-// 		}
-// 	}
-
-// 	if datas.Kind() == reflect.Map {
-// 		for key, element := range datas.MapKeys() {
-// 			sqlStr += fmt.Sprintf("(%s),", insertParams(r.elemType, r.isImmutable, key+1))
-// 			arg := r.insertArgs(currentAccount, currentUserID, datas.MapIndex(element), key+1)
-// 			if indexData == 0 {
-// 				dbArgs = arg
-// 			} else {
-// 				for k, v := range arg {
-// 					dbArgs[k] = v
-// 				}
-// 			}
-// 			indexData++
-// 			if indexData == limit {
-// 				err := r.insertData(ctx, sqlStr, dbArgs)
-// 				if err != nil {
-// 					return err
-// 				}
-
-// 				indexData = 0
-// 				sqlStr = fmt.Sprintf(`
-// 				INSERT INTO "%s"(%s)
-// 				VALUES `, r.tableName, r.insertFields)
-// 				dbArgs = map[string]interface{}{}
-// 			}
-// 		}
-// 	}
-
-// 	sqlStr = strings.TrimSuffix(sqlStr, ",")
-
-// 	statement, err := db.PrepareNamed(sqlStr)
-// 	if err != nil {
-// 		return err
-// 	}
-// 	defer statement.Close()
-
-// 	_, err = statement.Exec(dbArgs)
-// 	if err != nil {
-// 		return err
-// 	}
-
-// 	return nil
-// }
+// maxParams is the maximum number of bind parameters in a postgres statement
+const maxParams = 65535
+
+// InsertMany inserts the elements of a slice, in as few statements as the postgres
+// parameter limit allows, and fills them back with the inserted rows.
+// The order of the returned rows is not guaranteed, they are matched back to the elements
+// on the key column, case insensitively, so its values must be unique among the elements.
+// The elements are not filled back without a key.
+// It runs in the transaction of the context if any, so a failing chunk rolls back all of them.
+func (r *PostgresStorage) InsertMany(ctx context.Context, elems interface{}, key string) error {
+	return r.insertMany(ctx, elems, "", key)
+}
+
+// UpsertMany is InsertMany updating the updateFields of the existing rows conflicting
+// with the elements on the conflict target, e.g. `("code")` or `(LOWER("name")) WHERE "deletedAt" IS NULL`
+func (r *PostgresStorage) UpsertMany(ctx context.Context, elems interface{}, conflictTarget string, updateFields []string, key string) error {
+	setFields := []string{}
+	for _, f := range updateFields {
+		setFields = append(setFields, fmt.Sprintf(`"%s" = EXCLUDED."%s"`, f, f))
+	}
+	if r.versioned {
+		setFields = append(setFields, fmt.Sprintf(`"version" = "%s"."version" + 1`, r.tableName))
+	}
+	return r.insertMany(ctx, elems, fmt.Sprintf(`ON CONFLICT %s DO UPDATE SET %s`, conflictTarget, strings.Join(setFields, ",")), key)
+}
+
+func (r *PostgresStorage) insertMany(ctx context.Context, elems interface{}, onConflict string, key string) (err error) {
+	ctx, span := r.startSpan(ctx, "INSERT")
+	defer func() { tracing.End(span, err) }()
+
+	db := r.db
+	tx, ok := TxFromContext(ctx)
+	if ok {
+		db = tx
+	}
+
+	datas := reflect.ValueOf(elems)
+	if datas.Kind() != reflect.Slice {
+		return fmt.Errorf("insert many expects a slice, got %s", datas.Kind())
+	}
+
+	fields := []int{}
+	keyField := -1
+	for i := 0; i < r.elemType.NumField(); i++ {
+		dbTag := r.elemType.Field(i).Tag.Get("db")
+		if writableTag(dbTag) {
+			fields = append(fields, i)
+		}
+		if key != "" && dbTag == key {
+			keyField = i
+		}
+	}
+	if key != "" && keyField < 0 {
+		return fmt.Errorf("insert many key %s is not a column of %s", key, r.tableName)
+	}
+	chunkSize := maxParams / len(fields)
+	span.SetAttributes(attribute.Int("db.rows", datas.Len()))
+
+	for start := 0; start < datas.Len(); start += chunkSize {
+		end := start + chunkSize
+		if end > datas.Len() {
+			end = datas.Len()
+		}
+
+		rows := []string{}
+		args := []interface{}{}
+		for i := start; i < end; i++ {
+			v := reflect.Indirect(datas.Index(i))
+			params := []string{}
+			for _, f := range fields {
+				args = append(args, insertValue(v.Field(f)))
+				params = append(params, fmt.Sprintf("$%d", len(args)))
+			}
+			rows = append(rows, fmt.Sprintf("(%s)", strings.Join(params, ",")))
+		}
+
+		query := fmt.Sprintf(`
+		INSERT INTO "%s"(%s)
+		VALUES %s %s
+		RETURNING %s`, r.tableName, r.insertFields, strings.Join(rows, ","), onConflict, r.selectFields)
+		if start == 0 {
+			setStatement(span, query)
+		}
+
+		inserted := reflect.New(reflect.SliceOf(r.elemType))
+		err := db.Select(inserted.Interface(), query, args...)
+		if err != nil {
+			return err
+		}
+		if keyField < 0 {
+			continue
+		}
+		err = fillBack(datas, start, end, inserted.Elem(), keyField)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// fillBack sets the elements of a chunk to the inserted rows with the same key
+func fillBack(datas reflect.Value, start int, end int, inserted reflect.Value, keyField int) error {
+	elems := map[string]reflect.Value{}
+	for i := start; i < end; i++ {
+		v := reflect.Indirect(datas.Index(i))
+		k := keyValue(v.Field(keyField))
+		if _, ok := elems[k]; ok {
+			return fmt.Errorf("insert many key %q is not unique", k)
+		}
+		elems[k] = v
+	}
+
+	for i := 0; i < inserted.Len(); i++ {
+		row := inserted.Index(i)
+		k := keyValue(row.Field(keyField))
+		v, ok := elems[k]
+		if !ok {
+			return fmt.Errorf("insert many returned an unknown key %q", k)
+		}
+		v.Set(row)
+		delete(elems, k)
+	}
+	if len(elems) > 0 {
+		return fmt.Errorf("insert many did not return %d of the rows", len(elems))
+	}
+
+	return nil
+}
+
+// keyValue returns the value of a key field, as compared by a LOWER() unique index
+func keyValue(field reflect.Value) string {
+	field = reflect.Indirect(field)
+	if !field.IsValid() {
+		return ""
+	}
+	return strings.ToLower(fmt.Sprint(field.Interface()))
+}
+
+// insertValue returns the value of a field as inserted in the database,
+// maps are stored as json
+func insertValue(field reflect.Value) interface{} {
+	var typeMapString map[string]interface{}
+	if field.Type() == reflect.TypeOf(typeMapString) {
+		metadataBytes, err := json.Marshal(field.Interface())
+		if err != nil {
+			return "{}"
+		}
+		return string(metadataBytes)
+	}
+	return field.Interface()
+}
 
 func (r *PostgresStorage) insertArgs(elem interface{}, index int) map[string]interface{} {
 	res := map[string]interface{}{}
@@ -296,20 +420,8 @@ func (r *PostgresStorage) insertArgs(elem interface{}, index int) map[string]int
 
 	for i := 0; i < v.NumField(); i++ {
 		dbTag := r.elemType.Field(i).Tag.Get("db")
-		if !idTag(dbTag) && !emptyTag(dbTag) {
-			var typeMapString map[string]interface{}
-			var val interface{}
-			if v.Field(i).Type() == reflect.TypeOf(typeMapString) {
-				metadataBytes, err := json.Marshal(v.Field(i).Interface())
-				if err != nil {
-					val = "{}"
-				} else {
-					val = string(metadataBytes)
-				}
-			} else {
-				val = v.Field(i).Interface()
-			}
-			res[dbTag] = val
+		if writableTag(dbTag) {
+			res[dbTag] = insertValue(v.Field(i))
 		}
 	}
 
@@ -333,7 +445,12 @@ func renamingKey(m map[string]interface{}, add string) map[string]interface{} {
 
 // Update updates the element in the database.
 // It will update the "updatedAt" field.
-func (r *PostgresStorage) Update(ctx context.Context, elem interface{}) error {
+// When the element has a "version" column, the update only applies if the row is still
+// at the version of the element, and bumps it. Otherwise ErrConflict is returned.
+func (r *PostgresStorage) Update(ctx context.Context, elem interface{}) (err error) {
+	ctx, span := r.startSpan(ctx, "UPDATE")
+	defer func() { tracing.End(span, err, ErrNotFound, ErrConflict) }()
+
 	db := r.db
 	tx, ok := TxFromContext(ctx)
 	if ok {
@@ -341,17 +458,26 @@ func (r *PostgresStorage) Update(ctx context.Context, elem interface{}) error {
 	}
 	id := r.findID(elem)
 	existingElem := reflect.New(r.elemType).Interface()
-	err := r.FindByID(ctx, existingElem, id)
+	err = r.FindByID(ctx, existingElem, id)
 
 	if err != nil {
 		return err
 	}
 
-	statement, err := db.PrepareNamed(fmt.Sprintf(`
-		UPDATE "%s" SET %s WHERE "id" = :id RETURNING %s`,
+	setFields := r.updateSetFields
+	where := `"id" = :id`
+	if r.versioned {
+		setFields += `,"version" = "version" + 1`
+		where += ` AND "version" = :version`
+	}
+	query := fmt.Sprintf(`
+		UPDATE "%s" SET %s WHERE %s RETURNING %s`,
 		r.tableName,
-		r.updateSetFields,
-		r.selectFields))
+		setFields,
+		where,
+		r.selectFields)
+	setStatement(span, query)
+	statement, err := db.PrepareNamed(query)
 	if err != nil {
 		return err
 	}
@@ -359,7 +485,13 @@ func (r *PostgresStorage) Update(ctx context.Context, elem interface{}) error {
 
 	updateArgs := r.updateArgs(existingElem, elem)
 	updateArgs["id"] = id
+	if r.versioned {
+		updateArgs["version"] = r.findVersion(elem)
+	}
 	err = statement.Get(elem, updateArgs)
+	if err == sql.ErrNoRows && r.versioned {
+		return ErrConflict
+	}
 	if err != nil {
 		return err
 	}
@@ -367,6 +499,17 @@ func (r *PostgresStorage) Update(ctx context.Context, elem interface{}) error {
 	return nil
 }
 
+// findVersion returns the "version" field of the element
+func (r *PostgresStorage) findVersion(elem interface{}) interface{} {
+	v := reflect.ValueOf(elem).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if versionTag(r.elemType.Field(i).Tag.Get("db")) {
+			return v.Field(i).Interface()
+		}
+	}
+	return nil
+}
+
 // it assumes the id column named "id"
 func (r *PostgresStorage) findID(elem interface{}) interface{} {
 	v := reflect.ValueOf(elem).Elem()
@@ -386,7 +529,7 @@ func (r *PostgresStorage) updateArgs(existingElem interface{}, elem interface{})
 	ev := reflect.ValueOf(existingElem).Elem()
 	for i := 0; i < ev.NumField(); i++ {
 		dbTag := r.elemType.Field(i).Tag.Get("db")
-		if !idTag(dbTag) && !emptyTag(dbTag) {
+		if writableTag(dbTag) {
 			var typeMapString map[string]interface{}
 			var val interface{}
 
@@ -408,15 +551,22 @@ func (r *PostgresStorage) updateArgs(existingElem interface{}, elem interface{})
 
 // Delete deletes the elem from database.
 // Delete not really deletes the elem from the db, but it will set the
-// "deletedAt" column to current time.
-func (r *PostgresStorage) Delete(ctx context.Context, id interface{}) error {
+// "deletedAt" column to current time and the "deletedBy" column to the actor.
+// It returns ErrNotFound when the elem does not exist or is already deleted.
+func (r *PostgresStorage) Delete(ctx context.Context, id interface{}, deletedBy string) (err error) {
+	ctx, span := r.startSpan(ctx, "UPDATE")
+	defer func() { tracing.End(span, err, ErrNotFound) }()
+
 	db := r.db
 	tx, ok := TxFromContext(ctx)
 	if ok {
 		db = tx
 	}
-	statement, err := db.PrepareNamed(fmt.Sprintf(`UPDATE "%s" SET "deletedAt" = :deletedAt WHERE "id" = :id RETURNING %s
-	`, r.tableName, r.selectFields))
+	query := fmt.Sprintf(`UPDATE "%s" SET "deletedAt" = :deletedAt, "deletedBy" = :deletedBy%s
+	WHERE "id" = :id AND "deletedAt" IS NULL
+	`, r.tableName, r.bumpVersion())
+	setStatement(span, query)
+	statement, err := db.PrepareNamed(query)
 	if err != nil {
 		return err
 	}
@@ -425,26 +575,82 @@ func (r *PostgresStorage) Delete(ctx context.Context, id interface{}) error {
 	deleteArgs := map[string]interface{}{
 		"id":        id,
 		"deletedAt": time.Now().UTC(),
+		"deletedBy": deletedBy,
 	}
 
-	_, err = statement.Exec(deleteArgs)
+	result, err := statement.Exec(deleteArgs)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+// Restore restores the soft deleted elem by clearing
+// the "deletedAt" & "deletedBy" columns.
+// It returns ErrNotFound when the elem does not exist or is not deleted.
+func (r *PostgresStorage) Restore(ctx context.Context, id interface{}) (err error) {
+	ctx, span := r.startSpan(ctx, "UPDATE")
+	defer func() { tracing.End(span, err, ErrNotFound) }()
+
+	db := r.db
+	tx, ok := TxFromContext(ctx)
+	if ok {
+		db = tx
+	}
+	query := fmt.Sprintf(`UPDATE "%s" SET "deletedAt" = NULL, "deletedBy" = NULL%s
+	WHERE "id" = :id AND "deletedAt" IS NOT NULL
+	`, r.tableName, r.bumpVersion())
+	setStatement(span, query)
+	statement, err := db.PrepareNamed(query)
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+
+	result, err := statement.Exec(map[string]interface{}{
+		"id": id,
+	})
 	if err != nil {
 		return err
 	}
+	return checkAffected(result)
+}
+
+// bumpVersion returns the SET clause bumping the version of a versioned row
+func (r *PostgresStorage) bumpVersion() string {
+	if !r.versioned {
+		return ""
+	}
+	return `, "version" = "version" + 1`
+}
+
+func checkAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
 	return nil
 }
 
 // DeleteHard hard delete the elem from database.
-func (r *PostgresStorage) DeleteHard(ctx context.Context, id interface{}) error {
+func (r *PostgresStorage) DeleteHard(ctx context.Context, id interface{}) (err error) {
+	ctx, span := r.startSpan(ctx, "DELETE")
+	defer func() { tracing.End(span, err) }()
+
 	db := r.db
 	tx, ok := TxFromContext(ctx)
 	if ok {
 		db = tx
 	}
 
-	statement, err := db.PrepareNamed(fmt.Sprintf(`
+	query := fmt.Sprintf(`
 		DELETE FROM "%s" WHERE "id" = :id
-	`, r.tableName))
+	`, r.tableName)
+	setStatement(span, query)
+	statement, err := db.PrepareNamed(query)
 	if err != nil {
 		return err
 	}
@@ -472,6 +678,7 @@ func NewPostgresStorage(db *sqlx.DB, tableName string, elem interface{}) *Postgr
 		insertFields:    insertFields(elemType),
 		insertParams:    insertParams(elemType, 0),
 		updateSetFields: updateSetFields(elemType),
+		versioned:       hasVersion(elemType),
 	}
 }
 
@@ -492,7 +699,7 @@ func insertFields(elemType reflect.Type) string {
 	for i := 0; i < elemType.NumField(); i++ {
 		field := elemType.Field(i)
 		dbTag := field.Tag.Get("db")
-		if !idTag(dbTag) && !emptyTag(dbTag) {
+		if writableTag(dbTag) {
 			dbFields = append(dbFields, fmt.Sprintf("\"%s\"", dbTag))
 		}
 	}
@@ -504,7 +711,7 @@ func insertParams(elemType reflect.Type, index int) string {
 	for i := 0; i < elemType.NumField(); i++ {
 		field := elemType.Field(i)
 		dbTag := field.Tag.Get("db")
-		if !idTag(dbTag) && !emptyTag(dbTag) {
+		if writableTag(dbTag) {
 			dbParams = append(dbParams, fmt.Sprintf(":%s", dbTag))
 		}
 	}
@@ -524,7 +731,7 @@ func updateSetFields(elemType reflect.Type) string {
 	for i := 0; i < elemType.NumField(); i++ {
 		field := elemType.Field(i)
 		dbTag := field.Tag.Get("db")
-		if !idTag(dbTag) && !emptyTag(dbTag) {
+		if writableTag(dbTag) {
 			setFields = append(setFields, fmt.Sprintf("\"%s\" = :%s", dbTag, dbTag))
 		}
 	}
@@ -535,6 +742,26 @@ func idTag(dbTag string) bool {
 	return dbTag == "id"
 }
 
+// versionTag is the optimistic locking column, set by the database:
+// 1 on insert, bumped by every update
+func versionTag(dbTag string) bool {
+	return dbTag == "version"
+}
+
+// writableTag tells whether the column is inserted & updated from the element fields
+func writableTag(dbTag string) bool {
+	return !idTag(dbTag) && !versionTag(dbTag) && !emptyTag(dbTag)
+}
+
+func hasVersion(elemType reflect.Type) bool {
+	for i := 0; i < elemType.NumField(); i++ {
+		if versionTag(elemType.Field(i).Tag.Get("db")) {
+			return true
+		}
+	}
+	return false
+}
+
 func emptyTag(dbTag string) bool {
 	emptyTags := []string{"", "-"}
 	for _, t := range emptyTags {
@@ -0,0 +1,48 @@
+package data
+
+import (
+	"reflect"
+	"testing"
+)
+
+type named struct {
+	ID   int    `db:"id"`
+	Name string `db:"name"`
+}
+
+func TestFillBackOutOfOrder(t *testing.T) {
+	elems := []*named{{Name: "Alpha"}, {Name: "beta"}, {Name: "Gamma"}}
+	// the rows come back in another order, with the names as stored
+	inserted := []named{{ID: 3, Name: "Gamma"}, {ID: 1, Name: "Alpha"}, {ID: 2, Name: "Beta"}}
+
+	err := fillBack(reflect.ValueOf(elems), 0, len(elems), reflect.ValueOf(inserted), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []int{1, 2, 3} {
+		if elems[i].ID != want {
+			t.Errorf("element %d got id %d, want %d", i, elems[i].ID, want)
+		}
+	}
+	if elems[1].Name != "Beta" {
+		t.Errorf("got name %s, want the stored Beta", elems[1].Name)
+	}
+}
+
+func TestFillBackMissingRow(t *testing.T) {
+	elems := []*named{{Name: "alpha"}, {Name: "beta"}}
+	inserted := []named{{ID: 1, Name: "alpha"}}
+
+	if err := fillBack(reflect.ValueOf(elems), 0, len(elems), reflect.ValueOf(inserted), 1); err == nil {
+		t.Fatal("no error for a missing row")
+	}
+}
+
+func TestFillBackDuplicateKey(t *testing.T) {
+	elems := []*named{{Name: "alpha"}, {Name: "ALPHA"}}
+	inserted := []named{{ID: 1, Name: "alpha"}, {ID: 2, Name: "ALPHA"}}
+
+	if err := fillBack(reflect.ValueOf(elems), 0, len(elems), reflect.ValueOf(inserted), 1); err == nil {
+		t.Fatal("no error for a duplicate key")
+	}
+}
@@ -0,0 +1,35 @@
+package data
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxStatementLength truncates the statements recorded on the spans,
+// the bulk inserts have thousands of parameters
+const maxStatementLength = 2048
+
+var tracer = otel.Tracer("github.com/riskiramdan/evos/internal/data")
+
+// startSpan starts the span of a statement, e.g. SELECT, run on the table of the storage
+func (r *PostgresStorage) startSpan(ctx context.Context, operation string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, operation+" "+r.tableName,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBOperationKey.String(operation),
+			semconv.DBSQLTableKey.String(r.tableName),
+		),
+	)
+}
+
+// setStatement records the statement run on the span
+func setStatement(span trace.Span, query string) {
+	if len(query) > maxStatementLength {
+		query = query[:maxStatementLength] + "..."
+	}
+	span.SetAttributes(semconv.DBStatementKey.String(query))
+}
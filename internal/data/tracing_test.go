@@ -0,0 +1,117 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/riskiramdan/evos/internal/tracing/tracingtest"
+
+	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// fakeQueryer answers the selects with err
+type fakeQueryer struct {
+	err error
+}
+
+func (q *fakeQueryer) PrepareNamed(query string) (*sqlx.NamedStmt, error) {
+	return nil, errors.New("not supported")
+}
+
+func (q *fakeQueryer) Rebind(query string) string {
+	return sqlx.Rebind(sqlx.DOLLAR, query)
+}
+
+func (q *fakeQueryer) MustExec(query string, args ...interface{}) sql.Result {
+	return nil
+}
+
+func (q *fakeQueryer) Select(dest interface{}, query string, args ...interface{}) error {
+	return q.err
+}
+
+func (q *fakeQueryer) Get(dest interface{}, query string, args ...interface{}) error {
+	return q.err
+}
+
+func newTestStorage(q Queryer) *PostgresStorage {
+	elemType := reflect.TypeOf(named{})
+	return &PostgresStorage{
+		db:           q,
+		tableName:    "characters",
+		elemType:     elemType,
+		selectFields: selectFields(elemType),
+		insertFields: insertFields(elemType),
+	}
+}
+
+func TestStatementSpan(t *testing.T) {
+	spans := tracingtest.Record()
+	storage := newTestStorage(&fakeQueryer{})
+
+	elems := []*named{}
+	err := storage.Where(context.Background(), &elems, `"name" = :name`, map[string]interface{}{"name": "Thor"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	span := tracingtest.Find(spans(), "SELECT characters")
+	if span == nil {
+		t.Fatal("no statement span")
+	}
+	if span.SpanKind() != trace.SpanKindClient {
+		t.Errorf("got span kind %s, want client", span.SpanKind())
+	}
+	for key, want := range map[string]string{
+		"db.system":    "postgresql",
+		"db.operation": "SELECT",
+		"db.sql.table": "characters",
+		"db.statement": `SELECT "id","name" FROM "characters" WHERE "name" = :name`,
+	} {
+		if got := tracingtest.Attribute(span, key); got != want {
+			t.Errorf("got %s %q, want %q", key, got, want)
+		}
+	}
+	if span.Status().Code != codes.Unset {
+		t.Errorf("got status %v, want unset", span.Status().Code)
+	}
+}
+
+func TestStatementSpanFailed(t *testing.T) {
+	spans := tracingtest.Record()
+	storage := newTestStorage(&fakeQueryer{err: errors.New("connection refused")})
+
+	elems := []*named{}
+	storage.Where(context.Background(), &elems, `"id" = :id`, map[string]interface{}{"id": 1})
+
+	span := tracingtest.Find(spans(), "SELECT characters")
+	if span == nil {
+		t.Fatal("no statement span")
+	}
+	if span.Status().Code != codes.Error || span.Status().Description != "connection refused" {
+		t.Errorf("got status %v, want the error", span.Status())
+	}
+	if len(span.Events()) != 1 || span.Events()[0].Name != "exception" {
+		t.Errorf("got events %v, want the recorded error", span.Events())
+	}
+}
+
+func TestStatementTruncated(t *testing.T) {
+	spans := tracingtest.Record()
+	storage := newTestStorage(&fakeQueryer{})
+
+	elems := []*named{}
+	where := `"name" IN ('` + strings.Repeat("x", 2*maxStatementLength) + `')`
+	storage.Where(context.Background(), &elems, where, map[string]interface{}{})
+
+	span := tracingtest.Find(spans(), "SELECT characters")
+	if got := tracingtest.Attribute(span, "db.statement"); len(got) != maxStatementLength+len("...") {
+		t.Errorf("got a statement of %d bytes, want it truncated", len(got))
+	}
+}
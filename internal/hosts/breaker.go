@@ -0,0 +1,121 @@
+package hosts
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrCircuitOpen error when the host failed too many times in a row, the request is not sent
+	ErrCircuitOpen = errors.New("circuit open")
+)
+
+// circuit states, exported as the value of the hosts_circuit_state gauge
+const (
+	circuitClosed   = 0
+	circuitHalfOpen = 1
+	circuitOpen     = 2
+)
+
+// breaker is the circuit breaker of a host. It opens after threshold consecutive
+// failures and rejects the requests for the cooldown, then lets a single trial
+// request through: its success closes the circuit, its failure opens it again.
+type breaker struct {
+	host      string
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    int
+	failures int
+	openedAt time.Time
+	// trying is set while the trial request of the half-open circuit is in flight
+	trying bool
+}
+
+func (b *breaker) setState(state int) {
+	b.state = state
+	hostCircuitState.WithLabelValues(b.host).Set(float64(state))
+}
+
+// allow tells whether a request may be sent to the host
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case circuitOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.setState(circuitHalfOpen)
+		b.trying = true
+		return true
+	case circuitHalfOpen:
+		if b.trying {
+			return false
+		}
+		b.trying = true
+		return true
+	}
+	return true
+}
+
+// done records the outcome of a request allowed through
+func (b *breaker) done(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trying = false
+	if success {
+		b.failures = 0
+		if b.state != circuitClosed {
+			b.setState(circuitClosed)
+		}
+		return
+	}
+
+	b.failures++
+	if b.state == circuitHalfOpen || b.failures >= b.threshold {
+		b.openedAt = time.Now()
+		b.setState(circuitOpen)
+	}
+}
+
+// release lets another request through after one allowed whose outcome is unknown
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trying = false
+}
+
+// breakers holds the circuit breaker of every host called
+type breakers struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]*breaker
+}
+
+func (bs *breakers) get(host string) *breaker {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	b, ok := bs.hosts[host]
+	if !ok {
+		b = &breaker{host: host, threshold: bs.threshold, cooldown: bs.cooldown}
+		bs.hosts[host] = b
+	}
+	return b
+}
+
+func newBreakers(threshold int, cooldown time.Duration) *breakers {
+	return &breakers{
+		threshold: threshold,
+		cooldown:  cooldown,
+		hosts:     map[string]*breaker{},
+	}
+}
@@ -0,0 +1,51 @@
+package hosts
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBreakerSingleTrial(t *testing.T) {
+	b := newBreakers(1, time.Millisecond).get("breaker.test")
+
+	b.done(false)
+	if b.allow() {
+		t.Fatal("allowed while open")
+	}
+	time.Sleep(2 * time.Millisecond)
+
+	if !b.allow() {
+		t.Fatal("the trial is not allowed after the cooldown")
+	}
+	if b.allow() {
+		t.Fatal("a second request is allowed while the trial is in flight")
+	}
+	b.release()
+	if !b.allow() {
+		t.Fatal("no request allowed after the trial is released")
+	}
+	b.done(true)
+	if b.state != circuitClosed {
+		t.Fatalf("got state %d, want closed", b.state)
+	}
+	if !b.allow() || !b.allow() {
+		t.Fatal("closed, every request is allowed")
+	}
+}
+
+func TestBreakerResetOnSuccess(t *testing.T) {
+	b := newBreakers(3, time.Minute).get("breaker.test")
+
+	b.done(false)
+	b.done(false)
+	b.done(true)
+	b.done(false)
+	b.done(false)
+	if b.state != circuitClosed {
+		t.Fatal("opened by failures that were not consecutive")
+	}
+	b.done(false)
+	if b.state != circuitOpen {
+		t.Fatal("not opened by the threshold of consecutive failures")
+	}
+}
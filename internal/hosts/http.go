@@ -1,122 +1,276 @@
 package hosts
 
 import (
+	"bytes"
+	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 
-	"github.com/parnurzeal/gorequest"
-)
+	"github.com/riskiramdan/evos/config"
+	"github.com/riskiramdan/evos/internal/logging"
+	"github.com/riskiramdan/evos/internal/tracing"
 
-// HTTPManager interface
-type HTTPManager struct{}
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
 
-// ConfigHTTP config struct
-type ConfigHTTP struct {
-	DebugClient bool   `envconfig:"DEBUG_CLIENT" default:"true"`
-	Timeout     string `envconfig:"TIMEOUT" default:"60s"`
-	RetryBad    int    `envconfig:"RETRY_BAD" default:"1"`
-}
+var tracer = otel.Tracer("github.com/riskiramdan/evos/internal/hosts")
 
 var (
-	envHTTP ConfigHTTP
+	// ErrInvalidURL error when the url to call is not an absolute http(s) url
+	ErrInvalidURL = errors.New("invalid url")
 )
 
+// StatusError is returned with the body of a response answered with an error status
+type StatusError struct {
+	StatusCode int
+	Body       []byte
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// HTTPManager calls the external hosts with a shared client, keeping the
+// connections to each host open. The idempotent requests are retried with
+// an exponential backoff, and each host has its own circuit breaker.
+type HTTPManager struct {
+	config   config.HTTPClientConfig
+	client   *http.Client
+	breakers *breakers
+}
+
+// NewHTTPManager creates the http manager calling the hosts with the client configuration
+func NewHTTPManager(config config.HTTPClientConfig) (*HTTPManager, error) {
+	tlsConfig, err := newTLSConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   10 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   config.MaxIdleConnsPerHost,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+		TLSClientConfig:       tlsConfig,
+	}
+
+	return &HTTPManager{
+		config: config,
+		// the timeout is set on the context of each call, so it spans the retries
+		client:   &http.Client{Transport: transport},
+		breakers: newBreakers(config.BreakerThreshold, config.BreakerCooldown),
+	}, nil
+}
+
+func newTLSConfig(config config.HTTPClientConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: config.InsecureSkipVerify,
+	}
+	if config.InsecureSkipVerify {
+		log.Warn().Msg("the certificates of the hosts are not verified")
+	}
+	if config.CAFile == "" {
+		return tlsConfig, nil
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	pem, err := ioutil.ReadFile(config.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("hosts: error when reading the CA file: %v", err)
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("hosts: no certificate found in the CA file %s", config.CAFile)
+	}
+	tlsConfig.RootCAs = pool
+	return tlsConfig, nil
+}
+
 // HTTPGet func
-func (hm *HTTPManager) HTTPGet(url string, header http.Header) ([]byte, error) {
-	request := gorequest.New()
-	request.SetDebug(envHTTP.DebugClient)
-	timeout, _ := time.ParseDuration(envHTTP.Timeout)
-	reqagent := request.Get(url)
-	header.Set("Accept-Encoding", "identity")
-	reqagent.Header = header
-	_, body, errs := reqagent.
-		Timeout(timeout).
-		Retry(envHTTP.RetryBad, time.Second, http.StatusInternalServerError).
-		End()
-	if errs != nil {
-		return []byte(body), errs[0]
-	}
-	return []byte(body), nil
+func (hm *HTTPManager) HTTPGet(ctx context.Context, url string, header http.Header) ([]byte, error) {
+	return hm.do(ctx, http.MethodGet, url, nil, header)
 }
 
 // HTTPPost func
-func (hm *HTTPManager) HTTPPost(url string, jsondata interface{}) ([]byte, error) {
-	request := gorequest.New()
-	request.SetDebug(envHTTP.DebugClient)
-	timeout, _ := time.ParseDuration(envHTTP.Timeout)
-	if url[:5] == "https" {
-		request.TLSClientConfig(&tls.Config{InsecureSkipVerify: true})
-	}
-	reqagent := request.Post(url)
-	reqagent.Header.Set("Content-Type", "application/json")
-	_, body, errs := reqagent.
-		Send(jsondata).
-		Timeout(timeout).
-		Retry(envHTTP.RetryBad, time.Second, http.StatusInternalServerError).
-		End()
-	if errs != nil {
-		return []byte(body), errs[0]
-	}
-	return []byte(body), nil
+func (hm *HTTPManager) HTTPPost(ctx context.Context, url string, jsondata interface{}) ([]byte, error) {
+	return hm.do(ctx, http.MethodPost, url, jsondata, nil)
 }
 
 // HTTPPostWithHeader func
-func (hm *HTTPManager) HTTPPostWithHeader(url string, jsondata interface{}, header http.Header) ([]byte, error) {
-	request := gorequest.New()
-	request.SetDebug(envHTTP.DebugClient)
-	timeout, _ := time.ParseDuration(envHTTP.Timeout)
-	reqagent := request.Post(url)
-	reqagent.Header = header
-	_, body, errs := reqagent.
-		Send(jsondata).
-		Timeout(timeout).
-		Retry(envHTTP.RetryBad, time.Second, http.StatusInternalServerError).
-		End()
-	if errs != nil {
-		return []byte(body), errs[0]
-	}
-	return []byte(body), nil
+func (hm *HTTPManager) HTTPPostWithHeader(ctx context.Context, url string, jsondata interface{}, header http.Header) ([]byte, error) {
+	return hm.do(ctx, http.MethodPost, url, jsondata, header)
 }
 
 // HTTPPutWithHeader func
-func (hm *HTTPManager) HTTPPutWithHeader(url string, jsondata interface{}, header http.Header) ([]byte, error) {
-	request := gorequest.New()
-	request.SetDebug(envHTTP.DebugClient)
-	timeout, _ := time.ParseDuration(envHTTP.Timeout)
-	if url[:5] == "https" {
-		request.TLSClientConfig(&tls.Config{InsecureSkipVerify: true})
-	}
-	reqagent := request.Put(url)
-	reqagent.Header = header
-	_, body, errs := reqagent.
-		Send(jsondata).
-		Timeout(timeout).
-		Retry(envHTTP.RetryBad, time.Second, http.StatusInternalServerError).
-		End()
-	if errs != nil {
-		return []byte(body), errs[0]
-	}
-	return []byte(body), nil
+func (hm *HTTPManager) HTTPPutWithHeader(ctx context.Context, url string, jsondata interface{}, header http.Header) ([]byte, error) {
+	return hm.do(ctx, http.MethodPut, url, jsondata, header)
 }
 
 // HTTPDeleteWithHeader func
-func (hm *HTTPManager) HTTPDeleteWithHeader(url string, jsondata interface{}, header http.Header) ([]byte, error) {
-	request := gorequest.New()
-	request.SetDebug(envHTTP.DebugClient)
-	timeout, _ := time.ParseDuration(envHTTP.Timeout)
-	if url[:5] == "https" {
-		request.TLSClientConfig(&tls.Config{InsecureSkipVerify: true})
-	}
-	reqagent := request.Delete(url)
-	reqagent.Header = header
-	_, body, errs := reqagent.
-		Send(jsondata).
-		Timeout(timeout).
-		Retry(envHTTP.RetryBad, time.Second, http.StatusInternalServerError).
-		End()
-	if errs != nil {
-		return []byte(body), errs[0]
-	}
-	return []byte(body), nil
+func (hm *HTTPManager) HTTPDeleteWithHeader(ctx context.Context, url string, jsondata interface{}, header http.Header) ([]byte, error) {
+	return hm.do(ctx, http.MethodDelete, url, jsondata, header)
+}
+
+// idempotent tells whether the request can be retried without side effects
+func idempotent(method string) bool {
+	return method != http.MethodPost
+}
+
+// retryable tells whether a failed attempt may succeed on a retry
+func retryable(status int, err error) bool {
+	return err != nil || status >= http.StatusInternalServerError || status == http.StatusTooManyRequests
+}
+
+// backoff returns the wait before the retry following the attempt,
+// doubling with every attempt, capped, and jittered over its upper half
+func (hm *HTTPManager) backoff(attempt int) time.Duration {
+	d := hm.config.RetryBackoff
+	for i := 0; i < attempt && d < hm.config.RetryMaxBackoff; i++ {
+		d *= 2
+	}
+	if d > hm.config.RetryMaxBackoff {
+		d = hm.config.RetryMaxBackoff
+	}
+	half := int64(d / 2)
+	return time.Duration(half + rand.Int63n(half+1))
+}
+
+// do sends the request, the json data as its body, through the circuit of the host,
+// retrying it when idempotent. A response with an error status returns its body
+// and a *StatusError.
+func (hm *HTTPManager) do(ctx context.Context, method string, rawURL string, jsondata interface{}, header http.Header) (_ []byte, err error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("hosts: %w %q", ErrInvalidURL, rawURL)
+	}
+
+	var body []byte
+	if jsondata != nil {
+		body, err = json.Marshal(jsondata)
+		if err != nil {
+			return nil, fmt.Errorf("hosts: error when encoding the body: %v", err)
+		}
+	}
+
+	ctx, span := tracer.Start(ctx, "HTTP "+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPMethodKey.String(method),
+			semconv.HTTPURLKey.String(u.Redacted()),
+			semconv.NetPeerNameKey.String(u.Hostname()),
+		),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, hm.config.Timeout)
+	defer cancel()
+
+	retries := 0
+	if idempotent(method) {
+		retries = hm.config.Retry
+	}
+	b := hm.breakers.get(u.Host)
+	for attempt := 0; ; attempt++ {
+		if !b.allow() {
+			hostRequestsTotal.WithLabelValues(u.Host, method, "circuit_open").Inc()
+			return nil, fmt.Errorf("hosts: %s %s: %w", method, u.Host, ErrCircuitOpen)
+		}
+
+		resBody, status, err := hm.send(ctx, method, u, body, header)
+		if status != 0 {
+			span.SetAttributes(semconv.HTTPStatusCodeKey.Int(status))
+		}
+		if err != nil && ctx.Err() != nil {
+			// cancelled or timed out, it says nothing about the host
+			b.release()
+		} else {
+			b.done(err == nil && status < http.StatusInternalServerError)
+		}
+
+		if attempt < retries && retryable(status, err) && ctx.Err() == nil {
+			hostRetriesTotal.WithLabelValues(u.Host, method).Inc()
+			span.AddEvent("retry", trace.WithAttributes(attribute.Int("attempt", attempt+1)))
+			select {
+			case <-time.After(hm.backoff(attempt)):
+				continue
+			case <-ctx.Done():
+			}
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("hosts: %s %s: %w", method, u.Redacted(), err)
+		}
+		if status >= http.StatusBadRequest {
+			return resBody, &StatusError{StatusCode: status, Body: resBody}
+		}
+		return resBody, nil
+	}
+}
+
+// send makes a single attempt of the request
+func (hm *HTTPManager) send(ctx context.Context, method string, u *url.URL, body []byte, header http.Header) ([]byte, int, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), reader)
+	if err != nil {
+		return nil, 0, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if body != nil && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	start := time.Now()
+	res, err := hm.client.Do(req)
+	if err != nil {
+		hostRequestDuration.WithLabelValues(u.Host, method).Observe(time.Since(start).Seconds())
+		hostRequestsTotal.WithLabelValues(u.Host, method, "error").Inc()
+		if hm.config.Debug {
+			logging.FromContext(ctx).Info().Err(err).Str("method", method).Str("url", u.Redacted()).Dur("duration", time.Since(start)).Msg("host request failed")
+		}
+		return nil, 0, err
+	}
+	defer res.Body.Close()
+
+	resBody, err := ioutil.ReadAll(res.Body)
+	hostRequestDuration.WithLabelValues(u.Host, method).Observe(time.Since(start).Seconds())
+	hostRequestsTotal.WithLabelValues(u.Host, method, strconv.Itoa(res.StatusCode)).Inc()
+	if hm.config.Debug {
+		logging.FromContext(ctx).Info().Str("method", method).Str("url", u.Redacted()).Int("status", res.StatusCode).Dur("duration", time.Since(start)).Msg("host request")
+	}
+	if err != nil {
+		return nil, res.StatusCode, err
+	}
+	return resBody, res.StatusCode, nil
 }
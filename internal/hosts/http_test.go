@@ -0,0 +1,271 @@
+package hosts
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/riskiramdan/evos/config"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func newTestManager(t *testing.T, retry int) *HTTPManager {
+	t.Helper()
+	hm, err := NewHTTPManager(config.HTTPClientConfig{
+		Timeout:             5 * time.Second,
+		Retry:               retry,
+		RetryBackoff:        time.Millisecond,
+		RetryMaxBackoff:     4 * time.Millisecond,
+		MaxIdleConnsPerHost: 1,
+		BreakerThreshold:    3,
+		BreakerCooldown:     50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hm
+}
+
+// newTestServer answers with the statuses in turn, the last one repeated, counting the hits
+func newTestServer(t *testing.T, statuses ...int) (*httptest.Server, *int32) {
+	t.Helper()
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := int(atomic.AddInt32(&hits, 1)) - 1
+		if i >= len(statuses) {
+			i = len(statuses) - 1
+		}
+		w.WriteHeader(statuses[i])
+		w.Write([]byte(http.StatusText(statuses[i])))
+	}))
+	t.Cleanup(server.Close)
+	return server, &hits
+}
+
+func hostOf(t *testing.T, server *httptest.Server) string {
+	t.Helper()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.Host
+}
+
+func TestRetryServerError(t *testing.T) {
+	hm := newTestManager(t, 2)
+	server, hits := newTestServer(t, http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusOK)
+	host := hostOf(t, server)
+
+	body, err := hm.HTTPGet(context.Background(), server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "OK" {
+		t.Fatalf("got body %q", body)
+	}
+	if got := atomic.LoadInt32(hits); got != 3 {
+		t.Fatalf("got %d requests, want 3", got)
+	}
+	if got := testutil.ToFloat64(hostRetriesTotal.WithLabelValues(host, http.MethodGet)); got != 2 {
+		t.Errorf("got %v retries, want 2", got)
+	}
+	if got := testutil.ToFloat64(hostRequestsTotal.WithLabelValues(host, http.MethodGet, "503")); got != 2 {
+		t.Errorf("got %v 503 requests, want 2", got)
+	}
+	if got := testutil.ToFloat64(hostRequestsTotal.WithLabelValues(host, http.MethodGet, "200")); got != 1 {
+		t.Errorf("got %v 200 requests, want 1", got)
+	}
+}
+
+func TestRetryTooManyRequests(t *testing.T) {
+	hm := newTestManager(t, 1)
+	server, hits := newTestServer(t, http.StatusTooManyRequests, http.StatusOK)
+
+	_, err := hm.HTTPPutWithHeader(context.Background(), server.URL, map[string]int{"power": 1}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := atomic.LoadInt32(hits); got != 2 {
+		t.Fatalf("got %d requests, want 2", got)
+	}
+}
+
+func TestRetriesExhausted(t *testing.T) {
+	hm := newTestManager(t, 2)
+	server, hits := newTestServer(t, http.StatusInternalServerError)
+
+	body, err := hm.HTTPGet(context.Background(), server.URL, nil)
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("got %v, want a 500 StatusError", err)
+	}
+	if string(body) != "Internal Server Error" {
+		t.Errorf("got body %q", body)
+	}
+	if got := atomic.LoadInt32(hits); got != 3 {
+		t.Fatalf("got %d requests, want 3", got)
+	}
+}
+
+func TestNoRetryPost(t *testing.T) {
+	hm := newTestManager(t, 2)
+	server, hits := newTestServer(t, http.StatusServiceUnavailable, http.StatusOK)
+	host := hostOf(t, server)
+
+	_, err := hm.HTTPPost(context.Background(), server.URL, map[string]int{"power": 1})
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("got %v, want a 503 StatusError", err)
+	}
+	if got := atomic.LoadInt32(hits); got != 1 {
+		t.Fatalf("got %d requests, want 1", got)
+	}
+	if got := testutil.ToFloat64(hostRetriesTotal.WithLabelValues(host, http.MethodPost)); got != 0 {
+		t.Errorf("got %v retries, want 0", got)
+	}
+}
+
+func TestNoRetryClientError(t *testing.T) {
+	hm := newTestManager(t, 2)
+	server, hits := newTestServer(t, http.StatusNotFound, http.StatusOK)
+
+	_, err := hm.HTTPGet(context.Background(), server.URL, nil)
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("got %v, want a 404 StatusError", err)
+	}
+	if got := atomic.LoadInt32(hits); got != 1 {
+		t.Fatalf("got %d requests, want 1", got)
+	}
+}
+
+func TestInvalidURL(t *testing.T) {
+	hm := newTestManager(t, 0)
+
+	for _, rawURL := range []string{"", "/character", "ftp://example.com", "http://"} {
+		if _, err := hm.HTTPGet(context.Background(), rawURL, nil); !errors.Is(err, ErrInvalidURL) {
+			t.Errorf("%q got %v, want ErrInvalidURL", rawURL, err)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	hm := newTestManager(t, 0)
+	hm.config.RetryBackoff = 100 * time.Millisecond
+	hm.config.RetryMaxBackoff = time.Second
+
+	for attempt, max := range []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	} {
+		for i := 0; i < 20; i++ {
+			d := hm.backoff(attempt)
+			if d < max/2 || d > max {
+				t.Fatalf("attempt %d waits %s, want between %s and %s", attempt, d, max/2, max)
+			}
+		}
+	}
+}
+
+func TestBreakerOpens(t *testing.T) {
+	ctx := context.Background()
+	hm := newTestManager(t, 0)
+	status := int32(http.StatusInternalServerError)
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.WriteHeader(int(atomic.LoadInt32(&status)))
+	}))
+	defer server.Close()
+	host := hostOf(t, server)
+
+	for i := 0; i < 3; i++ {
+		if _, err := hm.HTTPGet(ctx, server.URL, nil); errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("request %d rejected before the threshold", i)
+		}
+	}
+	if got := testutil.ToFloat64(hostCircuitState.WithLabelValues(host)); got != circuitOpen {
+		t.Fatalf("got circuit state %v, want open", got)
+	}
+
+	// open, the requests are not sent
+	if _, err := hm.HTTPGet(ctx, server.URL, nil); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("got %v, want ErrCircuitOpen", err)
+	}
+	if got := atomic.LoadInt32(&hits); got != 3 {
+		t.Fatalf("got %d requests, want 3", got)
+	}
+	if got := testutil.ToFloat64(hostRequestsTotal.WithLabelValues(host, http.MethodGet, "circuit_open")); got != 1 {
+		t.Errorf("got %v rejected requests, want 1", got)
+	}
+
+	// half-open after the cooldown, a failing trial opens it again
+	time.Sleep(60 * time.Millisecond)
+	if _, err := hm.HTTPGet(ctx, server.URL, nil); errors.Is(err, ErrCircuitOpen) {
+		t.Fatal("the trial request is rejected")
+	}
+	if _, err := hm.HTTPGet(ctx, server.URL, nil); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("got %v after a failed trial, want ErrCircuitOpen", err)
+	}
+
+	// a succeeding trial closes it
+	atomic.StoreInt32(&status, http.StatusOK)
+	time.Sleep(60 * time.Millisecond)
+	if _, err := hm.HTTPGet(ctx, server.URL, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := testutil.ToFloat64(hostCircuitState.WithLabelValues(host)); got != circuitClosed {
+		t.Fatalf("got circuit state %v, want closed", got)
+	}
+	if _, err := hm.HTTPGet(ctx, server.URL, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// The circuit of a host is kept apart from the other hosts
+func TestBreakerPerHost(t *testing.T) {
+	ctx := context.Background()
+	hm := newTestManager(t, 0)
+	failing, _ := newTestServer(t, http.StatusInternalServerError)
+	healthy, _ := newTestServer(t, http.StatusOK)
+
+	for i := 0; i < 4; i++ {
+		hm.HTTPGet(ctx, failing.URL, nil)
+	}
+	if _, err := hm.HTTPGet(ctx, healthy.URL, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// A cancelled request says nothing about the host, it does not count as a failure
+func TestCancelledNotCounted(t *testing.T) {
+	hm := newTestManager(t, 0)
+	block := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-block:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(block)
+
+	for i := 0; i < 4; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		_, err := hm.HTTPGet(ctx, server.URL, nil)
+		cancel()
+		if errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("request %d rejected, the cancelled ones opened the circuit", i)
+		}
+	}
+}
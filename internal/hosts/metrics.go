@@ -0,0 +1,41 @@
+package hosts
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	hostRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "hosts_requests_total",
+			Help: "A counter for the requests to the upstream hosts, by status code or error.",
+		},
+		[]string{"host", "method", "code"},
+	)
+	hostRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "hosts_request_duration_seconds",
+			Help:    "A histogram of latencies of the requests to the upstream hosts, per attempt.",
+			Buckets: []float64{.05, .1, .25, .5, 1, 2.5, 5, 10},
+		},
+		[]string{"host", "method"},
+	)
+	hostRetriesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "hosts_retries_total",
+			Help: "A counter for the retried requests to the upstream hosts.",
+		},
+		[]string{"host", "method"},
+	)
+	hostCircuitState = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "hosts_circuit_state",
+			Help: "The circuit breaker state of the upstream hosts: 0 closed, 1 half-open, 2 open.",
+		},
+		[]string{"host"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(hostRequestsTotal, hostRequestDuration, hostRetriesTotal, hostCircuitState)
+}
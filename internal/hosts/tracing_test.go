@@ -0,0 +1,87 @@
+package hosts
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/riskiramdan/evos/internal/tracing/tracingtest"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestHostSpan(t *testing.T) {
+	spans := tracingtest.Record()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	hm := newTestManager(t, 1)
+
+	traceparent := make(chan string, 2)
+	status := http.StatusBadGateway
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent <- r.Header.Get("traceparent")
+		w.WriteHeader(status)
+		status = http.StatusOK
+	}))
+	defer server.Close()
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "parent")
+	_, err := hm.HTTPGet(ctx, server.URL+"/character?limit=10", nil)
+	parent.End()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	span := tracingtest.Find(spans(), "HTTP GET")
+	if span == nil {
+		t.Fatal("no host call span")
+	}
+	if span.SpanKind() != trace.SpanKindClient {
+		t.Errorf("got span kind %s, want client", span.SpanKind())
+	}
+	if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Error("the host call span is not a child of the span of the context")
+	}
+	if got := tracingtest.Attribute(span, "http.status_code"); got != "200" {
+		t.Errorf("got http.status_code %q", got)
+	}
+	if got := tracingtest.Attribute(span, "http.url"); got != server.URL+"/character?limit=10" {
+		t.Errorf("got http.url %q", got)
+	}
+	if len(span.Events()) != 1 || span.Events()[0].Name != "retry" {
+		t.Errorf("got events %v, want a retry", span.Events())
+	}
+	if span.Status().Code != codes.Unset {
+		t.Errorf("got status %v, want unset", span.Status().Code)
+	}
+
+	// every attempt carries the trace context to the host
+	for i := 0; i < 2; i++ {
+		header := <-traceparent
+		if want := span.SpanContext().TraceID().String(); len(header) < 35 || header[3:35] != want {
+			t.Errorf("attempt %d got traceparent %q, want the trace %s", i, header, want)
+		}
+	}
+}
+
+func TestHostSpanFailed(t *testing.T) {
+	spans := tracingtest.Record()
+	hm := newTestManager(t, 0)
+	server, _ := newTestServer(t, http.StatusInternalServerError)
+
+	hm.HTTPPost(context.Background(), server.URL, map[string]int{"power": 1})
+
+	span := tracingtest.Find(spans(), "HTTP POST")
+	if span == nil {
+		t.Fatal("no host call span")
+	}
+	if span.Status().Code != codes.Error {
+		t.Errorf("got status %v, want error", span.Status().Code)
+	}
+	if got := tracingtest.Attribute(span, "http.status_code"); got != "500" {
+		t.Errorf("got http.status_code %q", got)
+	}
+}
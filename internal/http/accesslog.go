@@ -0,0 +1,85 @@
+package http
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/riskiramdan/evos/internal/logging"
+	"github.com/riskiramdan/evos/internal/types"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// requestHook adds the fields only known while the request is served to every line logged
+type requestHook struct {
+	rctx  *chi.Context
+	start time.Time
+}
+
+func (h requestHook) Run(e *zerolog.Event, level zerolog.Level, msg string) {
+	if h.rctx != nil && h.rctx.RoutePattern() != "" {
+		e.Str("route", h.rctx.RoutePattern())
+	}
+	e.Dur("latency", time.Since(h.start))
+}
+
+// accessLog puts the request logger in the context, with the request id, the
+// trace id, the route pattern & the latency, then logs a line once the request
+// is served. It must be used after middleware.RequestID & traced.
+func (hs *Server) accessLog(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		fields := hs.logger.With().Str("request_id", middleware.GetReqID(r.Context()))
+		if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+			fields = fields.Str("trace_id", sc.TraceID().String())
+		}
+		logger := fields.Logger().
+			Hook(requestHook{rctx: chi.RouteContext(r.Context()), start: start})
+
+		req := &logging.Request{}
+		ctx := logging.WithRequest(logging.WithLogger(r.Context(), logger), req)
+		ww := &errorRecorder{WrapResponseWriter: middleware.NewWrapResponseWriter(w, r.ProtoMajor), req: req}
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		e := logger.Info()
+		switch {
+		case status >= http.StatusInternalServerError:
+			e = logger.Error()
+		case status >= http.StatusBadRequest:
+			e = logger.Warn()
+		}
+		if userID := req.UserID(); userID != 0 {
+			e.Int("user_id", userID)
+		}
+		if err := req.Err(); err != nil {
+			e.Object("error", err)
+		}
+		e.Str("method", r.Method).
+			Str("path", r.URL.Path).
+			Int("status", status).
+			Int("bytes", ww.BytesWritten()).
+			Str("remote_ip", clientIP(r)).
+			Str("user_agent", r.UserAgent()).
+			Msg("request")
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+// errorRecorder records the error a request is answered with by response.Error
+type errorRecorder struct {
+	middleware.WrapResponseWriter
+	req *logging.Request
+}
+
+// SetError records the error for the access log
+func (w *errorRecorder) SetError(err types.Error) {
+	w.req.SetError(err)
+}
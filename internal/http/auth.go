@@ -2,18 +2,15 @@ package http
 
 import (
 	"context"
-	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/riskiramdan/evos/internal/appcontext"
-	"github.com/riskiramdan/evos/internal/constants"
-	"github.com/riskiramdan/evos/internal/data"
 	"github.com/riskiramdan/evos/internal/http/response"
+	"github.com/riskiramdan/evos/internal/logging"
 	"github.com/riskiramdan/evos/internal/types"
 	"github.com/riskiramdan/evos/internal/user"
-
-	"github.com/dgrijalva/jwt-go"
 )
 
 func (hs *Server) authorizedOnly(userService user.ServiceInterface) func(next http.Handler) http.Handler {
@@ -24,65 +21,61 @@ func (hs *Server) authorizedOnly(userService user.ServiceInterface) func(next ht
 			ctx := r.Context()
 			tokenString = getBearerToken(r)
 			if tokenString == "" {
-				response.Error(w, "Unauthorized", http.StatusUnauthorized, types.Error{
+				response.Error(w, r, "Unauthorized", types.Error{
 					Path:    ".Server->authorizeOnly()",
 					Message: "",
 					Error:   nil,
 					Type:    "",
+					Kind:    types.KindUnauthorized,
 				})
 				return
 			}
 
-			token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-				if method, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-					return nil, fmt.Errorf("Signing method invalid")
-				} else if method != jwt.SigningMethodHS256 {
-					return nil, fmt.Errorf("Signing method invalid")
-				}
-
-				return constants.SignatureKey, nil
-			})
-			if err != nil {
-				response.Error(w, "Unauthorized", http.StatusUnauthorized, types.Error{
-					Path:    ".Server->authorizeOnly()",
-					Message: err.Error(),
-					Error:   err,
-					Type:    "Invalid Token",
-				})
+			singleUser, sess, errT := userService.Authenticate(ctx, tokenString)
+			if errT != nil {
+				response.Error(w, r, "Unauthorized", *errT)
 				return
 			}
-			singleUser, errT := userService.GetByToken(ctx, tokenString)
+			ctx = context.WithValue(ctx, appcontext.KeyUserID, singleUser.ID)
+			ctx = logging.WithUserID(ctx, singleUser.ID)
+			ctx = context.WithValue(ctx, appcontext.KeySessionID, strconv.Itoa(sess.ID))
+			ctx = context.WithValue(ctx, appcontext.KeyRoleID, singleUser.RoleID)
+			if singleUser.RoleID == 1 {
+
+				ctx = context.WithValue(ctx, appcontext.KeyIsAdmin, true)
+			}
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// permitted only lets through the users whose role is granted the named permission.
+// It must be used after authorizedOnly.
+func (hs *Server) permitted(name string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			allowed, errT := hs.permissionService.HasPermission(ctx, appcontext.RoleID(ctx), name)
 			if errT != nil {
-				if errT.Error != data.ErrNotFound {
-					response.Error(w, "Internal Server Error", http.StatusInternalServerError, *errT)
-					return
-				}
-				response.Error(w, "Unauthorized", http.StatusUnauthorized, types.Error{
-					Path:    ".Server->authorizeOnly()",
-					Message: "",
-					Error:   nil,
-					Type:    "",
-				})
+				errT.Path = ".Server->permitted()" + errT.Path
+				response.Error(w, r, "Internal Server Error", *errT)
 				return
 			}
-			_, ok := token.Claims.(jwt.MapClaims)
-			if !ok || !token.Valid {
-				response.Error(w, "Unauthorized", http.StatusUnauthorized, types.Error{
-					Path:    ".Server->authorizeOnly()",
-					Message: "",
+			if !allowed {
+				response.Error(w, r, "Forbidden", types.Error{
+					Path:    ".Server->permitted()",
+					Message: "missing permission " + name,
 					Error:   nil,
 					Type:    "",
+					Kind:    types.KindForbidden,
 				})
 				return
 			}
-			ctx = context.WithValue(ctx, appcontext.KeyUserID, singleUser.ID)
-			ctx = context.WithValue(ctx, appcontext.KeySessionID, *singleUser.Token)
-			if singleUser.RoleID == 1 {
-
-				ctx = context.WithValue(ctx, appcontext.KeyIsAdmin, true)
-			}
 
-			next.ServeHTTP(w, r.WithContext(ctx))
+			next.ServeHTTP(w, r)
 		}
 
 		return http.HandlerFunc(fn)
@@ -100,20 +93,3 @@ func getBearerToken(r *http.Request) string {
 	token = strings.Trim(splitToken[1], " ")
 	return token
 }
-
-// AdminOnly ..
-func AdminOnly(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ctx := r.Context()
-		if !appcontext.IsAdmin(ctx) {
-			response.Error(w, "Admin Only", http.StatusUnauthorized, types.Error{
-				Path:    ".Server->authorizeOnly()",
-				Message: "",
-				Error:   nil,
-				Type:    "",
-			})
-			return
-		}
-		next.ServeHTTP(w, r)
-	})
-}
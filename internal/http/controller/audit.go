@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/riskiramdan/evos/internal/audit"
+	"github.com/riskiramdan/evos/internal/http/response"
+	"github.com/riskiramdan/evos/internal/types"
+)
+
+// AuditController represents the audit log controller
+type AuditController struct {
+	auditService audit.ServiceInterface
+}
+
+// AuditList audit log list, the cursor of the next page and the count when asked for
+type AuditList struct {
+	Data       []*audit.Logs `json:"data"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+	Total      *int          `json:"total,omitempty"`
+}
+
+// GetListAudit function for get the audit trail, optionally of a single entity (?entity=character&id=)
+func (a *AuditController) GetListAudit(w http.ResponseWriter, r *http.Request) {
+	p, err := parsePagination(r, ".AuditController->ListAudit()")
+	if err != nil {
+		response.Error(w, r, "Bad Request", *err)
+		return
+	}
+
+	q := r.URL.Query()
+	entityID, errID := queryInt(q, "id")
+	if errID != nil {
+		response.Error(w, r, errID.Error(), types.Error{
+			Path:    ".AuditController->ListAudit()",
+			Message: errID.Error(),
+			Error:   errID,
+			Type:    "golang-error",
+			Kind:    types.KindBadRequest,
+		})
+		return
+	}
+	params := &audit.FindAllLogsParams{
+		Entity:    q.Get("entity"),
+		Limit:     p.Limit,
+		After:     p.After,
+		WithTotal: p.WithTotal,
+	}
+	if entityID != nil {
+		params.EntityID = *entityID
+	}
+
+	logs, page, err := a.auditService.ListLogs(r.Context(), params)
+	if err != nil {
+		err.Path = ".AuditController->ListAudit()" + err.Path
+		response.Error(w, r, err.Message, *err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, AuditList{
+		Data:       logs,
+		NextCursor: page.NextCursor,
+		Total:      page.Total,
+	})
+}
+
+// NewAuditController creates a new audit log controller
+func NewAuditController(
+	auditService audit.ServiceInterface,
+) *AuditController {
+	return &AuditController{
+		auditService: auditService,
+	}
+}
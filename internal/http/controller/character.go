@@ -1,10 +1,15 @@
 package controller
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/riskiramdan/evos/internal/character"
@@ -12,6 +17,8 @@ import (
 	"github.com/riskiramdan/evos/internal/http/response"
 	"github.com/riskiramdan/evos/internal/types"
 	u "github.com/riskiramdan/evos/util"
+
+	validator "gopkg.in/go-playground/validator.v9"
 )
 
 // CharacterController represents the character controller
@@ -21,75 +28,105 @@ type CharacterController struct {
 	utility          *u.Utility
 }
 
-// CharacterList character list and count
+// CharacterList character list, the cursor of the next page and the count when asked for
 type CharacterList struct {
-	Data  []*character.Characters `json:"data"`
-	Total int                     `json:"total"`
+	Data       []*character.Characters `json:"data"`
+	NextCursor string                  `json:"next_cursor,omitempty"`
+	Total      *int                    `json:"total,omitempty"`
 }
 
 // GetListCharacter function for get list data characters
 func (a *CharacterController) GetListCharacter(w http.ResponseWriter, r *http.Request) {
-	var err *types.Error
+	a.listCharacter(w, r, false)
+}
 
-	queryValues := r.URL.Query()
-	var limit = 10
-	var errConversion error
-	if queryValues.Get("limit") != "" {
-		limit, errConversion = strconv.Atoi(queryValues.Get("limit"))
-		if errConversion != nil {
-			err = &types.Error{
-				Path:    ".CharacterController->ListCharacter()",
-				Message: errConversion.Error(),
-				Error:   errConversion,
-				Type:    "golang-error",
-			}
-			response.Error(w, "Bad Request", http.StatusBadRequest, *err)
-			return
-		}
-	}
+// GetListDeletedCharacter function for get list data of soft deleted characters
+func (a *CharacterController) GetListDeletedCharacter(w http.ResponseWriter, r *http.Request) {
+	a.listCharacter(w, r, true)
+}
 
-	var page = 1
-	if queryValues.Get("page") != "" {
-		page, errConversion = strconv.Atoi(queryValues.Get("page"))
-		if errConversion != nil {
-			err = &types.Error{
-				Path:    ".CharacterController->ListCharacter()",
-				Message: errConversion.Error(),
-				Error:   errConversion,
-				Type:    "golang-error",
-			}
-			response.Error(w, "Bad Request", http.StatusBadRequest, *err)
-			return
+// parseCharacterFilters parses the filters & the sort of the character listing
+func parseCharacterFilters(r *http.Request, params *character.FindAllCharacterParams) error {
+	q := r.URL.Query()
+	var err error
+
+	params.Name = q.Get("name")
+	params.CreatedBy = q.Get("createdBy")
+	if params.CharacterTypeIDs, err = queryInts(q, "characterTypeId"); err != nil {
+		return err
+	}
+	if params.PowerMin, err = queryInt(q, "powerMin"); err != nil {
+		return err
+	}
+	if params.PowerMax, err = queryInt(q, "powerMax"); err != nil {
+		return err
+	}
+	if params.ValueMin, err = queryInt(q, "valueMin"); err != nil {
+		return err
+	}
+	if params.ValueMax, err = queryInt(q, "valueMax"); err != nil {
+		return err
+	}
+	if params.CreatedFrom, err = queryTime(q, "createdFrom"); err != nil {
+		return err
+	}
+	if params.CreatedTo, err = queryTime(q, "createdTo"); err != nil {
+		return err
+	}
+	if params.UpdatedFrom, err = queryTime(q, "updatedFrom"); err != nil {
+		return err
+	}
+	if params.UpdatedTo, err = queryTime(q, "updatedTo"); err != nil {
+		return err
+	}
+	if q.Get("sort") != "" {
+		if params.Sort, err = data.ParseSort(q.Get("sort"), character.SortFields); err != nil {
+			return err
 		}
 	}
 
-	var name = queryValues.Get("name")
+	return nil
+}
 
-	if limit < 0 {
-		limit = 10
+func (a *CharacterController) listCharacter(w http.ResponseWriter, r *http.Request, deleted bool) {
+	p, err := parsePagination(r, ".CharacterController->ListCharacter()")
+	if err != nil {
+		response.Error(w, r, "Bad Request", *err)
+		return
 	}
-	if page < 0 {
-		page = 1
+
+	params := &character.FindAllCharacterParams{
+		Limit:     p.Limit,
+		After:     p.After,
+		WithTotal: p.WithTotal,
+		Deleted:   deleted,
 	}
-	characterList, count, err := a.characterService.ListCharacters(r.Context(), &character.FindAllCharacterParams{
-		Name:  name,
-		Limit: limit,
-		Page:  page,
-	})
+	errFilter := parseCharacterFilters(r, params)
+	if errFilter != nil {
+		response.Error(w, r, errFilter.Error(), types.Error{
+			Path:    ".CharacterController->ListCharacter()",
+			Message: errFilter.Error(),
+			Error:   errFilter,
+			Type:    "golang-error",
+			Kind:    types.KindBadRequest,
+		})
+		return
+	}
+
+	characterList, page, err := a.characterService.ListCharacters(r.Context(), params)
 	if err != nil {
 		err.Path = ".CharacterController->ListCharacter()" + err.Path
-		if err.Error != data.ErrNotFound {
-			response.Error(w, "Internal Server Error", http.StatusInternalServerError, *err)
-			return
-		}
+		response.Error(w, r, err.Message, *err)
+		return
 	}
 	if characterList == nil {
 		characterList = []*character.Characters{}
 	}
 
 	response.JSON(w, http.StatusOK, CharacterList{
-		Data:  characterList,
-		Total: count,
+		Data:       characterList,
+		NextCursor: page.NextCursor,
+		Total:      page.Total,
 	})
 }
 
@@ -97,18 +134,8 @@ func (a *CharacterController) GetListCharacter(w http.ResponseWriter, r *http.Re
 func (a *CharacterController) PostCreateCharacter(w http.ResponseWriter, r *http.Request) {
 	var err *types.Error
 
-	decoder := json.NewDecoder(r.Body)
-
-	var params *character.TransactionParams
-	errDecode := decoder.Decode(&params)
-	if errDecode != nil {
-		err = &types.Error{
-			Path:    ".CharacterController->CreateCharacter()",
-			Message: errDecode.Error(),
-			Error:   errDecode,
-			Type:    "golang-error",
-		}
-		response.Error(w, "Bad Request", http.StatusBadRequest, *err)
+	var params character.TransactionParams
+	if !decodeAndValidate(w, r, &params, ".CharacterController->CreateCharacter()") {
 		return
 	}
 	errTransaction := a.dataManager.RunInTransaction(r.Context(), func(ctx context.Context) error {
@@ -123,12 +150,7 @@ func (a *CharacterController) PostCreateCharacter(w http.ResponseWriter, r *http
 		return nil
 	})
 	if errTransaction != nil {
-		err.Path = ".CharacterController->CreateCharacter()" + err.Path
-		if errTransaction == character.ErrCharacterExists {
-			response.Error(w, character.ErrCharacterExists.Error(), http.StatusUnprocessableEntity, *err)
-			return
-		}
-		response.Error(w, "Internal Server Error", http.StatusInternalServerError, *err)
+		response.Error(w, r, errTransaction.Error(), transactionError(err, errTransaction, ".CharacterController->CreateCharacter()"))
 		return
 	}
 
@@ -139,51 +161,437 @@ func (a *CharacterController) PostCreateCharacter(w http.ResponseWriter, r *http
 func (a *CharacterController) PutUpdateCharacter(w http.ResponseWriter, r *http.Request) {
 	var err *types.Error
 
-	decoder := json.NewDecoder(r.Body)
-
-	var params *character.TransactionParams
-	errDecode := decoder.Decode(&params)
-	if errDecode != nil {
+	var params character.TransactionParams
+	if !decodeAndValidatePartial(w, r, &params, ".CharacterController->UpdateCharacter()") {
+		return
+	}
+	if version := ifMatch(r); version != nil {
+		params.Version = version
+	}
+	var sCharacterID = chi.URLParam(r, "characterId")
+	characterID, errConversion := strconv.Atoi(sCharacterID)
+	if errConversion != nil {
 		err = &types.Error{
 			Path:    ".CharacterController->UpdateCharacter()",
-			Message: errDecode.Error(),
-			Error:   errDecode,
+			Message: errConversion.Error(),
+			Error:   errConversion,
 			Type:    "golang-error",
+			Kind:    types.KindBadRequest,
 		}
-		response.Error(w, "Bad Request", http.StatusBadRequest, *err)
+		response.Error(w, r, "Bad Request", *err)
 		return
 	}
+
+	var updated *character.Characters
+	errTransaction := a.dataManager.RunInTransaction(r.Context(), func(ctx context.Context) error {
+		updated, err = a.characterService.UpdateCharacter(ctx, characterID, &params)
+		if err != nil {
+			return err.Error
+		}
+		return nil
+	})
+	if errTransaction != nil {
+		errUpdate := transactionError(err, errTransaction, ".CharacterController->UpdateCharacter()")
+		if errors.Is(errTransaction, data.ErrConflict) {
+			current, errCurrent := a.characterService.GetCharacter(r.Context(), characterID)
+			if errCurrent == nil {
+				conflict(w, r, errUpdate, current.Version, current)
+				return
+			}
+		}
+		response.Error(w, r, errTransaction.Error(), errUpdate)
+		return
+	}
+	w.Header().Set("ETag", etag(updated.Version))
+	response.JSON(w, http.StatusOK, "Update Character Successful")
+
+}
+
+func parseCharacterID(r *http.Request, path string) (int, *types.Error) {
 	var sCharacterID = chi.URLParam(r, "characterId")
 	characterID, errConversion := strconv.Atoi(sCharacterID)
 	if errConversion != nil {
-		err = &types.Error{
-			Path:    ".CharacterController->UpdateCharacter()",
+		return 0, &types.Error{
+			Path:    path,
 			Message: errConversion.Error(),
 			Error:   errConversion,
 			Type:    "golang-error",
+			Kind:    types.KindBadRequest,
 		}
-		response.Error(w, "Bad Request", http.StatusBadRequest, *err)
+	}
+	return characterID, nil
+}
+
+// GetCharacter function for get a character by its id
+func (a *CharacterController) GetCharacter(w http.ResponseWriter, r *http.Request) {
+	characterID, err := parseCharacterID(r, ".CharacterController->GetCharacter()")
+	if err != nil {
+		response.Error(w, r, "Bad Request", *err)
+		return
+	}
+
+	characterDetail, err := a.characterService.GetCharacter(r.Context(), characterID)
+	if err != nil {
+		err.Path = ".CharacterController->GetCharacter()" + err.Path
+		response.Error(w, r, err.Message, *err)
+		return
+	}
+
+	w.Header().Set("ETag", etag(characterDetail.Version))
+	response.JSON(w, http.StatusOK, characterDetail)
+}
+
+// DeleteCharacter for soft deleting data character
+func (a *CharacterController) DeleteCharacter(w http.ResponseWriter, r *http.Request) {
+	characterID, err := parseCharacterID(r, ".CharacterController->DeleteCharacter()")
+	if err != nil {
+		response.Error(w, r, "Bad Request", *err)
 		return
 	}
 
 	errTransaction := a.dataManager.RunInTransaction(r.Context(), func(ctx context.Context) error {
-		_, err = a.characterService.UpdateCharacter(ctx, characterID, params)
+		err = a.characterService.DeleteCharacter(ctx, characterID)
 		if err != nil {
 			return err.Error
 		}
 		return nil
 	})
 	if errTransaction != nil {
-		err.Path = ".CharacterController->UpdateCharacter()" + err.Path
-		if errTransaction == data.ErrAlreadyExist {
-			response.Error(w, data.ErrAlreadyExist.Error(), http.StatusUnprocessableEntity, *err)
-			return
+		response.Error(w, r, errTransaction.Error(), transactionError(err, errTransaction, ".CharacterController->DeleteCharacter()"))
+		return
+	}
+
+	response.JSON(w, http.StatusOK, "Delete Character Successful")
+}
+
+// PostRestoreCharacter for restoring a soft deleted character
+func (a *CharacterController) PostRestoreCharacter(w http.ResponseWriter, r *http.Request) {
+	characterID, err := parseCharacterID(r, ".CharacterController->RestoreCharacter()")
+	if err != nil {
+		response.Error(w, r, "Bad Request", *err)
+		return
+	}
+
+	var restored *character.Characters
+	errTransaction := a.dataManager.RunInTransaction(r.Context(), func(ctx context.Context) error {
+		restored, err = a.characterService.RestoreCharacter(ctx, characterID)
+		if err != nil {
+			return err.Error
 		}
-		response.Error(w, "Internal Server Error", http.StatusInternalServerError, *err)
+		return nil
+	})
+	if errTransaction != nil {
+		response.Error(w, r, errTransaction.Error(), transactionError(err, errTransaction, ".CharacterController->RestoreCharacter()"))
 		return
 	}
-	response.JSON(w, http.StatusOK, "Update Character Successful")
 
+	response.JSON(w, http.StatusOK, restored)
+}
+
+// bulkMaxBodySize & bulkMaxRows bound the size of a bulk import
+const (
+	bulkMaxBodySize = 32 << 20
+	bulkMaxRows     = 50000
+)
+
+// BulkRowReport reports what happened to a row of a bulk import
+type BulkRowReport struct {
+	Row       int                    `json:"row"`
+	Status    string                 `json:"status"`
+	Character *character.Characters  `json:"character,omitempty"`
+	Code      string                 `json:"code,omitempty"`
+	Error     string                 `json:"error,omitempty"`
+	Fields    []*response.FieldError `json:"fields,omitempty"`
+}
+
+// BulkReport reports the outcome of a bulk import
+type BulkReport struct {
+	Created int              `json:"created"`
+	Updated int              `json:"updated"`
+	Failed  int              `json:"failed"`
+	Rows    []*BulkRowReport `json:"rows"`
+}
+
+// readBulkRows reads the raw rows of a bulk import, given as a json array or as NDJSON.
+// The row numbers are the array positions or the line numbers, starting at 1.
+func readBulkRows(r *http.Request) (map[int]json.RawMessage, []int, error) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return nil, nil, errEmptyBody
+	}
+
+	raws := map[int]json.RawMessage{}
+	order := []int{}
+	if body[0] == '[' {
+		list := []json.RawMessage{}
+		err = json.Unmarshal(body, &list)
+		if err != nil {
+			return nil, nil, err
+		}
+		for i, raw := range list {
+			raws[i+1] = raw
+			order = append(order, i+1)
+		}
+	} else {
+		for i, line := range bytes.Split(body, []byte("\n")) {
+			line = bytes.TrimSpace(line)
+			if len(line) == 0 {
+				continue
+			}
+			raws[i+1] = json.RawMessage(line)
+			order = append(order, i+1)
+		}
+	}
+	if len(order) > bulkMaxRows {
+		return nil, nil, fmt.Errorf("too many rows, at most %d are accepted", bulkMaxRows)
+	}
+
+	return raws, order, nil
+}
+
+// PostBulkCreateCharacter for creating many characters at once from a json array or NDJSON body.
+// The invalid rows are reported and the valid ones created, with ?upsert=true the characters
+// whose name exists are updated instead of failing.
+func (a *CharacterController) PostBulkCreateCharacter(w http.ResponseWriter, r *http.Request) {
+	upsert, _ := strconv.ParseBool(r.URL.Query().Get("upsert"))
+
+	r.Body = http.MaxBytesReader(w, r.Body, bulkMaxBodySize)
+	raws, order, errRead := readBulkRows(r)
+	if errRead != nil {
+		response.Error(w, r, "Bad Request", types.Error{
+			Path:    ".CharacterController->BulkCreateCharacter()",
+			Message: errRead.Error(),
+			Error:   errRead,
+			Type:    "golang-error",
+			Kind:    types.KindBadRequest,
+		})
+		return
+	}
+
+	reports := map[int]*BulkRowReport{}
+	rows := []*character.BulkRow{}
+	for _, row := range order {
+		params := &character.TransactionParams{}
+		errRow := json.Unmarshal(raws[row], params)
+		if errRow == nil {
+			errRow = validate.Struct(params)
+		}
+		if errRow != nil {
+			reports[row] = bulkRowFailure(row, errRow)
+			continue
+		}
+		rows = append(rows, &character.BulkRow{Row: row, Params: params})
+	}
+
+	a.runBulk(w, r, ".CharacterController->BulkCreateCharacter()", order, reports, rows, upsert)
+}
+
+// bulkRowFailure reports a row rejected before reaching the service
+func bulkRowFailure(row int, errRow error) *BulkRowReport {
+	report := &BulkRowReport{Row: row, Status: character.BulkFailed, Code: types.KindBadRequest.String(), Error: errRow.Error()}
+	if errValidation, ok := errRow.(validator.ValidationErrors); ok {
+		report.Code = types.KindValidation.String()
+		report.Error = "Validation Error"
+		for _, e := range errValidation {
+			report.Fields = append(report.Fields, response.MakeFieldError(e.Field(), e.ActualTag()))
+		}
+	}
+	return report
+}
+
+// runBulk creates the valid rows in a single transaction, then writes the report
+// of every row in order, merging the failures already known
+func (a *CharacterController) runBulk(
+	w http.ResponseWriter,
+	r *http.Request,
+	path string,
+	order []int,
+	reports map[int]*BulkRowReport,
+	rows []*character.BulkRow,
+	upsert bool,
+) {
+	var err *types.Error
+
+	var results []*character.BulkResult
+	errTransaction := a.dataManager.RunInTransaction(r.Context(), func(ctx context.Context) error {
+		results, err = a.characterService.BulkCreateCharacters(ctx, rows, upsert)
+		if err != nil {
+			return err.Error
+		}
+		return nil
+	})
+	if errTransaction != nil {
+		response.Error(w, r, errTransaction.Error(), transactionError(err, errTransaction, path))
+		return
+	}
+	for _, result := range results {
+		report := &BulkRowReport{Row: result.Row, Status: result.Status, Character: result.Character}
+		if result.Error != nil {
+			_, report.Code = types.Error{Error: result.Error}.Classify()
+			report.Error = result.Error.Error()
+		}
+		reports[result.Row] = report
+	}
+
+	resp := BulkReport{Rows: []*BulkRowReport{}}
+	for _, row := range order {
+		report := reports[row]
+		switch report.Status {
+		case character.BulkCreated:
+			resp.Created++
+		case character.BulkUpdated:
+			resp.Updated++
+		default:
+			resp.Failed++
+		}
+		resp.Rows = append(resp.Rows, report)
+	}
+
+	response.JSON(w, http.StatusOK, resp)
+}
+
+// exportColumns is the header row of the character export
+var exportColumns = []interface{}{
+	"id", "name", "characterTypeID", "power", "value", "createdAt", "createdBy", "updatedAt", "updatedBy",
+}
+
+// GetExportCharacter exports the filtered & sorted character list as a xlsx or csv (?format=) spreadsheet,
+// the calculated value included
+func (a *CharacterController) GetExportCharacter(w http.ResponseWriter, r *http.Request) {
+	format, errFormat := spreadsheetFormat(r)
+	params := &character.FindAllCharacterParams{}
+	if errFormat == nil {
+		errFormat = parseCharacterFilters(r, params)
+	}
+	if errFormat != nil {
+		response.Error(w, r, errFormat.Error(), types.Error{
+			Path:    ".CharacterController->ExportCharacter()",
+			Message: errFormat.Error(),
+			Error:   errFormat,
+			Type:    "golang-error",
+			Kind:    types.KindBadRequest,
+		})
+		return
+	}
+
+	characterList, _, err := a.characterService.ListCharacters(r.Context(), params)
+	if err != nil {
+		err.Path = ".CharacterController->ExportCharacter()" + err.Path
+		response.Error(w, r, err.Message, *err)
+		return
+	}
+
+	rows := [][]interface{}{exportColumns}
+	for _, c := range characterList {
+		updatedAt := ""
+		if c.UpdatedAt != nil {
+			updatedAt = c.UpdatedAt.Format(time.RFC3339)
+		}
+		rows = append(rows, []interface{}{
+			c.ID, c.Name, c.CharacterTypeID, c.Power, c.Value,
+			c.CreatedAt.Format(time.RFC3339), c.CreatedBy, updatedAt, c.UpdatedBy,
+		})
+	}
+
+	filename := fmt.Sprintf("characters-%s.%s", time.Now().Format("20060102-150405"), format)
+	if format == "csv" {
+		response.CSV(w, http.StatusOK, filename, rows)
+		return
+	}
+	response.EXCEL(w, http.StatusOK, filename, rows)
+}
+
+// importColumns are the columns of the character import, named after the json fields
+var importColumns = []string{"name", "characterTypeID", "power"}
+
+// GetImportTemplate downloads the empty xlsx spreadsheet to fill for the character import
+func (a *CharacterController) GetImportTemplate(w http.ResponseWriter, r *http.Request) {
+	header := []interface{}{}
+	for _, column := range importColumns {
+		header = append(header, column)
+	}
+	response.EXCEL(w, http.StatusOK, "character-import-template.xlsx", [][]interface{}{header})
+}
+
+// PostImportCharacter for creating characters from an uploaded xlsx or csv spreadsheet (form field "file").
+// Like the bulk import, the invalid rows are reported and the valid ones created, ?upsert=true updates the
+// characters whose name exists. The rows are numbered as in the spreadsheet, the header being row 1.
+func (a *CharacterController) PostImportCharacter(w http.ResponseWriter, r *http.Request) {
+	upsert, _ := strconv.ParseBool(r.URL.Query().Get("upsert"))
+
+	r.Body = http.MaxBytesReader(w, r.Body, bulkMaxBodySize)
+	records, errRead := readSpreadsheet(r, bulkMaxBodySize)
+	var positions map[string]int
+	if errRead == nil {
+		positions, errRead = spreadsheetColumns(records[0], importColumns)
+	}
+	if errRead == nil && len(records)-1 > bulkMaxRows {
+		errRead = fmt.Errorf("too many rows, at most %d are accepted", bulkMaxRows)
+	}
+	if errRead != nil {
+		response.Error(w, r, "Bad Request", types.Error{
+			Path:    ".CharacterController->ImportCharacter()",
+			Message: errRead.Error(),
+			Error:   errRead,
+			Type:    "golang-error",
+			Kind:    types.KindBadRequest,
+		})
+		return
+	}
+
+	reports := map[int]*BulkRowReport{}
+	rows := []*character.BulkRow{}
+	order := []int{}
+	for i, record := range records[1:] {
+		if blankRecord(record) {
+			continue
+		}
+		row := i + 2
+		order = append(order, row)
+
+		params, fields := importParams(record, positions)
+		if len(fields) > 0 {
+			reports[row] = &BulkRowReport{Row: row, Status: character.BulkFailed, Code: types.KindValidation.String(), Error: "Validation Error", Fields: fields}
+			continue
+		}
+		errRow := validate.Struct(params)
+		if errRow != nil {
+			reports[row] = bulkRowFailure(row, errRow)
+			continue
+		}
+		rows = append(rows, &character.BulkRow{Row: row, Params: params})
+	}
+
+	a.runBulk(w, r, ".CharacterController->ImportCharacter()", order, reports, rows, upsert)
+}
+
+// importParams converts a spreadsheet record, the cells that are not numbers where numbers are expected are reported
+func importParams(record []string, positions map[string]int) (*character.TransactionParams, []*response.FieldError) {
+	params := &character.TransactionParams{
+		Name: recordValue(record, positions, "name"),
+	}
+	fields := []*response.FieldError{}
+
+	if v := recordValue(record, positions, "characterTypeID"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			fields = append(fields, response.MakeFieldError("characterTypeID", "numeric"))
+		}
+		params.CharacterTypeID = id
+	}
+	if v := recordValue(record, positions, "power"); v != "" {
+		power, err := strconv.Atoi(v)
+		if err != nil {
+			fields = append(fields, response.MakeFieldError("power", "numeric"))
+		}
+		params.Power = &power
+	}
+
+	return params, fields
 }
 
 // NewCharacterController creates a new character controller
@@ -0,0 +1,193 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/riskiramdan/evos/internal/charactertype"
+	"github.com/riskiramdan/evos/internal/data"
+	"github.com/riskiramdan/evos/internal/http/response"
+	"github.com/riskiramdan/evos/internal/types"
+)
+
+// CharacterTypeController represents the character type controller
+type CharacterTypeController struct {
+	characterTypeService charactertype.ServiceInterface
+	dataManager          *data.Manager
+}
+
+// CharacterTypeList character type list and count
+type CharacterTypeList struct {
+	Data  []*charactertype.CharacterTypes `json:"data"`
+	Total int                             `json:"total"`
+}
+
+// GetListCharacterType function for get list data character types
+func (a *CharacterTypeController) GetListCharacterType(w http.ResponseWriter, r *http.Request) {
+	var err *types.Error
+
+	queryValues := r.URL.Query()
+	var limit = 10
+	var errConversion error
+	if queryValues.Get("limit") != "" {
+		limit, errConversion = strconv.Atoi(queryValues.Get("limit"))
+		if errConversion != nil {
+			err = &types.Error{
+				Path:    ".CharacterTypeController->ListCharacterType()",
+				Message: errConversion.Error(),
+				Error:   errConversion,
+				Type:    "golang-error",
+				Kind:    types.KindBadRequest,
+			}
+			response.Error(w, r, "Bad Request", *err)
+			return
+		}
+	}
+
+	var page = 1
+	if queryValues.Get("page") != "" {
+		page, errConversion = strconv.Atoi(queryValues.Get("page"))
+		if errConversion != nil {
+			err = &types.Error{
+				Path:    ".CharacterTypeController->ListCharacterType()",
+				Message: errConversion.Error(),
+				Error:   errConversion,
+				Type:    "golang-error",
+				Kind:    types.KindBadRequest,
+			}
+			response.Error(w, r, "Bad Request", *err)
+			return
+		}
+	}
+
+	if limit < 0 {
+		limit = 10
+	}
+	if page < 0 {
+		page = 1
+	}
+	characterTypeList, count, err := a.characterTypeService.ListCharacterTypes(r.Context(), &charactertype.FindAllCharacterTypeParams{
+		Limit: limit,
+		Page:  page,
+	})
+	if err != nil {
+		err.Path = ".CharacterTypeController->ListCharacterType()" + err.Path
+		if !errors.Is(err.Error, data.ErrNotFound) {
+			response.Error(w, r, err.Message, *err)
+			return
+		}
+	}
+	if characterTypeList == nil {
+		characterTypeList = []*charactertype.CharacterTypes{}
+	}
+
+	response.JSON(w, http.StatusOK, CharacterTypeList{
+		Data:  characterTypeList,
+		Total: count,
+	})
+}
+
+// GetCharacterType function for get a character type by its id
+func (a *CharacterTypeController) GetCharacterType(w http.ResponseWriter, r *http.Request) {
+	var err *types.Error
+
+	var sCharacterTypeID = chi.URLParam(r, "characterTypeId")
+	characterTypeID, errConversion := strconv.Atoi(sCharacterTypeID)
+	if errConversion != nil {
+		err = &types.Error{
+			Path:    ".CharacterTypeController->GetCharacterType()",
+			Message: errConversion.Error(),
+			Error:   errConversion,
+			Type:    "golang-error",
+			Kind:    types.KindBadRequest,
+		}
+		response.Error(w, r, "Bad Request", *err)
+		return
+	}
+
+	characterType, err := a.characterTypeService.GetCharacterType(r.Context(), characterTypeID)
+	if err != nil {
+		err.Path = ".CharacterTypeController->GetCharacterType()" + err.Path
+		response.Error(w, r, err.Message, *err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, characterType)
+}
+
+// PostCreateCharacterType for creating data character type
+func (a *CharacterTypeController) PostCreateCharacterType(w http.ResponseWriter, r *http.Request) {
+	var err *types.Error
+
+	var params charactertype.TransactionParams
+	if !decodeAndValidate(w, r, &params, ".CharacterTypeController->CreateCharacterType()") {
+		return
+	}
+
+	var characterType *charactertype.CharacterTypes
+	errTransaction := a.dataManager.RunInTransaction(r.Context(), func(ctx context.Context) error {
+		characterType, err = a.characterTypeService.CreateCharacterType(ctx, &params)
+		if err != nil {
+			return err.Error
+		}
+		return nil
+	})
+	if errTransaction != nil {
+		response.Error(w, r, errTransaction.Error(), transactionError(err, errTransaction, ".CharacterTypeController->CreateCharacterType()"))
+		return
+	}
+
+	response.JSON(w, http.StatusOK, characterType)
+}
+
+// PutUpdateCharacterType for update data character type
+func (a *CharacterTypeController) PutUpdateCharacterType(w http.ResponseWriter, r *http.Request) {
+	var err *types.Error
+
+	var params charactertype.TransactionParams
+	if !decodeAndValidatePartial(w, r, &params, ".CharacterTypeController->UpdateCharacterType()") {
+		return
+	}
+	var sCharacterTypeID = chi.URLParam(r, "characterTypeId")
+	characterTypeID, errConversion := strconv.Atoi(sCharacterTypeID)
+	if errConversion != nil {
+		err = &types.Error{
+			Path:    ".CharacterTypeController->UpdateCharacterType()",
+			Message: errConversion.Error(),
+			Error:   errConversion,
+			Type:    "golang-error",
+			Kind:    types.KindBadRequest,
+		}
+		response.Error(w, r, "Bad Request", *err)
+		return
+	}
+
+	var characterType *charactertype.CharacterTypes
+	errTransaction := a.dataManager.RunInTransaction(r.Context(), func(ctx context.Context) error {
+		characterType, err = a.characterTypeService.UpdateCharacterType(ctx, characterTypeID, &params)
+		if err != nil {
+			return err.Error
+		}
+		return nil
+	})
+	if errTransaction != nil {
+		response.Error(w, r, errTransaction.Error(), transactionError(err, errTransaction, ".CharacterTypeController->UpdateCharacterType()"))
+		return
+	}
+
+	response.JSON(w, http.StatusOK, characterType)
+}
+
+// NewCharacterTypeController creates a new character type controller
+func NewCharacterTypeController(
+	characterTypeService charactertype.ServiceInterface,
+	dataManager *data.Manager,
+) *CharacterTypeController {
+	return &CharacterTypeController{
+		characterTypeService: characterTypeService,
+		dataManager:          dataManager,
+	}
+}
@@ -0,0 +1,19 @@
+package controller
+
+import (
+	"github.com/riskiramdan/evos/internal/types"
+)
+
+// transactionError returns the error a transaction failed with: the one of the service,
+// or the one of the transaction itself, e.g. a failed commit, when the service did not fail
+func transactionError(err *types.Error, errTransaction error, path string) types.Error {
+	if err == nil {
+		err = &types.Error{
+			Message: errTransaction.Error(),
+			Error:   errTransaction,
+			Type:    "golang-error",
+		}
+	}
+	err.Path = path + err.Path
+	return *err
+}
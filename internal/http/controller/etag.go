@@ -0,0 +1,44 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/riskiramdan/evos/internal/http/response"
+	"github.com/riskiramdan/evos/internal/types"
+)
+
+// etag formats the version of a resource as its entity tag
+func etag(version int) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// ifMatch returns the version required by the If-Match header, nil when the header
+// is absent or "*". A tag that is not a version can never match, so it is returned as 0.
+func ifMatch(r *http.Request) *int {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return nil
+	}
+
+	version := 0
+	tag := strings.Trim(strings.TrimPrefix(header, "W/"), `"`)
+	if v, err := strconv.Atoi(tag); err == nil {
+		version = v
+	}
+	return &version
+}
+
+// conflict writes the conflict of an update with the current state of the resource and its ETag:
+// 412 when the If-Match precondition failed, 409 when the version was given in the body
+// or the resource changed during the update
+func conflict(w http.ResponseWriter, r *http.Request, err types.Error, version int, current interface{}) {
+	status := http.StatusConflict
+	if r.Header.Get("If-Match") != "" {
+		status = http.StatusPreconditionFailed
+	}
+	w.Header().Set("ETag", etag(version))
+	response.Conflict(w, r, status, err, current)
+}
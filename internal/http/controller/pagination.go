@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/riskiramdan/evos/internal/data"
+	"github.com/riskiramdan/evos/internal/types"
+)
+
+// Page size of the cursor paginated listings
+const (
+	defaultLimit = 10
+	maxLimit     = 100
+)
+
+// pagination holds the query parameters of a cursor paginated listing
+type pagination struct {
+	Limit     int
+	After     *data.Cursor
+	WithTotal bool
+}
+
+// parsePagination parses the ?after=<cursor>&limit=&total=true query parameters
+func parsePagination(r *http.Request, path string) (*pagination, *types.Error) {
+	queryValues := r.URL.Query()
+	p := &pagination{
+		Limit: defaultLimit,
+	}
+
+	var errConversion error
+	if queryValues.Get("limit") != "" {
+		p.Limit, errConversion = strconv.Atoi(queryValues.Get("limit"))
+		if errConversion != nil {
+			return nil, &types.Error{
+				Path:    path,
+				Message: errConversion.Error(),
+				Error:   errConversion,
+				Type:    "golang-error",
+				Kind:    types.KindBadRequest,
+			}
+		}
+	}
+	if p.Limit <= 0 {
+		p.Limit = defaultLimit
+	}
+	if p.Limit > maxLimit {
+		p.Limit = maxLimit
+	}
+
+	if queryValues.Get("after") != "" {
+		p.After, errConversion = data.DecodeCursor(queryValues.Get("after"))
+		if errConversion != nil {
+			return nil, &types.Error{
+				Path:    path,
+				Message: errConversion.Error(),
+				Error:   errConversion,
+				Type:    "golang-error",
+				Kind:    types.KindBadRequest,
+			}
+		}
+	}
+
+	if queryValues.Get("total") != "" {
+		p.WithTotal, errConversion = strconv.ParseBool(queryValues.Get("total"))
+		if errConversion != nil {
+			return nil, &types.Error{
+				Path:    path,
+				Message: errConversion.Error(),
+				Error:   errConversion,
+				Type:    "golang-error",
+				Kind:    types.KindBadRequest,
+			}
+		}
+	}
+
+	return p, nil
+}
@@ -0,0 +1,61 @@
+package controller
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// queryDateLayout is the date only layout accepted besides RFC 3339 timestamps
+const queryDateLayout = "2006-01-02"
+
+// queryInt parses an optional integer query parameter
+func queryInt(q url.Values, name string) (*int, error) {
+	s := q.Get(name)
+	if s == "" {
+		return nil, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", name, err)
+	}
+	return &v, nil
+}
+
+// queryInts parses an integer list query parameter,
+// given either repeated or comma separated
+func queryInts(q url.Values, name string) ([]int, error) {
+	values := []int{}
+	for _, s := range q[name] {
+		for _, part := range strings.Split(s, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			v, err := strconv.Atoi(part)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s: %v", name, err)
+			}
+			values = append(values, v)
+		}
+	}
+	return values, nil
+}
+
+// queryTime parses an optional RFC 3339 timestamp or date query parameter
+func queryTime(q url.Values, name string) (*time.Time, error) {
+	s := q.Get(name)
+	if s == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		t, err = time.Parse(queryDateLayout, s)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s, expected a RFC 3339 timestamp or a %s date", name, queryDateLayout)
+	}
+	return &t, nil
+}
@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/riskiramdan/evos/internal/http/response"
+	"github.com/riskiramdan/evos/internal/types"
+
+	"github.com/360EntSecGroup-Skylar/excelize"
+)
+
+// spreadsheet errors
+var (
+	errUnsupportedFormat = types.NewDomainError(types.KindBadRequest, "UnsupportedFormat", "unsupported format, xlsx or csv expected")
+	errEmptySpreadsheet  = types.NewDomainError(types.KindBadRequest, "EmptySpreadsheet", "the spreadsheet has no header row")
+)
+
+// spreadsheetFormat returns the export format asked by ?format=, xlsx by default
+func spreadsheetFormat(r *http.Request) (string, error) {
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	switch format {
+	case "":
+		return "xlsx", nil
+	case "xlsx", "csv":
+		return format, nil
+	}
+	return "", errUnsupportedFormat
+}
+
+// readSpreadsheet reads the records of the spreadsheet uploaded in the "file" form field,
+// a xlsx workbook (its first sheet) or a csv file told apart by their extension
+func readSpreadsheet(r *http.Request, maxMemory int64) ([][]string, error) {
+	err := r.ParseMultipartForm(maxMemory)
+	if err != nil {
+		return nil, err
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var records [][]string
+	switch strings.ToLower(filepath.Ext(header.Filename)) {
+	case ".csv":
+		reader := csv.NewReader(file)
+		reader.FieldsPerRecord = -1
+		records, err = reader.ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		if len(records) > 0 && len(records[0]) > 0 {
+			// spreadsheet programs may prefix the csv with a byte order mark
+			records[0][0] = strings.TrimPrefix(records[0][0], "\ufeff")
+		}
+		for _, record := range records {
+			for i := range record {
+				record[i] = response.UnescapeCSV(record[i])
+			}
+		}
+	case ".xlsx":
+		f, err := excelize.OpenReader(file)
+		if err != nil {
+			return nil, err
+		}
+		sheets := f.GetSheetMap()
+		indexes := []int{}
+		for index := range sheets {
+			indexes = append(indexes, index)
+		}
+		if len(indexes) == 0 {
+			return nil, errEmptySpreadsheet
+		}
+		sort.Ints(indexes)
+		records = f.GetRows(sheets[indexes[0]])
+	default:
+		return nil, errUnsupportedFormat
+	}
+
+	if len(records) == 0 {
+		return nil, errEmptySpreadsheet
+	}
+	return records, nil
+}
+
+// spreadsheetColumns maps the columns to their position in the header,
+// the header names are matched ignoring case, spaces & underscores
+func spreadsheetColumns(header []string, columns []string) (map[string]int, error) {
+	normalize := func(s string) string {
+		s = strings.ToLower(strings.TrimSpace(s))
+		return strings.NewReplacer(" ", "", "_", "").Replace(s)
+	}
+
+	positions := map[string]int{}
+	for _, column := range columns {
+		for i, name := range header {
+			if normalize(name) == normalize(column) {
+				positions[column] = i
+				break
+			}
+		}
+		if _, ok := positions[column]; !ok {
+			return nil, fmt.Errorf("missing column %q", column)
+		}
+	}
+	return positions, nil
+}
+
+// recordValue returns the trimmed value of a record column, empty when the record is short
+func recordValue(record []string, positions map[string]int, column string) string {
+	i := positions[column]
+	if i >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[i])
+}
+
+// blankRecord tells whether every cell of the record is empty
+func blankRecord(record []string) bool {
+	for _, v := range record {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
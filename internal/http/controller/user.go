@@ -2,12 +2,20 @@ package controller
 
 import (
 	"context"
-	"encoding/json"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/go-chi/chi"
+	"github.com/riskiramdan/evos/internal/appcontext"
 	"github.com/riskiramdan/evos/internal/data"
 	"github.com/riskiramdan/evos/internal/http/response"
+	"github.com/riskiramdan/evos/internal/logging"
+	"github.com/riskiramdan/evos/internal/metrics"
+	"github.com/riskiramdan/evos/internal/ratelimit"
 	"github.com/riskiramdan/evos/internal/types"
 	"github.com/riskiramdan/evos/internal/user"
 	u "github.com/riskiramdan/evos/util"
@@ -18,79 +26,52 @@ type UserController struct {
 	userService user.ServiceInterface
 	dataManager *data.Manager
 	utility     *u.Utility
+	limiter     *ratelimit.Limiter
+	lockout     *ratelimit.Lockout
+	// phoneLimit is the number of login attempts allowed per phone in the window
+	phoneLimit int
+	window     time.Duration
 }
 
-// UserList user list and count
+// UserList user list, the cursor of the next page and the count when asked for
 type UserList struct {
-	Data  []*user.Users `json:"data"`
-	Total int           `json:"total"`
+	Data       []*user.Users `json:"data"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+	Total      *int          `json:"total,omitempty"`
 }
 
 // GetListUser function for get list data users
 func (a *UserController) GetListUser(w http.ResponseWriter, r *http.Request) {
-	var err *types.Error
-
-	queryValues := r.URL.Query()
-	var limit = 10
-	var errConversion error
-	if queryValues.Get("limit") != "" {
-		limit, errConversion = strconv.Atoi(queryValues.Get("limit"))
-		if errConversion != nil {
-			err = &types.Error{
-				Path:    ".UserController->ListUser()",
-				Message: errConversion.Error(),
-				Error:   errConversion,
-				Type:    "golang-error",
-			}
-			response.Error(w, "Bad Request", http.StatusBadRequest, *err)
-			return
-		}
-	}
-
-	var page = 1
-	if queryValues.Get("page") != "" {
-		page, errConversion = strconv.Atoi(queryValues.Get("page"))
-		if errConversion != nil {
-			err = &types.Error{
-				Path:    ".UserController->ListUser()",
-				Message: errConversion.Error(),
-				Error:   errConversion,
-				Type:    "golang-error",
-			}
-			response.Error(w, "Bad Request", http.StatusBadRequest, *err)
-			return
-		}
+	p, err := parsePagination(r, ".UserController->ListUser()")
+	if err != nil {
+		response.Error(w, r, "Bad Request", *err)
+		return
 	}
 
+	queryValues := r.URL.Query()
 	var name = queryValues.Get("name")
 	var phone = queryValues.Get("phone")
 
-	if limit < 0 {
-		limit = 10
-	}
-	if page < 0 {
-		page = 1
-	}
-	userList, count, err := a.userService.ListUsers(r.Context(), &user.FindAllUsersParams{
-		Name:  name,
-		Phone: phone,
-		Limit: limit,
-		Page:  page,
+	userList, page, err := a.userService.ListUsers(r.Context(), &user.FindAllUsersParams{
+		Name:      name,
+		Phone:     phone,
+		Limit:     p.Limit,
+		After:     p.After,
+		WithTotal: p.WithTotal,
 	})
 	if err != nil {
 		err.Path = ".UserController->ListUser()" + err.Path
-		if err.Error != data.ErrNotFound {
-			response.Error(w, "Internal Server Error", http.StatusInternalServerError, *err)
-			return
-		}
+		response.Error(w, r, err.Message, *err)
+		return
 	}
 	if userList == nil {
 		userList = []*user.Users{}
 	}
 
 	response.JSON(w, http.StatusOK, UserList{
-		Data:  userList,
-		Total: count,
+		Data:       userList,
+		NextCursor: page.NextCursor,
+		Total:      page.Total,
 	})
 }
 
@@ -98,27 +79,18 @@ func (a *UserController) GetListUser(w http.ResponseWriter, r *http.Request) {
 func (a *UserController) PostCreateUser(w http.ResponseWriter, r *http.Request) {
 	var err *types.Error
 
-	decoder := json.NewDecoder(r.Body)
-
-	var params *user.TransactionParams
-	errDecode := decoder.Decode(&params)
-	if errDecode != nil {
-		err = &types.Error{
-			Path:    ".UserController->CreateUser()",
-			Message: errDecode.Error(),
-			Error:   errDecode,
-			Type:    "golang-error",
-		}
-		response.Error(w, "Bad Request", http.StatusBadRequest, *err)
+	var params user.TransactionParams
+	if !decodeAndValidate(w, r, &params, ".UserController->CreateUser()") {
 		return
 	}
 	resp := &user.Users{}
 	errTransaction := a.dataManager.RunInTransaction(r.Context(), func(ctx context.Context) error {
+		// self registration always gets the guest role
 		resp, err = a.userService.CreateUser(ctx, &user.TransactionParams{
 			Name:     params.Name,
-			RoleID:   params.RoleID,
+			RoleID:   user.RoleGuest,
 			Phone:    params.Phone,
-			Password: a.utility.RandStringBytesMaskImprSrcSB(4),
+			Password: params.Password,
 		})
 		if err != nil {
 			return err.Error
@@ -126,55 +98,87 @@ func (a *UserController) PostCreateUser(w http.ResponseWriter, r *http.Request)
 		return nil
 	})
 	if errTransaction != nil {
-		err.Path = ".UserController->CreateUser()" + err.Path
-		if errTransaction == user.ErrPhoneAlreadyExists {
-			response.Error(w, user.ErrPhoneAlreadyExists.Error(), http.StatusUnprocessableEntity, *err)
-		} else {
-			response.Error(w, "Internal Server Error", http.StatusInternalServerError, *err)
-		}
+		response.Error(w, r, errTransaction.Error(), transactionError(err, errTransaction, ".UserController->CreateUser()"))
 		return
 	}
 
 	response.JSON(w, http.StatusOK, resp)
 }
 
+// phoneDigest identifies a phone in the logs without writing it, as the start of its sha256
+func phoneDigest(phone string) string {
+	sum := sha256.Sum256([]byte(phone))
+	return hex.EncodeToString(sum[:6])
+}
+
 // PostLogin for getting authorization ..
+// The attempts are rate limited per phone, and the account is locked out after too many failures.
 func (a *UserController) PostLogin(w http.ResponseWriter, r *http.Request) {
 	var err *types.Error
 
-	decoder := json.NewDecoder(r.Body)
-
 	var params user.LoginParams
-	errDecode := decoder.Decode(&params)
-	if errDecode != nil {
-		err = &types.Error{
+	if !decodeAndValidate(w, r, &params, ".UserController->Login()") {
+		return
+	}
+
+	params.UserAgent = r.UserAgent()
+	params.IPAddress = r.RemoteAddr
+
+	allowed, retryAfter, errLimit := a.limiter.Allow(r.Context(), "login:phone:"+params.Phone, a.phoneLimit, a.window)
+	if errLimit == nil && allowed {
+		retryAfter, errLimit = a.lockout.Locked(r.Context(), params.Phone)
+		allowed = retryAfter == 0
+	}
+	if errLimit != nil {
+		response.Error(w, r, "Internal Server Error", types.Error{
 			Path:    ".UserController->Login()",
-			Message: errDecode.Error(),
-			Error:   errDecode,
+			Message: errLimit.Error(),
+			Error:   errLimit,
 			Type:    "golang-error",
-		}
-		response.Error(w, "Bad Request", http.StatusBadRequest, *err)
+		})
+		return
+	}
+	if !allowed {
+		metrics.Logins.WithLabelValues(metrics.LoginLimited).Inc()
+		// the same answer whether the phone exists or not
+		response.TooManyRequests(w, r, "Too many attempts, try again later", retryAfter, types.Error{
+			Path:    ".UserController->Login()",
+			Message: "login attempts of phone " + phoneDigest(params.Phone) + " limited",
+			Error:   nil,
+			Type:    "",
+		})
 		return
 	}
 
 	var sess *user.LoginResponse
 	errTransaction := a.dataManager.RunInTransaction(r.Context(), func(ctx context.Context) error {
-		sess, err = a.userService.Login(ctx, params.Phone, params.Password)
+		sess, err = a.userService.Login(ctx, &params)
 		if err != nil {
 			return err.Error
 		}
 		return nil
 	})
 	if errTransaction != nil {
-		err.Path = ".UserController->Login()" + err.Path
-		if err.Error == user.ErrWrongPassword || err.Error == data.ErrNotFound || err.Error == user.ErrWrongPhone {
-			response.Error(w, "Phone / password is wrong", http.StatusBadRequest, *err)
-		} else {
-			response.Error(w, "Internal Server Error", http.StatusInternalServerError, *err)
+		errLogin := transactionError(err, errTransaction, ".UserController->Login()")
+		if errors.Is(errTransaction, user.ErrInvalidCredentials) {
+			lock, errLock := a.lockout.Fail(r.Context(), params.Phone)
+			if errLock != nil {
+				logging.FromContext(r.Context()).Warn().Err(errLock).Str("phone", phoneDigest(params.Phone)).Msg("counting the failed login")
+			}
+			if lock > 0 {
+				response.TooManyRequests(w, r, "Too many attempts, try again later", lock, errLogin)
+				return
+			}
 		}
+		response.Error(w, r, "Phone / password is wrong", errLogin)
 		return
 	}
 
+	errLock := a.lockout.Reset(r.Context(), params.Phone)
+	if errLock != nil {
+		logging.FromContext(r.Context()).Warn().Err(errLock).Str("phone", phoneDigest(params.Phone)).Msg("resetting the failed logins")
+	}
+
 	http.SetCookie(w, &http.Cookie{
 		Name:  "sessionId",
 		Value: sess.SessionID,
@@ -183,15 +187,275 @@ func (a *UserController) PostLogin(w http.ResponseWriter, r *http.Request) {
 	response.JSON(w, http.StatusOK, sess)
 }
 
+// PostRefresh for rotating the refresh token and getting a new access token
+func (a *UserController) PostRefresh(w http.ResponseWriter, r *http.Request) {
+	var err *types.Error
+
+	var params user.RefreshParams
+	if !decodeAndValidate(w, r, &params, ".UserController->Refresh()") {
+		return
+	}
+	params.UserAgent = r.UserAgent()
+	params.IPAddress = r.RemoteAddr
+
+	// not in a transaction, the revocation of a reused refresh token must be kept
+	sess, err := a.userService.Refresh(r.Context(), &params)
+	if err != nil {
+		err.Path = ".UserController->Refresh()" + err.Path
+		response.Error(w, r, "Unauthorized", *err)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:  "sessionId",
+		Value: sess.SessionID,
+	})
+
+	response.JSON(w, http.StatusOK, sess)
+}
+
+// PostLogout for revoking the current session
+func (a *UserController) PostLogout(w http.ResponseWriter, r *http.Request) {
+	var err *types.Error
+
+	sessionID := 0
+	if sid := appcontext.SessionID(r.Context()); sid != nil {
+		sessionID, _ = strconv.Atoi(*sid)
+	}
+
+	errTransaction := a.dataManager.RunInTransaction(r.Context(), func(ctx context.Context) error {
+		err = a.userService.Logout(ctx, sessionID)
+		if err != nil {
+			return err.Error
+		}
+		return nil
+	})
+	if errTransaction != nil {
+		response.Error(w, r, errTransaction.Error(), transactionError(err, errTransaction, ".UserController->Logout()"))
+		return
+	}
+
+	response.JSON(w, http.StatusOK, "Logout Successful")
+}
+
+// PostLogoutAll for revoking every session of the current user
+func (a *UserController) PostLogoutAll(w http.ResponseWriter, r *http.Request) {
+	var err *types.Error
+
+	errTransaction := a.dataManager.RunInTransaction(r.Context(), func(ctx context.Context) error {
+		err = a.userService.LogoutAll(ctx, appcontext.UserID(ctx))
+		if err != nil {
+			return err.Error
+		}
+		return nil
+	})
+	if errTransaction != nil {
+		response.Error(w, r, errTransaction.Error(), transactionError(err, errTransaction, ".UserController->LogoutAll()"))
+		return
+	}
+
+	response.JSON(w, http.StatusOK, "Logout Successful")
+}
+
+func parseUserID(r *http.Request, path string) (int, *types.Error) {
+	var sUserID = chi.URLParam(r, "userId")
+	userID, errConversion := strconv.Atoi(sUserID)
+	if errConversion != nil {
+		return 0, &types.Error{
+			Path:    path,
+			Message: errConversion.Error(),
+			Error:   errConversion,
+			Type:    "golang-error",
+			Kind:    types.KindBadRequest,
+		}
+	}
+	return userID, nil
+}
+
+// GetUser function for get a user by its id
+func (a *UserController) GetUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := parseUserID(r, ".UserController->GetUser()")
+	if err != nil {
+		response.Error(w, r, "Bad Request", *err)
+		return
+	}
+
+	resp, err := a.userService.GetUser(r.Context(), userID)
+	if err != nil {
+		err.Path = ".UserController->GetUser()" + err.Path
+		response.Error(w, r, err.Message, *err)
+		return
+	}
+
+	w.Header().Set("ETag", etag(resp.Version))
+	response.JSON(w, http.StatusOK, resp)
+}
+
+func (a *UserController) updateUser(w http.ResponseWriter, r *http.Request, userID int, path string) {
+	var err *types.Error
+
+	var params user.UpdateParams
+	if !decodeAndValidate(w, r, &params, path) {
+		return
+	}
+	if version := ifMatch(r); version != nil {
+		params.Version = version
+	}
+
+	var resp *user.Users
+	errTransaction := a.dataManager.RunInTransaction(r.Context(), func(ctx context.Context) error {
+		resp, err = a.userService.UpdateUser(ctx, userID, &params)
+		if err != nil {
+			return err.Error
+		}
+		return nil
+	})
+	if errTransaction != nil {
+		errUpdate := transactionError(err, errTransaction, path)
+		if errors.Is(errTransaction, data.ErrConflict) {
+			current, errCurrent := a.userService.GetUser(r.Context(), userID)
+			if errCurrent == nil {
+				conflict(w, r, errUpdate, current.Version, current)
+				return
+			}
+		}
+		response.Error(w, r, errTransaction.Error(), errUpdate)
+		return
+	}
+
+	w.Header().Set("ETag", etag(resp.Version))
+	response.JSON(w, http.StatusOK, resp)
+}
+
+// PutUpdateUser for updating a user by its id
+func (a *UserController) PutUpdateUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := parseUserID(r, ".UserController->UpdateUser()")
+	if err != nil {
+		response.Error(w, r, "Bad Request", *err)
+		return
+	}
+
+	a.updateUser(w, r, userID, ".UserController->UpdateUser()")
+}
+
+// PutUpdateMe for updating the current user
+func (a *UserController) PutUpdateMe(w http.ResponseWriter, r *http.Request) {
+	a.updateUser(w, r, appcontext.UserID(r.Context()), ".UserController->UpdateMe()")
+}
+
+// DeleteUser for soft deleting a user by its id
+func (a *UserController) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := parseUserID(r, ".UserController->DeleteUser()")
+	if err != nil {
+		response.Error(w, r, "Bad Request", *err)
+		return
+	}
+
+	errTransaction := a.dataManager.RunInTransaction(r.Context(), func(ctx context.Context) error {
+		err = a.userService.DeleteUser(ctx, userID)
+		if err != nil {
+			return err.Error
+		}
+		return nil
+	})
+	if errTransaction != nil {
+		response.Error(w, r, errTransaction.Error(), transactionError(err, errTransaction, ".UserController->DeleteUser()"))
+		return
+	}
+
+	response.JSON(w, http.StatusOK, "Delete User Successful")
+}
+
+// PostChangePassword for changing the password of the current user
+func (a *UserController) PostChangePassword(w http.ResponseWriter, r *http.Request) {
+	var err *types.Error
+
+	var params user.ChangePasswordParams
+	if !decodeAndValidate(w, r, &params, ".UserController->ChangePassword()") {
+		return
+	}
+
+	errTransaction := a.dataManager.RunInTransaction(r.Context(), func(ctx context.Context) error {
+		err = a.userService.ChangePassword(ctx, appcontext.UserID(ctx), &params)
+		if err != nil {
+			return err.Error
+		}
+		return nil
+	})
+	if errTransaction != nil {
+		response.Error(w, r, errTransaction.Error(), transactionError(err, errTransaction, ".UserController->ChangePassword()"))
+		return
+	}
+
+	response.JSON(w, http.StatusOK, "Change Password Successful")
+}
+
+// Lockout is the failed logins & the lockout of a phone
+type Lockout struct {
+	Phone       string     `json:"phone"`
+	Failures    int64      `json:"failures"`
+	LockedUntil *time.Time `json:"lockedUntil"`
+}
+
+// GetListLockout function for get the phones with failed logins, the locked out ones first
+func (a *UserController) GetListLockout(w http.ResponseWriter, r *http.Request) {
+	states, errList := a.lockout.List(r.Context())
+	if errList != nil {
+		response.Error(w, r, "Internal Server Error", types.Error{
+			Path:    ".UserController->ListLockout()",
+			Message: errList.Error(),
+			Error:   errList,
+			Type:    "golang-error",
+		})
+		return
+	}
+
+	lockouts := []*Lockout{}
+	for _, state := range states {
+		lockouts = append(lockouts, &Lockout{
+			Phone:       state.ID,
+			Failures:    state.Failures,
+			LockedUntil: state.LockedUntil,
+		})
+	}
+
+	response.JSON(w, http.StatusOK, lockouts)
+}
+
+// DeleteLockout function for clear the failed logins of a phone and unlock it
+func (a *UserController) DeleteLockout(w http.ResponseWriter, r *http.Request) {
+	phone := chi.URLParam(r, "phone")
+	errReset := a.lockout.Reset(r.Context(), phone)
+	if errReset != nil {
+		response.Error(w, r, "Internal Server Error", types.Error{
+			Path:    ".UserController->DeleteLockout()",
+			Message: errReset.Error(),
+			Error:   errReset,
+			Type:    "golang-error",
+		})
+		return
+	}
+
+	response.JSON(w, http.StatusOK, "Unlock Successful")
+}
+
 // NewUserController creates a new user controller
 func NewUserController(
 	userService user.ServiceInterface,
 	dataManager *data.Manager,
 	utility *u.Utility,
+	limiter *ratelimit.Limiter,
+	lockout *ratelimit.Lockout,
+	phoneLimit int,
+	window time.Duration,
 ) *UserController {
 	return &UserController{
 		userService: userService,
 		dataManager: dataManager,
 		utility:     utility,
+		limiter:     limiter,
+		lockout:     lockout,
+		phoneLimit:  phoneLimit,
+		window:      window,
 	}
 }
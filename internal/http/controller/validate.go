@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/riskiramdan/evos/internal/http/response"
+	"github.com/riskiramdan/evos/internal/types"
+
+	validator "gopkg.in/go-playground/validator.v9"
+)
+
+// errEmptyBody is returned when the request body holds no json value
+var errEmptyBody = types.NewDomainError(types.KindBadRequest, "EmptyBody", "empty request body")
+
+// phonePattern accepts an optional leading + followed by 8 to 15 digits
+var phonePattern = regexp.MustCompile(`^\+?[0-9]{8,15}$`)
+
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	// report the json names of the fields, as sent by the client
+	v.RegisterTagNameFunc(jsonFieldName)
+	v.RegisterValidation("phone", func(fl validator.FieldLevel) bool {
+		return phonePattern.MatchString(fl.Field().String())
+	})
+	return v
+}
+
+func jsonFieldName(field reflect.StructField) string {
+	name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// decodeAndValidate decodes the json request body into params and validates it.
+// On failure the 400 (malformed body) or 422 (field errors) response is written,
+// and false is returned.
+func decodeAndValidate(w http.ResponseWriter, r *http.Request, params interface{}, path string) bool {
+	if _, ok := decode(w, r, params, path); !ok {
+		return false
+	}
+	return validated(w, r, validate.Struct(params), path)
+}
+
+// decodeAndValidatePartial is decodeAndValidate for updates,
+// only the fields present in the request body are validated.
+func decodeAndValidatePartial(w http.ResponseWriter, r *http.Request, params interface{}, path string) bool {
+	body, ok := decode(w, r, params, path)
+	if !ok {
+		return false
+	}
+
+	present := map[string]json.RawMessage{}
+	json.Unmarshal(body, &present)
+
+	fields := []string{}
+	t := reflect.TypeOf(params).Elem()
+	for i := 0; i < t.NumField(); i++ {
+		if _, ok := present[jsonFieldName(t.Field(i))]; ok {
+			fields = append(fields, t.Field(i).Name)
+		}
+	}
+	if len(fields) == 0 {
+		return true
+	}
+	return validated(w, r, validate.StructPartial(params, fields...), path)
+}
+
+func decode(w http.ResponseWriter, r *http.Request, params interface{}, path string) ([]byte, bool) {
+	body, errRead := ioutil.ReadAll(r.Body)
+	if errRead == nil && len(bytes.TrimSpace(body)) == 0 {
+		errRead = errEmptyBody
+	}
+	if errRead == nil {
+		errRead = json.Unmarshal(body, params)
+	}
+	if errRead != nil {
+		response.Error(w, r, "Bad Request", types.Error{
+			Path:    path,
+			Message: errRead.Error(),
+			Error:   errRead,
+			Type:    "golang-error",
+			Kind:    types.KindBadRequest,
+		})
+		return nil, false
+	}
+	return body, true
+}
+
+func validated(w http.ResponseWriter, r *http.Request, errValidate error, path string) bool {
+	if errValidate == nil {
+		return true
+	}
+	response.Error(w, r, "Validation Error", types.Error{
+		Path:    path,
+		Message: errValidate.Error(),
+		Error:   errValidate,
+		Type:    "validation-error",
+		Kind:    types.KindValidation,
+	})
+	return false
+}
@@ -0,0 +1,32 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/riskiramdan/evos/config"
+	"github.com/riskiramdan/evos/internal/http/response"
+	"github.com/riskiramdan/evos/internal/types"
+)
+
+var errRouteNotFound = types.NewDomainError(types.KindNotFound, "RouteNotFound", "no route matches the request")
+
+// legacyErrors answers the requests with the legacy error responses when they are on,
+// the clients asking for application/problem+json still get problems
+func (hs *Server) legacyErrors(next http.Handler) http.Handler {
+	if hs.config.Server.ErrorFormat != config.ErrorFormatLegacy {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(response.WithLegacyErrors(r.Context())))
+	})
+}
+
+// notFound answers the requests matching no route
+func notFound(w http.ResponseWriter, r *http.Request) {
+	response.Error(w, r, errRouteNotFound.Error(), types.Error{
+		Path:    ".Server->notFound()",
+		Message: errRouteNotFound.Error(),
+		Error:   errRouteNotFound,
+		Type:    "routing-error",
+	})
+}
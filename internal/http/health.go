@@ -0,0 +1,67 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/riskiramdan/evos/internal/http/response"
+)
+
+// checkTimeout bounds each readiness check, so a hanging dependency fails the probe
+const checkTimeout = 2 * time.Second
+
+// Check checks a dependency the server needs to serve requests
+type Check func(ctx context.Context) error
+
+// HealthResponse is the body of the liveness & readiness probes
+type HealthResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// getHealthz tells the process is alive, it depends on nothing
+func (hs *Server) getHealthz(w http.ResponseWriter, r *http.Request) {
+	response.JSON(w, http.StatusOK, HealthResponse{Status: "ok"})
+}
+
+// getReadyz tells whether the server can take traffic: it is not shutting down,
+// and every check passes
+func (hs *Server) getReadyz(w http.ResponseWriter, r *http.Request) {
+	if atomic.LoadInt32(&hs.shuttingDown) == 1 {
+		response.JSON(w, http.StatusServiceUnavailable, HealthResponse{Status: "shutting down"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+	defer cancel()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	res := HealthResponse{Status: "ok", Checks: map[string]string{}}
+	for name, check := range hs.readinessChecks {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+			status := "ok"
+			if err := check(ctx); err != nil {
+				status = err.Error()
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			res.Checks[name] = status
+			if status != "ok" {
+				res.Status = "unavailable"
+			}
+		}(name, check)
+	}
+	wg.Wait()
+
+	status := http.StatusOK
+	if res.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+	response.JSON(w, status, res)
+}
@@ -2,52 +2,77 @@ package http
 
 import (
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-func (hs *Server) instrument(method string, path string) func(next http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return promhttp.InstrumentHandlerDuration(
-			httpRequestDuration(method, path).MustCurryWith(prometheus.Labels{"handler": path}),
-			promhttp.InstrumentHandlerCounter(
-				httpRequestsTotalCounterVec(method, path),
-				next,
-			),
-		)
-	}
-}
+// unmatchedRoute labels the requests matching no route, so the unknown paths
+// don't each create their own series
+const unmatchedRoute = "unmatched"
 
-func httpRequestsTotalCounterVec(method string, path string) *prometheus.CounterVec {
-	counter := prometheus.NewCounterVec(
+var (
+	httpRequestsTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
-			Name:        "http_requests_total",
-			Help:        "A counter for requests to the wrapped handler.",
-			ConstLabels: prometheus.Labels{"path": path, "method": method},
+			Name: "http_requests_total",
+			Help: "A counter for requests, by route pattern, method & status code.",
 		},
-		[]string{"code"},
+		[]string{"route", "method", "code"},
 	)
-
-	prometheus.MustRegister(counter)
-
-	return counter
-}
-
-func httpRequestDuration(method string, path string) *prometheus.HistogramVec {
-	// duration is partitioned by the HTTP method and handler. It uses custom
-	// buckets based on the expected request duration.
-	duration := prometheus.NewHistogramVec(
+	httpRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "A histogram of latencies for requests, by route pattern & method.",
+			Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+		},
+		[]string{"route", "method"},
+	)
+	httpResponseSize = prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
-			Name:        "http_request_duration_seconds",
-			Help:        "A histogram of latencies for requests.",
-			Buckets:     []float64{.25, .5, 1, 2.5, 5, 10},
-			ConstLabels: prometheus.Labels{"path": path, "method": method},
+			Name:    "http_response_size_bytes",
+			Help:    "A histogram of response sizes, by route pattern & method.",
+			Buckets: prometheus.ExponentialBuckets(100, 10, 6),
 		},
-		[]string{"handler"},
+		[]string{"route", "method"},
 	)
+	httpRequestsInFlight = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "http_requests_in_flight",
+			Help: "The number of requests being served.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(httpRequestsTotal, httpRequestDuration, httpResponseSize, httpRequestsInFlight)
+}
+
+// instrument records the metrics of every request. The route pattern is only
+// known once chi has routed the request, so it is read after serving it.
+func instrument(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		httpRequestsInFlight.Inc()
+		defer httpRequestsInFlight.Dec()
 
-	prometheus.MustRegister(duration)
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		route := unmatchedRoute
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		httpRequestsTotal.WithLabelValues(route, r.Method, strconv.Itoa(status)).Inc()
+		httpRequestDuration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+		httpResponseSize.WithLabelValues(route, r.Method).Observe(float64(ww.BytesWritten()))
+	}
 
-	return duration
+	return http.HandlerFunc(fn)
 }
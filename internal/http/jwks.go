@@ -0,0 +1,13 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/riskiramdan/evos/internal/http/response"
+)
+
+// getJWKS publishes the public keys used to verify the access tokens
+func (hs *Server) getJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	response.JSON(w, http.StatusOK, hs.keyring.JWKS())
+}
@@ -0,0 +1,419 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/riskiramdan/evos/internal/character"
+	"github.com/riskiramdan/evos/internal/charactertype"
+	"github.com/riskiramdan/evos/internal/http/controller"
+	"github.com/riskiramdan/evos/internal/http/response"
+	"github.com/riskiramdan/evos/internal/keyring"
+	"github.com/riskiramdan/evos/internal/openapi"
+	"github.com/riskiramdan/evos/internal/permission"
+	"github.com/riskiramdan/evos/internal/user"
+)
+
+// docsPage renders the OpenAPI document with Redoc
+const docsPage = `<!DOCTYPE html>
+<html>
+<head>
+<title>evos API</title>
+<meta charset="utf-8"/>
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<style>body { margin: 0; padding: 0; }</style>
+</head>
+<body>
+<redoc spec-url="/openapi.json"></redoc>
+<script src="https://cdn.redoc.ly/redoc/v2.0.0/bundles/redoc.standalone.js"></script>
+</body>
+</html>`
+
+const (
+	mediaJSON   = "application/json"
+	mediaXLSX   = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	mediaCSV    = "text/csv"
+	mediaNDJSON = "application/x-ndjson"
+)
+
+// getOpenAPI serves the OpenAPI document of the routes
+func (hs *Server) getOpenAPI(w http.ResponseWriter, r *http.Request) {
+	response.JSON(w, http.StatusOK, hs.spec)
+}
+
+// getDocs serves the page browsing the OpenAPI document
+func (hs *Server) getDocs(w http.ResponseWriter, r *http.Request) {
+	response.HTML(w, http.StatusOK, docsPage)
+}
+
+// openAPI describes every route of compileRouter, the tests fail when a route is missing
+func openAPI() *openapi.Document {
+	doc := openapi.New(openapi.Info{
+		Title:       "evos",
+		Description: "Characters & character types of evos, their users & their audit trail.",
+		Version:     "1.0.0",
+	})
+
+	doc.Components.SecuritySchemes["bearer"] = &openapi.SecurityScheme{
+		Type:         "http",
+		Scheme:       "bearer",
+		BearerFormat: "JWT",
+		Description:  "The sessionId of the login, or of the refresh.",
+	}
+	doc.Components.Responses["Error"] = &openapi.Response{
+		Description: "The request failed. The legacy shape is answered when the legacy errors are on, unless application/problem+json is accepted.",
+		Content: map[string]*openapi.MediaType{
+			response.ProblemContentType: {Schema: doc.Schema(response.Problem{})},
+			mediaJSON:                   {Schema: doc.Schema(response.ErrorResponse{})},
+		},
+	}
+	doc.Components.Responses["Conflict"] = &openapi.Response{
+		Description: "The resource changed since its version was read: 412 for a stale If-Match, 409 otherwise. The current state is in current.",
+		Headers: map[string]*openapi.Header{
+			"ETag": {Description: "The current version of the resource", Schema: &openapi.Schema{Type: "string"}},
+		},
+		Content: map[string]*openapi.MediaType{
+			response.ProblemContentType: {Schema: doc.Schema(response.Problem{})},
+			mediaJSON:                   {Schema: doc.Schema(response.ConflictResponse{})},
+		},
+	}
+	doc.Components.Responses["TooManyRequests"] = &openapi.Response{
+		Description: "Too many attempts, retry later.",
+		Headers: map[string]*openapi.Header{
+			"Retry-After": {Description: "The seconds to wait before retrying", Schema: &openapi.Schema{Type: "integer"}},
+		},
+		Content: map[string]*openapi.MediaType{
+			response.ProblemContentType: {Schema: doc.Schema(response.Problem{})},
+			mediaJSON:                   {Schema: doc.Schema(response.ErrorResponse{})},
+		},
+	}
+
+	// responses answers v on success, and the errors otherwise
+	responses := func(description string, mediaType string, v interface{}) map[string]*openapi.Response {
+		ok := &openapi.Response{Description: description}
+		switch mediaType {
+		case mediaXLSX, mediaCSV:
+			ok.Content = map[string]*openapi.MediaType{mediaType: {Schema: &openapi.Schema{Type: "string", Format: "binary"}}}
+		default:
+			ok.Content = doc.Content(mediaType, v)
+		}
+		return map[string]*openapi.Response{
+			"200":     ok,
+			"default": {Ref: "#/components/responses/Error"},
+		}
+	}
+	ok := func(description string, v interface{}) map[string]*openapi.Response {
+		return responses(description, mediaJSON, v)
+	}
+	// versioned adds the ETag of the version of the resource to the success, and the conflicts
+	versioned := func(res map[string]*openapi.Response, conflicts bool) map[string]*openapi.Response {
+		res["200"].Headers = map[string]*openapi.Header{
+			"ETag": {Description: "The version of the resource, to send back as If-Match", Schema: &openapi.Schema{Type: "string"}},
+		}
+		if conflicts {
+			res["409"] = &openapi.Response{Ref: "#/components/responses/Conflict"}
+			res["412"] = &openapi.Response{Ref: "#/components/responses/Conflict"}
+		}
+		return res
+	}
+	limited := func(res map[string]*openapi.Response) map[string]*openapi.Response {
+		res["429"] = &openapi.Response{Ref: "#/components/responses/TooManyRequests"}
+		return res
+	}
+	body := func(v interface{}) *openapi.RequestBody {
+		return &openapi.RequestBody{Required: true, Content: doc.Content(mediaJSON, v)}
+	}
+	// secured operations need a bearer token, and a permission when named
+	secured := func(op *openapi.Operation, name string) *openapi.Operation {
+		op.Security = []map[string][]string{{"bearer": {}}}
+		if name != "" {
+			op.Description = join(op.Description, "Needs the `"+name+"` permission.")
+		}
+		return op
+	}
+
+	integer := func() *openapi.Schema { return &openapi.Schema{Type: "integer"} }
+	str := func() *openapi.Schema { return &openapi.Schema{Type: "string"} }
+	boolean := func() *openapi.Schema { return &openapi.Schema{Type: "boolean"} }
+	timestamp := func() *openapi.Schema {
+		return &openapi.Schema{Type: "string", Description: "A RFC 3339 timestamp or a YYYY-MM-DD date"}
+	}
+	pagination := func(params ...*openapi.Parameter) []*openapi.Parameter {
+		return append(params,
+			openapi.QueryParameter("limit", "The page size, 10 by default, 100 at most", integer()),
+			openapi.QueryParameter("after", "The next_cursor of the previous page", str()),
+			openapi.QueryParameter("total", "Counts the total when true", boolean()),
+		)
+	}
+	characterFilters := func() []*openapi.Parameter {
+		return []*openapi.Parameter{
+			openapi.QueryParameter("name", "The name contains it", str()),
+			openapi.QueryParameter("createdBy", "Created by this user", str()),
+			openapi.QueryParameter("characterTypeId", "Of these character types, repeated or comma separated", str()),
+			openapi.QueryParameter("powerMin", "", integer()),
+			openapi.QueryParameter("powerMax", "", integer()),
+			openapi.QueryParameter("valueMin", "", integer()),
+			openapi.QueryParameter("valueMax", "", integer()),
+			openapi.QueryParameter("createdFrom", "", timestamp()),
+			openapi.QueryParameter("createdTo", "", timestamp()),
+			openapi.QueryParameter("updatedFrom", "", timestamp()),
+			openapi.QueryParameter("updatedTo", "", timestamp()),
+			openapi.QueryParameter("sort", "Comma separated fields, a leading - sorts descending, e.g. power,-name", str()),
+		}
+	}
+	upsert := openapi.QueryParameter("upsert", "Updates the characters whose name exists instead of failing them", boolean())
+	ifMatch := openapi.HeaderParameter("If-Match", "The ETag of the version the update is based on", str())
+	characterID := openapi.PathParameter("characterId", "", integer())
+	characterTypeID := openapi.PathParameter("characterTypeId", "", integer())
+	userID := openapi.PathParameter("userId", "", integer())
+
+	// Probes, metrics & documentation
+	//
+
+	doc.Add(http.MethodGet, "/healthz", &openapi.Operation{
+		OperationID: "getHealthz", Tags: []string{"operations"}, Summary: "Liveness probe",
+		Responses: ok("The process is alive", HealthResponse{}),
+	})
+	doc.Add(http.MethodGet, "/readyz", &openapi.Operation{
+		OperationID: "getReadyz", Tags: []string{"operations"}, Summary: "Readiness probe",
+		Description: "Checks the dependencies, answers 503 when one fails or on shutdown.",
+		Responses: map[string]*openapi.Response{
+			"200": {Description: "Ready to serve", Content: doc.Content(mediaJSON, HealthResponse{})},
+			"503": {Description: "Not ready", Content: doc.Content(mediaJSON, HealthResponse{})},
+		},
+	})
+	doc.Add(http.MethodGet, "/metrics", &openapi.Operation{
+		OperationID: "getMetrics", Tags: []string{"operations"}, Summary: "Prometheus metrics",
+		Responses: map[string]*openapi.Response{
+			"200": {Description: "The metrics, in the Prometheus text format", Content: map[string]*openapi.MediaType{"text/plain": {Schema: str()}}},
+		},
+	})
+	doc.Add(http.MethodGet, "/.well-known/jwks.json", &openapi.Operation{
+		OperationID: "getJWKS", Tags: []string{"auth"}, Summary: "Public keys verifying the access tokens",
+		Responses: ok("The JSON Web Key Set", keyring.JWKSet{}),
+	})
+	doc.Add(http.MethodGet, "/openapi.json", &openapi.Operation{
+		OperationID: "getOpenAPI", Tags: []string{"operations"}, Summary: "This OpenAPI document",
+		Responses: map[string]*openapi.Response{
+			"200": {Description: "The OpenAPI document", Content: map[string]*openapi.MediaType{mediaJSON: {Schema: &openapi.Schema{Type: "object"}}}},
+		},
+	})
+	doc.Add(http.MethodGet, "/docs", &openapi.Operation{
+		OperationID: "getDocs", Tags: []string{"operations"}, Summary: "Browse this OpenAPI document",
+		Responses: map[string]*openapi.Response{
+			"200": {Description: "The documentation page", Content: map[string]*openapi.MediaType{"text/html": {Schema: str()}}},
+		},
+	})
+
+	// Authentication
+	//
+
+	doc.Add(http.MethodPost, "/login", &openapi.Operation{
+		OperationID: "login", Tags: []string{"auth"}, Summary: "Log in",
+		Description: "Rate limited per ip & per phone, the phone is locked out after too many failures.",
+		RequestBody: body(user.LoginParams{}),
+		Responses:   limited(ok("The tokens of the new session", user.LoginResponse{})),
+	})
+	doc.Add(http.MethodPost, "/register", &openapi.Operation{
+		OperationID: "register", Tags: []string{"auth"}, Summary: "Register",
+		Description: "The registered user always gets the guest role. Rate limited per ip.",
+		RequestBody: body(user.TransactionParams{}),
+		Responses:   limited(ok("The registered user", user.Users{})),
+	})
+	doc.Add(http.MethodPost, "/auth/refresh", &openapi.Operation{
+		OperationID: "refresh", Tags: []string{"auth"}, Summary: "Rotate the refresh token",
+		RequestBody: body(user.RefreshParams{}),
+		Responses:   ok("The new tokens of the session", user.LoginResponse{}),
+	})
+	doc.Add(http.MethodPost, "/auth/logout", secured(&openapi.Operation{
+		OperationID: "logout", Tags: []string{"auth"}, Summary: "Revoke the current session",
+		Responses: ok("Logged out", ""),
+	}, ""))
+	doc.Add(http.MethodPost, "/auth/logout-all", secured(&openapi.Operation{
+		OperationID: "logoutAll", Tags: []string{"auth"}, Summary: "Revoke every session of the current user",
+		Responses: ok("Logged out", ""),
+	}, ""))
+	doc.Add(http.MethodPut, "/auth/me", secured(&openapi.Operation{
+		OperationID: "updateMe", Tags: []string{"users"}, Summary: "Update the current user",
+		Parameters:  []*openapi.Parameter{ifMatch},
+		RequestBody: body(user.UpdateParams{}),
+		Responses:   versioned(ok("The updated user", user.Users{}), true),
+	}, ""))
+	doc.Add(http.MethodPost, "/auth/me/password", secured(&openapi.Operation{
+		OperationID: "changePassword", Tags: []string{"users"}, Summary: "Change the password of the current user",
+		RequestBody: body(user.ChangePasswordParams{}),
+		Responses:   ok("Password changed", ""),
+	}, ""))
+
+	// Users
+	//
+
+	doc.Add(http.MethodGet, "/auth/users", secured(&openapi.Operation{
+		OperationID: "listUsers", Tags: []string{"users"}, Summary: "List the users",
+		Parameters: pagination(
+			openapi.QueryParameter("name", "The name contains it", str()),
+			openapi.QueryParameter("phone", "The phone contains it", str()),
+		),
+		Responses: ok("A page of users", controller.UserList{}),
+	}, permission.UserRead))
+	doc.Add(http.MethodGet, "/auth/users/{userId}", secured(&openapi.Operation{
+		OperationID: "getUser", Tags: []string{"users"}, Summary: "Get a user",
+		Parameters: []*openapi.Parameter{userID},
+		Responses:  versioned(ok("The user", user.Users{}), false),
+	}, permission.UserRead))
+	doc.Add(http.MethodPut, "/auth/users/{userId}", secured(&openapi.Operation{
+		OperationID: "updateUser", Tags: []string{"users"}, Summary: "Update a user",
+		Parameters:  []*openapi.Parameter{userID, ifMatch},
+		RequestBody: body(user.UpdateParams{}),
+		Responses:   versioned(ok("The updated user", user.Users{}), true),
+	}, permission.UserWrite))
+	doc.Add(http.MethodDelete, "/auth/users/{userId}", secured(&openapi.Operation{
+		OperationID: "deleteUser", Tags: []string{"users"}, Summary: "Soft delete a user",
+		Parameters: []*openapi.Parameter{userID},
+		Responses:  ok("Deleted", ""),
+	}, permission.UserWrite))
+	doc.Add(http.MethodGet, "/auth/lockouts", secured(&openapi.Operation{
+		OperationID: "listLockouts", Tags: []string{"users"}, Summary: "List the phones with failed logins",
+		Description: "The locked out phones first.",
+		Responses:   ok("The failed logins & lockouts", []*controller.Lockout{}),
+	}, permission.UserRead))
+	doc.Add(http.MethodDelete, "/auth/lockouts/{phone}", secured(&openapi.Operation{
+		OperationID: "deleteLockout", Tags: []string{"users"}, Summary: "Unlock a phone",
+		Responses: ok("Unlocked", ""),
+	}, permission.UserWrite))
+	doc.Add(http.MethodGet, "/auth/audit", secured(&openapi.Operation{
+		OperationID: "listAudit", Tags: []string{"audit"}, Summary: "List the audit trail",
+		Parameters: pagination(
+			openapi.QueryParameter("entity", "Of this entity, e.g. character", str()),
+			openapi.QueryParameter("id", "Of the entity with this id", integer()),
+		),
+		Responses: ok("A page of audit logs", controller.AuditList{}),
+	}, permission.AuditRead))
+
+	// Characters
+	//
+
+	doc.Add(http.MethodGet, "/character/list", secured(&openapi.Operation{
+		OperationID: "listCharacters", Tags: []string{"characters"}, Summary: "List the characters",
+		Parameters: pagination(characterFilters()...),
+		Responses:  ok("A page of characters", controller.CharacterList{}),
+	}, permission.CharacterRead))
+	doc.Add(http.MethodGet, "/character/deleted", secured(&openapi.Operation{
+		OperationID: "listDeletedCharacters", Tags: []string{"characters"}, Summary: "List the soft deleted characters",
+		Parameters: pagination(characterFilters()...),
+		Responses:  ok("A page of deleted characters", controller.CharacterList{}),
+	}, permission.CharacterRestore))
+	doc.Add(http.MethodPost, "/character/", secured(&openapi.Operation{
+		OperationID: "createCharacter", Tags: []string{"characters"}, Summary: "Create a character",
+		RequestBody: body(character.TransactionParams{}),
+		Responses:   ok("Created", ""),
+	}, permission.CharacterWrite))
+	doc.Add(http.MethodPost, "/character/bulk", secured(&openapi.Operation{
+		OperationID: "bulkCreateCharacters", Tags: []string{"characters"}, Summary: "Create many characters",
+		Description: "The invalid rows are reported, the valid ones created in a single transaction.",
+		Parameters:  []*openapi.Parameter{upsert},
+		RequestBody: &openapi.RequestBody{
+			Required: true,
+			Content: map[string]*openapi.MediaType{
+				mediaJSON:   {Schema: doc.Schema([]character.TransactionParams{})},
+				mediaNDJSON: {Schema: &openapi.Schema{Type: "string", Description: "A character per line"}},
+			},
+		},
+		Responses: ok("The report of every row", controller.BulkReport{}),
+	}, permission.CharacterWrite))
+	doc.Add(http.MethodGet, "/character/export", secured(&openapi.Operation{
+		OperationID: "exportCharacters", Tags: []string{"characters"}, Summary: "Export the characters as a spreadsheet",
+		Parameters: append(characterFilters(),
+			openapi.QueryParameter("format", "xlsx (the default) or csv", &openapi.Schema{Type: "string", Enum: []interface{}{"xlsx", "csv"}}),
+		),
+		Responses: map[string]*openapi.Response{
+			"200": {Description: "The spreadsheet", Content: map[string]*openapi.MediaType{
+				mediaXLSX: {Schema: &openapi.Schema{Type: "string", Format: "binary"}},
+				mediaCSV:  {Schema: &openapi.Schema{Type: "string", Format: "binary"}},
+			}},
+			"default": {Ref: "#/components/responses/Error"},
+		},
+	}, permission.CharacterRead))
+	doc.Add(http.MethodGet, "/character/import-template", secured(&openapi.Operation{
+		OperationID: "getImportTemplate", Tags: []string{"characters"}, Summary: "Download the empty spreadsheet of the import",
+		Responses: responses("The spreadsheet", mediaXLSX, nil),
+	}, permission.CharacterWrite))
+	doc.Add(http.MethodPost, "/character/import", secured(&openapi.Operation{
+		OperationID: "importCharacters", Tags: []string{"characters"}, Summary: "Create characters from a spreadsheet",
+		Description: "Like the bulk creation, the rows are numbered as in the spreadsheet, the header being row 1.",
+		Parameters:  []*openapi.Parameter{upsert},
+		RequestBody: &openapi.RequestBody{
+			Required: true,
+			Content: map[string]*openapi.MediaType{
+				"multipart/form-data": {Schema: &openapi.Schema{
+					Type: "object",
+					Properties: map[string]*openapi.Schema{
+						"file": {Type: "string", Format: "binary", Description: "A xlsx or csv spreadsheet"},
+					},
+					Required: []string{"file"},
+				}},
+			},
+		},
+		Responses: ok("The report of every row", controller.BulkReport{}),
+	}, permission.CharacterWrite))
+	doc.Add(http.MethodGet, "/character/{characterId}", secured(&openapi.Operation{
+		OperationID: "getCharacter", Tags: []string{"characters"}, Summary: "Get a character",
+		Parameters: []*openapi.Parameter{characterID},
+		Responses:  versioned(ok("The character", character.Characters{}), false),
+	}, permission.CharacterRead))
+	doc.Add(http.MethodPut, "/character/{characterId}", secured(&openapi.Operation{
+		OperationID: "updateCharacter", Tags: []string{"characters"}, Summary: "Update a character",
+		Description: "Only the given fields are changed.",
+		Parameters:  []*openapi.Parameter{characterID, ifMatch},
+		RequestBody: &openapi.RequestBody{Required: true, Content: map[string]*openapi.MediaType{mediaJSON: {Schema: doc.Partial(character.TransactionParams{})}}},
+		Responses:   versioned(ok("Updated", ""), true),
+	}, permission.CharacterWrite))
+	doc.Add(http.MethodDelete, "/character/{characterId}", secured(&openapi.Operation{
+		OperationID: "deleteCharacter", Tags: []string{"characters"}, Summary: "Soft delete a character",
+		Parameters: []*openapi.Parameter{characterID},
+		Responses:  ok("Deleted", ""),
+	}, permission.CharacterDelete))
+	doc.Add(http.MethodPost, "/character/{characterId}/restore", secured(&openapi.Operation{
+		OperationID: "restoreCharacter", Tags: []string{"characters"}, Summary: "Restore a soft deleted character",
+		Parameters: []*openapi.Parameter{characterID},
+		Responses:  ok("The restored character", character.Characters{}),
+	}, permission.CharacterRestore))
+
+	// Character types
+	//
+
+	doc.Add(http.MethodGet, "/character-type/list", secured(&openapi.Operation{
+		OperationID: "listCharacterTypes", Tags: []string{"character types"}, Summary: "List the character types",
+		Parameters: []*openapi.Parameter{
+			openapi.QueryParameter("limit", "The page size, 10 by default", integer()),
+			openapi.QueryParameter("page", "The page, from 1", integer()),
+		},
+		Responses: ok("A page of character types", controller.CharacterTypeList{}),
+	}, permission.CharacterTypeRead))
+	doc.Add(http.MethodGet, "/character-type/{characterTypeId}", secured(&openapi.Operation{
+		OperationID: "getCharacterType", Tags: []string{"character types"}, Summary: "Get a character type",
+		Parameters: []*openapi.Parameter{characterTypeID},
+		Responses:  ok("The character type", charactertype.CharacterTypes{}),
+	}, permission.CharacterTypeRead))
+	doc.Add(http.MethodPost, "/character-type/", secured(&openapi.Operation{
+		OperationID: "createCharacterType", Tags: []string{"character types"}, Summary: "Create a character type",
+		RequestBody: body(charactertype.TransactionParams{}),
+		Responses:   ok("The created character type", charactertype.CharacterTypes{}),
+	}, permission.CharacterTypeWrite))
+	doc.Add(http.MethodPut, "/character-type/{characterTypeId}", secured(&openapi.Operation{
+		OperationID: "updateCharacterType", Tags: []string{"character types"}, Summary: "Update a character type",
+		Description: "Only the given fields are changed.",
+		Parameters:  []*openapi.Parameter{characterTypeID},
+		RequestBody: &openapi.RequestBody{Required: true, Content: map[string]*openapi.MediaType{mediaJSON: {Schema: doc.Partial(charactertype.TransactionParams{})}}},
+		Responses:   ok("The updated character type", charactertype.CharacterTypes{}),
+	}, permission.CharacterTypeWrite))
+
+	return doc
+}
+
+// join joins the sentences of a description
+func join(description string, sentence string) string {
+	if description == "" {
+		return sentence
+	}
+	return description + " " + sentence
+}
@@ -0,0 +1,50 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/riskiramdan/evos/config"
+
+	"github.com/rs/zerolog"
+)
+
+func newTestServer() *Server {
+	return &Server{
+		config: config.Default(),
+		logger: zerolog.Nop(),
+		spec:   openAPI(),
+	}
+}
+
+// Every route of the router is described by the OpenAPI document, and every operation routed
+func TestOpenAPIMatchesRouter(t *testing.T) {
+	hs := newTestServer()
+
+	if err := hs.spec.Check(hs.compileRouter()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetOpenAPI(t *testing.T) {
+	hs := newTestServer()
+	w := httptest.NewRecorder()
+
+	hs.compileRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d", w.Code)
+	}
+	doc := map[string]interface{}{}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc["openapi"] != "3.0.3" {
+		t.Errorf("got openapi %v", doc["openapi"])
+	}
+	if paths, _ := doc["paths"].(map[string]interface{}); paths["/character/{characterId}"] == nil {
+		t.Error("the character path is not described")
+	}
+}
@@ -0,0 +1,50 @@
+package http
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/riskiramdan/evos/internal/http/response"
+	"github.com/riskiramdan/evos/internal/types"
+)
+
+// clientIP returns the ip of the client, middleware.RealIP has already
+// replaced the remote address by the forwarded one when behind a proxy
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// rateLimited limits the number of requests per client ip to the route, named by name
+func (hs *Server) rateLimited(name string, limit int) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			allowed, retryAfter, err := hs.limiter.Allow(r.Context(), name+":ip:"+clientIP(r), limit, hs.config.RateLimit.Window)
+			if err != nil {
+				response.Error(w, r, "Internal Server Error", types.Error{
+					Path:    ".Server->rateLimited()",
+					Message: err.Error(),
+					Error:   err,
+					Type:    "golang-error",
+				})
+				return
+			}
+			if !allowed {
+				response.TooManyRequests(w, r, "Too Many Requests", retryAfter, types.Error{
+					Path:    ".Server->rateLimited()",
+					Message: "rate limit of " + name + " exceeded by " + clientIP(r),
+					Error:   nil,
+					Type:    "",
+				})
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
@@ -3,19 +3,33 @@ package http
 import (
 	"fmt"
 	"net/http"
-	"os"
 	"runtime/debug"
+
+	"github.com/riskiramdan/evos/internal/http/response"
+	"github.com/riskiramdan/evos/internal/logging"
+	"github.com/riskiramdan/evos/internal/types"
 )
 
 // recoverer is a middleware that recovers from panics & logs the panic &
 // returns a HTTP 500 (Internal Server Error) status if possible.
+// It must be used after accessLog, so the panic is logged with the request fields.
 func (hs *Server) recoverer(next http.Handler) http.Handler {
 	fn := func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if rvr := recover(); rvr != nil {
-				fmt.Fprintf(os.Stderr, "Panic: %+v\n", rvr)
-				debug.PrintStack()
-				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				if rvr == http.ErrAbortHandler {
+					panic(rvr)
+				}
+				logging.FromContext(r.Context()).Error().
+					Str("panic", fmt.Sprintf("%+v", rvr)).
+					Str("stack", string(debug.Stack())).
+					Msg("panic")
+				response.Error(w, r, http.StatusText(http.StatusInternalServerError), types.Error{
+					Path:    ".Server->recoverer()",
+					Message: fmt.Sprint(rvr),
+					Error:   fmt.Errorf("panic: %v", rvr),
+					Type:    "panic",
+				})
 			}
 		}()
 
@@ -0,0 +1,19 @@
+package http
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/riskiramdan/evos/internal/appcontext"
+
+	"github.com/go-chi/chi/middleware"
+)
+
+// withRequestID copies the request id set by middleware.RequestID in the app context,
+// so the domain services can read it without depending on chi
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), appcontext.KeyRequestID, middleware.GetReqID(r.Context()))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
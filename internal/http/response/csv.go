@@ -0,0 +1,49 @@
+package response
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// formulaPrefixes start the cells a spreadsheet would evaluate as a formula
+const formulaPrefixes = "=+-@\t\r"
+
+// CSV writes the rows as a csv attachment, the first row being the header.
+// The strings a spreadsheet would evaluate as a formula are prefixed with a quote.
+func CSV(w http.ResponseWriter, status int, filename string, rows [][]interface{}) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Header().Set("Expires", "0")
+	w.WriteHeader(status)
+
+	writer := csv.NewWriter(w)
+	for _, row := range rows {
+		record := make([]string, len(row))
+		for i, v := range row {
+			record[i] = fmt.Sprint(v)
+			if _, ok := v.(string); ok {
+				record[i] = escapeFormula(record[i])
+			}
+		}
+		writer.Write(record)
+	}
+	writer.Flush()
+}
+
+// escapeFormula prefixes a cell starting like a formula with a quote, so it is read as text
+func escapeFormula(cell string) string {
+	if cell != "" && strings.ContainsRune(formulaPrefixes, rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
+
+// UnescapeCSV removes the quote CSV prefixed a cell with, so an exported file imports back as it was
+func UnescapeCSV(cell string) string {
+	if len(cell) > 1 && cell[0] == '\'' && strings.ContainsRune(formulaPrefixes, rune(cell[1])) {
+		return cell[1:]
+	}
+	return cell
+}
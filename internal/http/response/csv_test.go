@@ -0,0 +1,53 @@
+package response
+
+import (
+	"encoding/csv"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCSVEscapesFormulas(t *testing.T) {
+	w := httptest.NewRecorder()
+	CSV(w, 200, "characters.csv", [][]interface{}{
+		{"name", "power"},
+		{"=HYPERLINK(\"http://evil\")", -5},
+		{"+1", 0},
+		{"-1", 0},
+		{"@SUM(A1)", 0},
+		{"\tTab", 0},
+		{"\rReturn", 0},
+		{"Thor", 100},
+	})
+
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"name", "'=HYPERLINK(\"http://evil\")", "'+1", "'-1", "'@SUM(A1)", "'\tTab", "'\rReturn", "Thor"}
+	for i, record := range records {
+		if record[0] != want[i] {
+			t.Errorf("row %d got %q, want %q", i, record[0], want[i])
+		}
+		if UnescapeCSV(record[0]) == want[i] && want[i][0] == '\'' {
+			t.Errorf("row %d is not unescaped", i)
+		}
+	}
+	// the numbers are not user input, a negative one is written as is
+	if records[1][1] != "-5" {
+		t.Errorf("got power %q, want -5", records[1][1])
+	}
+}
+
+func TestUnescapeCSV(t *testing.T) {
+	for cell, want := range map[string]string{
+		"'=1+1": "=1+1",
+		"'-1":   "-1",
+		"'Thor": "'Thor",
+		"'":     "'",
+		"Thor":  "Thor",
+	} {
+		if got := UnescapeCSV(cell); got != want {
+			t.Errorf("UnescapeCSV(%q) got %q, want %q", cell, got, want)
+		}
+	}
+}
@@ -2,32 +2,38 @@ package response
 
 import (
 	"encoding/json"
-	"fmt"
-	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/riskiramdan/evos/internal/logging"
 	"github.com/riskiramdan/evos/internal/types"
 
 	validator "gopkg.in/go-playground/validator.v9"
-
-	"github.com/pkg/errors"
 )
 
 //FieldError represents error message for each field
-//swagger:model
 type FieldError struct {
 	Field   string `json:"field"`
 	Message string `json:"message"`
 }
 
-//ErrorResponse represents error message
-//swagger:model
+//ErrorResponse represents the legacy error message, answered instead of a problem
+//when the legacy errors are on
 type ErrorResponse struct {
 	Code    string        `json:"code"`
 	Message string        `json:"message"`
 	Fields  []*FieldError `json:"fields"`
 }
 
+// ConflictResponse represents the legacy conflicting update error, with the current state of the resource
+type ConflictResponse struct {
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Current interface{} `json:"current"`
+}
+
 // MakeFieldError create field error object
 func MakeFieldError(field string, message string) *FieldError {
 	return &FieldError{
@@ -36,28 +42,44 @@ func MakeFieldError(field string, message string) *FieldError {
 	}
 }
 
-// Error writes error http response
-func Error(w http.ResponseWriter, data string, status int, err types.Error) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-
-	var errorCode string
-	switch status {
-	case http.StatusUnauthorized:
-		errorCode = "Unauthorized"
-	case http.StatusNotFound:
-		errorCode = "NotFound"
-	case http.StatusBadRequest:
-		errorCode = "BadRequest"
-	case http.StatusUnprocessableEntity:
-		errorCode = "ValidationError"
+// statuses maps the kinds of the errors to the http status they are answered with
+var statuses = map[types.Kind]int{
+	types.KindInternal:        http.StatusInternalServerError,
+	types.KindBadRequest:      http.StatusBadRequest,
+	types.KindValidation:      http.StatusUnprocessableEntity,
+	types.KindNotFound:        http.StatusNotFound,
+	types.KindConflict:        http.StatusConflict,
+	types.KindUnauthorized:    http.StatusUnauthorized,
+	types.KindForbidden:       http.StatusForbidden,
+	types.KindTooManyRequests: http.StatusTooManyRequests,
+}
+
+// Status returns the http status of the kind of error
+func Status(kind types.Kind) int {
+	if status, ok := statuses[kind]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// Error writes the error http response, its status & code are the ones of the kind & the
+// code of the error. The message of an internal error is never shown, it may leak details.
+// It is a problem, unless the legacy errors are on.
+func Error(w http.ResponseWriter, r *http.Request, data string, err types.Error) {
+	kind, errorCode := err.Classify()
+	status := Status(kind)
+	if kind == types.KindInternal {
+		data = http.StatusText(http.StatusInternalServerError)
 	}
 
 	errorFields := []*FieldError{}
 
+	// the legacy error responses keep their message
+	legacyData := data
 	switch err.Error.(type) {
 	case validator.ValidationErrors:
-		data = "Bad Request"
+		data = "Validation Error"
+		legacyData = "Bad Request"
 		for _, err := range err.Error.(validator.ValidationErrors) {
 			e := MakeFieldError(
 				err.Field(),
@@ -67,23 +89,74 @@ func Error(w http.ResponseWriter, data string, status int, err types.Error) {
 		}
 	}
 
-	json.NewEncoder(w).Encode(ErrorResponse{
-		Code:    errorCode,
-		Message: data,
-		Fields:  errorFields,
-	})
-
-	if err.Error != nil {
-		log.Printf("INFO: %v\n", err.Error.Error())
-		log.Printf("DETAIL [%s - %s]: %s\n", err.Path, err.Type, err.Message)
-		type stackTracer interface {
-			StackTrace() errors.StackTrace
-		}
+	if wantsProblem(r) {
+		problem := newProblem(r, status, errorCode, data)
+		problem.Fields = errorFields
+		writeProblem(w, problem)
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Code:    errorCode,
+			Message: legacyData,
+			Fields:  errorFields,
+		})
+	}
+
+	record(w, r, err)
+}
 
-		var st errors.StackTrace
-		if err, ok := err.Error.(stackTracer); ok {
-			st = err.StackTrace()
-			fmt.Printf("INFO: %+v\n", st[0])
+// errorSetter is implemented by the response writer of the access log
+type errorSetter interface {
+	SetError(err types.Error)
+}
+
+// record hands the error to the access log, which logs it with the request fields.
+// Outside of it, the error is logged on its own, with the logger of the request.
+func record(w http.ResponseWriter, r *http.Request, err types.Error) {
+	for {
+		if s, ok := w.(errorSetter); ok {
+			s.SetError(err)
+			return
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			break
 		}
+		w = u.Unwrap()
+	}
+	logging.FromContext(r.Context()).Warn().Object("error", err).Msg("request failed")
+}
+
+// Conflict writes the conflict http response of an update,
+// holding the current state of the resource
+func Conflict(w http.ResponseWriter, r *http.Request, status int, err types.Error, current interface{}) {
+	errorCode := "Conflict"
+	if status == http.StatusPreconditionFailed {
+		errorCode = "PreconditionFailed"
 	}
+
+	if wantsProblem(r) {
+		problem := newProblem(r, status, errorCode, err.Message)
+		problem.Current = current
+		writeProblem(w, problem)
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(ConflictResponse{
+			Code:    errorCode,
+			Message: err.Message,
+			Current: current,
+		})
+	}
+
+	record(w, r, err)
+}
+
+// TooManyRequests writes the http response of a rate limited or locked out request,
+// telling the client when to retry
+func TooManyRequests(w http.ResponseWriter, r *http.Request, data string, retryAfter time.Duration, err types.Error) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	err.Kind = types.KindTooManyRequests
+	Error(w, r, data, err)
 }
@@ -0,0 +1,107 @@
+package response
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/riskiramdan/evos/internal/appcontext"
+	"github.com/riskiramdan/evos/internal/types"
+
+	validator "gopkg.in/go-playground/validator.v9"
+)
+
+func validationError(t *testing.T) types.Error {
+	t.Helper()
+	params := struct {
+		Name string `json:"name" validate:"required"`
+	}{}
+	err := validator.New().Struct(params)
+	if err == nil {
+		t.Fatal("the params are valid")
+	}
+	return types.Error{Path: ".Test", Message: err.Error(), Error: err, Type: "validation-error", Kind: types.KindValidation}
+}
+
+func TestErrorProblem(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/character", nil)
+	r = r.WithContext(context.WithValue(r.Context(), appcontext.KeyRequestID, "evos/abc-000042"))
+	w := httptest.NewRecorder()
+
+	Error(w, r, "Validation Error", validationError(t))
+
+	if got := w.Header().Get("Content-Type"); got != ProblemContentType {
+		t.Fatalf("got content type %s", got)
+	}
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("got status %d", w.Code)
+	}
+	body := map[string]interface{}{}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body["requestId"] != "evos/abc-000042" {
+		t.Errorf("got requestId %v", body["requestId"])
+	}
+	if body["detail"] != "Validation Error" || body["code"] != "ValidationError" {
+		t.Errorf("got detail %v, code %v", body["detail"], body["code"])
+	}
+}
+
+// The legacy responses keep the message they had before the problems
+func TestErrorLegacy(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/character", nil)
+	r = r.WithContext(WithLegacyErrors(r.Context()))
+	w := httptest.NewRecorder()
+
+	Error(w, r, "Validation Error", validationError(t))
+
+	if got := w.Header().Get("Content-Type"); got != "application/json" {
+		t.Fatalf("got content type %s", got)
+	}
+	res := ErrorResponse{}
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Message != "Bad Request" || res.Code != "ValidationError" {
+		t.Errorf("got message %q, code %q", res.Message, res.Code)
+	}
+	if len(res.Fields) != 1 || res.Fields[0].Field != "Name" || res.Fields[0].Message != "required" {
+		t.Errorf("got fields %v", res.Fields)
+	}
+}
+
+func TestErrorLegacyAcceptsProblem(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/character/9999", nil)
+	r.Header.Set("Accept", "application/json, application/problem+json;q=0.9")
+	r = r.WithContext(WithLegacyErrors(r.Context()))
+	w := httptest.NewRecorder()
+
+	Error(w, r, "character not found", types.Error{Error: types.NewDomainError(types.KindNotFound, "NotFound", "not found")})
+
+	if got := w.Header().Get("Content-Type"); got != ProblemContentType {
+		t.Fatalf("got content type %s, want a problem", got)
+	}
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("got status %d", w.Code)
+	}
+}
+
+// The message of an internal error is never shown
+func TestErrorInternal(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/character", nil)
+	w := httptest.NewRecorder()
+
+	Error(w, r, "pq: connection refused", types.Error{Error: errors.New("pq: connection refused")})
+
+	problem := Problem{}
+	if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+		t.Fatal(err)
+	}
+	if problem.Status != http.StatusInternalServerError || problem.Detail != "Internal Server Error" {
+		t.Errorf("got status %d, detail %q", problem.Status, problem.Detail)
+	}
+}
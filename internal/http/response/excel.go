@@ -3,15 +3,31 @@ package response
 import (
 	"fmt"
 	"net/http"
-	"strings"
+
+	"github.com/360EntSecGroup-Skylar/excelize"
 )
 
-// EXCEL writes excel http response
-func EXCEL(w http.ResponseWriter, status int, data interface{}) {
-	w.Header().Set("Content-Type", "application/octet-stream")
-	w.Header().Set("Content-Disposition", "attachment; filename="+"Template-Upload.xlsx")
+// EXCEL writes the rows as a xlsx spreadsheet attachment, the first row being the header
+func EXCEL(w http.ResponseWriter, status int, filename string, rows [][]interface{}) {
+	f := excelize.NewFile()
+	sheet := f.GetSheetName(f.GetActiveSheetIndex())
+	for i := range rows {
+		f.SetSheetRow(sheet, fmt.Sprintf("A%d", i+1), &rows[i])
+	}
+	if len(rows) > 0 && len(rows[0]) > 0 {
+		// bold header, with columns wide enough to read it
+		style, err := f.NewStyle(`{"font":{"bold":true}}`)
+		if err == nil {
+			last := excelize.ToAlphaString(len(rows[0]) - 1)
+			f.SetCellStyle(sheet, "A1", last+"1", style)
+		}
+		f.SetColWidth(sheet, "A", excelize.ToAlphaString(len(rows[0])-1), 18)
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
 	w.Header().Set("Content-Transfer-Encoding", "binary")
 	w.Header().Set("Expires", "0")
-	writer := strings.NewReader(string([]byte(fmt.Sprintf("%s", data))))
-	writer.WriteTo(w)
+	w.WriteHeader(status)
+	f.Write(w)
 }
@@ -0,0 +1,78 @@
+package response
+
+import (
+	"context"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/riskiramdan/evos/internal/appcontext"
+)
+
+// ProblemContentType is the media type of the RFC 7807 error responses
+const ProblemContentType = "application/problem+json"
+
+// problemTypePrefix prefixes the code of an error to make the URI of its problem type
+const problemTypePrefix = "urn:evos:error:"
+
+// Problem represents an error response following RFC 7807, extended with the code
+// of the error, the id of the request, the invalid fields & the current state of a
+// conflicting resource
+type Problem struct {
+	Type      string        `json:"type"`
+	Title     string        `json:"title"`
+	Status    int           `json:"status"`
+	Detail    string        `json:"detail,omitempty"`
+	Instance  string        `json:"instance,omitempty"`
+	Code      string        `json:"code"`
+	RequestID string        `json:"requestId,omitempty"`
+	Fields    []*FieldError `json:"fields,omitempty"`
+	Current   interface{}   `json:"current,omitempty"`
+}
+
+type contextKey string
+
+// keyLegacyErrors marks the requests answered with the legacy error responses
+const keyLegacyErrors contextKey = "LegacyErrors"
+
+// WithLegacyErrors returns a copy of the context of a request to answer with the legacy
+// error responses, unless the client accepts application/problem+json
+func WithLegacyErrors(ctx context.Context) context.Context {
+	return context.WithValue(ctx, keyLegacyErrors, true)
+}
+
+// wantsProblem tells whether the request is answered with a problem:
+// always, unless the legacy errors are on & the client did not ask for it
+func wantsProblem(r *http.Request) bool {
+	if legacy, _ := r.Context().Value(keyLegacyErrors).(bool); !legacy {
+		return true
+	}
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err == nil && mediaType == ProblemContentType {
+			return true
+		}
+	}
+	return false
+}
+
+// newProblem creates the problem of the error of a request, its type being made from the code
+func newProblem(r *http.Request, status int, code string, detail string) *Problem {
+	return &Problem{
+		Type:      problemTypePrefix + code,
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		Code:      code,
+		RequestID: appcontext.RequestID(r.Context()),
+	}
+}
+
+// writeProblem writes the problem http response
+func writeProblem(w http.ResponseWriter, problem *Problem) {
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(problem.Status)
+	json.NewEncoder(w).Encode(problem)
+}
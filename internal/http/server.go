@@ -2,43 +2,59 @@ package http
 
 import (
 	"context"
-	"log"
+	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/riskiramdan/evos/config"
+	"github.com/riskiramdan/evos/internal/audit"
 	"github.com/riskiramdan/evos/internal/character"
+	"github.com/riskiramdan/evos/internal/charactertype"
 	"github.com/riskiramdan/evos/internal/data"
 	"github.com/riskiramdan/evos/internal/hosts"
 	"github.com/riskiramdan/evos/internal/http/controller"
+	"github.com/riskiramdan/evos/internal/keyring"
+	"github.com/riskiramdan/evos/internal/openapi"
+	"github.com/riskiramdan/evos/internal/permission"
+	"github.com/riskiramdan/evos/internal/ratelimit"
 	"github.com/riskiramdan/evos/internal/user"
 	"github.com/riskiramdan/evos/util"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/cors"
+	"github.com/rs/zerolog"
 )
 
 // Server represents the http server that handles the requests
 type Server struct {
-	dataManager         *data.Manager
-	utility             *util.Utility
-	config              *config.Config
-	userService         user.ServiceInterface
-	userController      *controller.UserController
-	characterService    character.ServiceInterface
-	characterController *controller.CharacterController
-	httpManager         *hosts.HTTPManager
-	redisManager        *redis.Client
-}
-
-func (hs *Server) authMethod(r chi.Router, method string, path string, handler http.HandlerFunc) {
-	r.With(
-		hs.instrument(method, "/v1"+path),
-	).Method(method, path, handler)
+	dataManager             *data.Manager
+	utility                 *util.Utility
+	config                  *config.Config
+	userService             user.ServiceInterface
+	userController          *controller.UserController
+	characterService        character.ServiceInterface
+	characterController     *controller.CharacterController
+	characterTypeService    charactertype.ServiceInterface
+	characterTypeController *controller.CharacterTypeController
+	permissionService       permission.ServiceInterface
+	auditController         *controller.AuditController
+	keyring                 *keyring.Keyring
+	httpManager             *hosts.HTTPManager
+	redisManager            *redis.Client
+	limiter                 *ratelimit.Limiter
+	readinessChecks         map[string]Check
+	logger                  zerolog.Logger
+	// spec is the OpenAPI document of the routes
+	spec *openapi.Document
+	// shuttingDown is set to 1 once the shutdown started, it fails the readiness probe
+	shuttingDown int32
 }
 
 func (hs *Server) compileRouter() chi.Router {
@@ -48,24 +64,29 @@ func (hs *Server) compileRouter() chi.Router {
 	//
 
 	r.Use(middleware.RequestID)
+	r.Use(withRequestID)
+	r.Use(hs.legacyErrors)
 	r.Use(middleware.RealIP)
-	r.Use(middleware.Recoverer)
+	r.Use(traced)
+	r.Use(hs.accessLog)
+	r.Use(hs.recoverer)
+	r.Use(instrument)
 
 	// Set a timeout value on the request context (ctx), that will signal
 	// through ctx.Done() that the request has timed out and further
 	// processing should be stopped.
-	r.Use(middleware.Timeout(60 * time.Second))
+	r.Use(middleware.Timeout(hs.config.Server.RequestTimeout))
 
 	// Basic CORS
 	//Routes()
 	// for more ideas, see: https://developer.github.com/v3/#cross-origin-resource-sharing
 	cors := cors.New(cors.Options{
 		// AllowedOrigins: []string{"https://foo.com"}, // Use this to allow specific origin hosts
-		AllowedOrigins: []string{"*"},
+		AllowedOrigins: hs.config.Server.CORSAllowedOrigins,
 		// AllowOriginFunc:  func(r *http.Request, origin string) bool { return true },
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "X-Access-Token", "X-Requested-With"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "X-Access-Token", "X-Requested-With", "If-Match"},
+		ExposedHeaders:   []string{"Link", "ETag"},
 		AllowCredentials: true,
 		MaxAge:           300, // Maximum value not ignored by any of major browsers
 	})
@@ -74,21 +95,70 @@ func (hs *Server) compileRouter() chi.Router {
 	// Prometheus handler
 	//
 
+	r.Handle("/metrics", promhttp.Handler())
+
 	// Add routes
 
-	r.HandleFunc("/login", hs.userController.PostLogin)
-	r.HandleFunc("/register", hs.userController.PostCreateUser)
+	r.NotFound(notFound)
+	r.Get("/healthz", hs.getHealthz)
+	r.Get("/readyz", hs.getReadyz)
+	r.Get("/.well-known/jwks.json", hs.getJWKS)
+	r.Get("/openapi.json", hs.getOpenAPI)
+	r.Get("/docs", hs.getDocs)
+	r.With(hs.rateLimited("login", hs.config.RateLimit.Login)).HandleFunc("/login", hs.userController.PostLogin)
+	r.With(hs.rateLimited("register", hs.config.RateLimit.Register)).HandleFunc("/register", hs.userController.PostCreateUser)
 
 	r.Route("/character", func(r chi.Router) {
-		r.Get("/list", hs.characterController.GetListCharacter)
-		r.Post("/", hs.characterController.PostCreateCharacter)
-		r.Put("/{characterId}", hs.characterController.PutUpdateCharacter)
+		r.Use(hs.authorizedOnly(hs.userService))
+
+		r.With(hs.permitted(permission.CharacterRead)).Get("/list", hs.characterController.GetListCharacter)
+		r.With(hs.permitted(permission.CharacterWrite)).Post("/", hs.characterController.PostCreateCharacter)
+		r.With(hs.permitted(permission.CharacterWrite)).Post("/bulk", hs.characterController.PostBulkCreateCharacter)
+		r.With(hs.permitted(permission.CharacterRead)).Get("/export", hs.characterController.GetExportCharacter)
+		r.With(hs.permitted(permission.CharacterWrite)).Get("/import-template", hs.characterController.GetImportTemplate)
+		r.With(hs.permitted(permission.CharacterWrite)).Post("/import", hs.characterController.PostImportCharacter)
+		r.With(hs.permitted(permission.CharacterWrite)).Put("/{characterId}", hs.characterController.PutUpdateCharacter)
+		r.With(hs.permitted(permission.CharacterRead)).Get("/{characterId}", hs.characterController.GetCharacter)
+		r.With(hs.permitted(permission.CharacterDelete)).Delete("/{characterId}", hs.characterController.DeleteCharacter)
+		r.With(hs.permitted(permission.CharacterRestore)).Get("/deleted", hs.characterController.GetListDeletedCharacter)
+		r.With(hs.permitted(permission.CharacterRestore)).Post("/{characterId}/restore", hs.characterController.PostRestoreCharacter)
 	})
 
-	r.Route("/auth", func(r chi.Router) {
+	r.Route("/character-type", func(r chi.Router) {
 		r.Use(hs.authorizedOnly(hs.userService))
 
-		hs.authMethod(r, "GET", "/users", hs.userController.GetListUser)
+		r.With(hs.permitted(permission.CharacterTypeRead)).Get("/list", hs.characterTypeController.GetListCharacterType)
+		r.With(hs.permitted(permission.CharacterTypeRead)).Get("/{characterTypeId}", hs.characterTypeController.GetCharacterType)
+		r.With(hs.permitted(permission.CharacterTypeWrite)).Post("/", hs.characterTypeController.PostCreateCharacterType)
+		r.With(hs.permitted(permission.CharacterTypeWrite)).Put("/{characterTypeId}", hs.characterTypeController.PutUpdateCharacterType)
+	})
+
+	r.Route("/auth", func(r chi.Router) {
+		r.Post("/refresh", hs.userController.PostRefresh)
+
+		r.Group(func(r chi.Router) {
+			r.Use(hs.authorizedOnly(hs.userService))
+
+			r.Post("/logout", hs.userController.PostLogout)
+			r.Post("/logout-all", hs.userController.PostLogoutAll)
+
+			r.Put("/me", hs.userController.PutUpdateMe)
+			r.Post("/me/password", hs.userController.PostChangePassword)
+
+			r.With(hs.permitted(permission.UserRead)).Group(func(r chi.Router) {
+				r.Get("/users", hs.userController.GetListUser)
+				r.Get("/users/{userId}", hs.userController.GetUser)
+				r.Get("/lockouts", hs.userController.GetListLockout)
+			})
+			r.With(hs.permitted(permission.UserWrite)).Group(func(r chi.Router) {
+				r.Put("/users/{userId}", hs.userController.PutUpdateUser)
+				r.Delete("/users/{userId}", hs.userController.DeleteUser)
+				r.Delete("/lockouts/{phone}", hs.userController.DeleteLockout)
+			})
+			r.With(hs.permitted(permission.AuditRead)).Group(func(r chi.Router) {
+				r.Get("/audit", hs.auditController.GetListAudit)
+			})
+		})
 	})
 
 	return r
@@ -104,48 +174,89 @@ func (hs *Server) Serve() {
 	// Run the server + gracefully shutdown mechanism
 	//
 
-	log.Printf("About to listen on 8083. Go to http://127.0.0.1:8083")
-	srv := http.Server{Addr: ":8083", Handler: r}
+	hs.logger.Info().Int("port", hs.config.Server.Port).Msgf("About to listen on %d. Go to http://127.0.0.1:%d", hs.config.Server.Port, hs.config.Server.Port)
+	srv := http.Server{Addr: fmt.Sprintf(":%d", hs.config.Server.Port), Handler: r}
 
 	go func() {
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("listen: %s\n", err)
+			hs.logger.Fatal().Err(err).Msg("listen")
 		}
 	}()
 
 	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, os.Interrupt)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 
 	<-quit
 
-	log.Println("Shutdown Server ...")
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	// fail the readiness probe first, and keep serving while the orchestrator
+	// stops routing new traffic here
+	atomic.StoreInt32(&hs.shuttingDown, 1)
+	hs.logger.Info().Dur("delay", hs.config.Server.ShutdownDelay).Msg("Draining ...")
+	time.Sleep(hs.config.Server.ShutdownDelay)
+
+	hs.logger.Info().Msg("Shutdown Server ...")
+	ctx, cancel := context.WithTimeout(context.Background(), hs.config.Server.ShutdownTimeout)
 	defer cancel()
 	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatal("Server Shutdown:", err)
+		hs.logger.Fatal().Err(err).Msg("Server Shutdown")
 	}
-	log.Println("Server exiting")
+	hs.logger.Info().Msg("Server exiting")
 }
 
 // NewServer creates a new http server
 func NewServer(
 	userService user.ServiceInterface,
 	characterService character.ServiceInterface,
+	characterTypeService charactertype.ServiceInterface,
+	permissionService permission.ServiceInterface,
+	auditService audit.ServiceInterface,
+	keyring *keyring.Keyring,
 	dataManager *data.Manager,
 	config *config.Config,
 	utility *util.Utility,
 	httpManager *hosts.HTTPManager,
+	redisManager *redis.Client,
+	readinessChecks map[string]Check,
+	logger zerolog.Logger,
 ) *Server {
-	userController := controller.NewUserController(userService, dataManager, utility)
+	// the rate limits & lockouts are shared through redis, and kept in memory while it's unavailable
+	var store ratelimit.Store = ratelimit.NewMemoryStore()
+	if redisManager != nil {
+		store = ratelimit.NewFallbackStore(ratelimit.NewRedisStore(redisManager), store)
+	}
+	limiter := ratelimit.NewLimiter(store)
+	lockout := ratelimit.NewLockout(store, config.Lockout.Threshold, config.Lockout.Duration, config.Lockout.MaxDuration)
+
+	userController := controller.NewUserController(
+		userService,
+		dataManager,
+		utility,
+		limiter,
+		lockout,
+		config.RateLimit.LoginPhone,
+		config.RateLimit.Window,
+	)
 	characterController := controller.NewCharacterController(characterService, dataManager)
+	characterTypeController := controller.NewCharacterTypeController(characterTypeService, dataManager)
+	auditController := controller.NewAuditController(auditService)
 	return &Server{
-		dataManager:         dataManager,
-		config:              config,
-		userService:         userService,
-		userController:      userController,
-		characterService:    characterService,
-		characterController: characterController,
-		utility:             utility,
-		httpManager:         httpManager,
+		dataManager:             dataManager,
+		config:                  config,
+		userService:             userService,
+		userController:          userController,
+		characterService:        characterService,
+		characterController:     characterController,
+		characterTypeService:    characterTypeService,
+		characterTypeController: characterTypeController,
+		permissionService:       permissionService,
+		auditController:         auditController,
+		keyring:                 keyring,
+		utility:                 utility,
+		httpManager:             httpManager,
+		redisManager:            redisManager,
+		limiter:                 limiter,
+		readinessChecks:         readinessChecks,
+		logger:                  logger,
+		spec:                    openAPI(),
 	}
 }
@@ -0,0 +1,69 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/riskiramdan/evos/internal/tracing/tracingtest"
+
+	"github.com/go-chi/chi"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTraced(t *testing.T) {
+	spans := tracingtest.Record()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	r := chi.NewRouter()
+	r.Use(traced)
+	r.Get("/character/{characterId}", func(w http.ResponseWriter, r *http.Request) {
+		if chi.URLParam(r, "characterId") == "0" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/character/9999", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/character/0", nil))
+
+	ended := spans()
+	if len(ended) != 2 {
+		t.Fatalf("got %d spans, want 2", len(ended))
+	}
+
+	notFound := ended[0]
+	if notFound.Name() != "GET /character/{characterId}" {
+		t.Errorf("got span name %q, named after the route pattern", notFound.Name())
+	}
+	if notFound.SpanKind() != trace.SpanKindServer {
+		t.Errorf("got span kind %s, want server", notFound.SpanKind())
+	}
+	if got := notFound.SpanContext().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("got trace id %s, the trace of the caller is not continued", got)
+	}
+	if got := tracingtest.Attribute(notFound, "http.route"); got != "/character/{characterId}" {
+		t.Errorf("got http.route %q", got)
+	}
+	if got := tracingtest.Attribute(notFound, "http.status_code"); got != "404" {
+		t.Errorf("got http.status_code %q", got)
+	}
+	// the 4xx are the client's errors
+	if notFound.Status().Code != codes.Unset {
+		t.Errorf("got status %v for a 404, want unset", notFound.Status().Code)
+	}
+
+	failed := ended[1]
+	if failed.Status().Code != codes.Error {
+		t.Errorf("got status %v for a 500, want error", failed.Status().Code)
+	}
+	if failed.Parent().IsValid() {
+		t.Error("a request without trace headers has a parent span")
+	}
+}
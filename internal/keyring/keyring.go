@@ -0,0 +1,258 @@
+package keyring
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/riskiramdan/evos/config"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/rs/zerolog/log"
+)
+
+// Algorithms supported by the keyring
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// Errors
+var (
+	ErrUnknownKey          = errors.New("unknown signing key")
+	ErrUnexpectedAlgorithm = errors.New("unexpected signing algorithm")
+	ErrUnsupportedKey      = errors.New("unsupported key material")
+	ErrNoSigningKey        = errors.New("no signing key configured")
+)
+
+// Key is a JWT key identified by its kid.
+// SigningKey is nil for the keys only kept to verify tokens during rotation.
+type Key struct {
+	ID              string
+	Algorithm       string
+	SigningKey      interface{}
+	VerificationKey interface{}
+}
+
+func (k *Key) method() jwt.SigningMethod {
+	return jwt.GetSigningMethod(k.Algorithm)
+}
+
+// Keyring holds the active signing key and every key accepted for verification
+type Keyring struct {
+	signing *Key
+	keys    map[string]*Key
+}
+
+// New creates a keyring signing with the signing key,
+// and verifying with the signing key & the additional verification keys
+func New(signing *Key, verification ...*Key) (*Keyring, error) {
+	if signing == nil || signing.SigningKey == nil {
+		return nil, fmt.Errorf("keyring: a signing key is required")
+	}
+
+	k := &Keyring{
+		signing: signing,
+		keys:    map[string]*Key{signing.ID: signing},
+	}
+	for _, v := range verification {
+		if _, exists := k.keys[v.ID]; exists {
+			return nil, fmt.Errorf("keyring: duplicate key id %q", v.ID)
+		}
+		k.keys[v.ID] = v
+	}
+	return k, nil
+}
+
+// Sign signs the claims with the active signing key, setting the kid header
+func (k *Keyring) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(k.signing.method(), claims)
+	token.Header["kid"] = k.signing.ID
+	return token.SignedString(k.signing.SigningKey)
+}
+
+// Parse parses & verifies the token with the key matching its kid header.
+// Tokens without kid are verified with the active signing key.
+func (k *Keyring) Parse(tokenString string) (*jwt.Token, error) {
+	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		key := k.signing
+		if kid, ok := token.Header["kid"].(string); ok {
+			key, ok = k.keys[kid]
+			if !ok {
+				return nil, ErrUnknownKey
+			}
+		}
+		if token.Method.Alg() != key.Algorithm {
+			return nil, ErrUnexpectedAlgorithm
+		}
+		return key.VerificationKey, nil
+	})
+}
+
+// JWK represents a public JSON Web Key
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+}
+
+// JWKSet represents a JSON Web Key Set
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys of the keyring, symmetric keys are never exposed
+func (k *Keyring) JWKS() *JWKSet {
+	set := &JWKSet{Keys: []JWK{}}
+	ids := []string{}
+	for id := range k.keys {
+		if id != k.signing.ID {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	ids = append([]string{k.signing.ID}, ids...)
+
+	for _, id := range ids {
+		key := k.keys[id]
+		switch pub := key.VerificationKey.(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				KeyType:   "RSA",
+				KeyID:     key.ID,
+				Use:       "sig",
+				Algorithm: key.Algorithm,
+				N:         base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				KeyType:   "OKP",
+				KeyID:     key.ID,
+				Use:       "sig",
+				Algorithm: key.Algorithm,
+				Curve:     "Ed25519",
+				X:         base64.RawURLEncoding.EncodeToString(pub),
+			})
+		}
+	}
+	return set
+}
+
+// ParseKey parses the key material. PEM encoded RSA & Ed25519 keys are detected
+// from their content, anything else is used as a HS256 secret.
+func ParseKey(id string, material []byte) (*Key, error) {
+	block, _ := pem.Decode(material)
+	if block == nil {
+		secret := []byte(strings.TrimSpace(string(material)))
+		if len(secret) == 0 {
+			return nil, ErrUnsupportedKey
+		}
+		return &Key{ID: id, Algorithm: AlgHS256, SigningKey: secret, VerificationKey: secret}, nil
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, ErrUnsupportedKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch key := parsed.(type) {
+	case *rsa.PrivateKey:
+		return &Key{ID: id, Algorithm: AlgRS256, SigningKey: key, VerificationKey: &key.PublicKey}, nil
+	case *rsa.PublicKey:
+		return &Key{ID: id, Algorithm: AlgRS256, VerificationKey: key}, nil
+	case ed25519.PrivateKey:
+		return &Key{ID: id, Algorithm: AlgEdDSA, SigningKey: key, VerificationKey: key.Public()}, nil
+	case ed25519.PublicKey:
+		return &Key{ID: id, Algorithm: AlgEdDSA, VerificationKey: key}, nil
+	}
+	return nil, ErrUnsupportedKey
+}
+
+// NewFromConfig loads the keyring from the signing key & the verification key files
+// of the configuration. Without any signing key configured, an ephemeral HS256 key is
+// generated when the configuration allows it, so the issued tokens do not survive a restart.
+func NewFromConfig(cfg config.JWTConfig) (*Keyring, error) {
+	material := []byte(cfg.SigningKey)
+	if cfg.SigningKeyFile != "" {
+		b, err := ioutil.ReadFile(cfg.SigningKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("keyring: error when reading signing key: %v", err)
+		}
+		material = b
+	}
+
+	var signing *Key
+	if len(material) == 0 && !cfg.EphemeralKey {
+		return nil, ErrNoSigningKey
+	}
+	if len(material) == 0 {
+		log.Warn().Msg("no JWT signing key configured, using an ephemeral key")
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		signing = &Key{ID: cfg.KeyID, Algorithm: AlgHS256, SigningKey: secret, VerificationKey: secret}
+	} else {
+		key, err := ParseKey(cfg.KeyID, material)
+		if err != nil {
+			return nil, fmt.Errorf("keyring: error when parsing signing key: %v", err)
+		}
+		if key.SigningKey == nil {
+			return nil, fmt.Errorf("keyring: the signing key %q is a public key", cfg.KeyID)
+		}
+		signing = key
+	}
+
+	verification := []*Key{}
+	for _, entry := range strings.Split(cfg.VerificationKeys, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("keyring: invalid verification key %q, expected kid=path", entry)
+		}
+		b, err := ioutil.ReadFile(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("keyring: error when reading verification key %q: %v", parts[0], err)
+		}
+		key, err := ParseKey(parts[0], b)
+		if err != nil {
+			return nil, fmt.Errorf("keyring: error when parsing verification key %q: %v", parts[0], err)
+		}
+		key.SigningKey = nil
+		verification = append(verification, key)
+	}
+
+	return New(signing, verification...)
+}
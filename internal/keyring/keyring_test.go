@@ -0,0 +1,39 @@
+package keyring
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/riskiramdan/evos/config"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func TestNewFromConfigWithoutSigningKey(t *testing.T) {
+	_, err := NewFromConfig(config.JWTConfig{KeyID: "default"})
+	if !errors.Is(err, ErrNoSigningKey) {
+		t.Fatalf("got %v, want ErrNoSigningKey", err)
+	}
+}
+
+func TestNewFromConfigEphemeralKey(t *testing.T) {
+	k, err := NewFromConfig(config.JWTConfig{KeyID: "default", EphemeralKey: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := k.Sign(jwt.MapClaims{"sub": "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := k.Parse(token); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewFromConfigSigningKey(t *testing.T) {
+	// a configured key wins over the ephemeral one
+	_, err := NewFromConfig(config.JWTConfig{KeyID: "default", SigningKey: "secret", EphemeralKey: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
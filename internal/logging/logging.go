@@ -0,0 +1,102 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/riskiramdan/evos/config"
+	"github.com/riskiramdan/evos/internal/types"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+type contextKey string
+
+const (
+	keyLogger  contextKey = "Logger"
+	keyRequest contextKey = "Request"
+)
+
+// New creates the logger of the configuration, writing to stdout
+func New(cfg config.LogConfig) (zerolog.Logger, error) {
+	level, err := zerolog.ParseLevel(cfg.Level)
+	if err != nil {
+		return zerolog.Nop(), err
+	}
+
+	var w io.Writer = os.Stdout
+	if cfg.Format == config.LogFormatConsole {
+		w = zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}
+	}
+	zerolog.DurationFieldUnit = time.Millisecond
+	return zerolog.New(w).Level(level).With().Timestamp().Logger(), nil
+}
+
+// FromContext returns the logger of the request of the context, with its fields,
+// or the global logger outside of a request
+func FromContext(ctx context.Context) *zerolog.Logger {
+	if l, ok := ctx.Value(keyLogger).(*zerolog.Logger); ok {
+		return l
+	}
+	return &log.Logger
+}
+
+// WithLogger returns a copy of the context logging with l
+func WithLogger(ctx context.Context, l zerolog.Logger) context.Context {
+	return context.WithValue(ctx, keyLogger, &l)
+}
+
+// Request holds the fields of a request known once it is being served,
+// for its access log line
+type Request struct {
+	mu     sync.Mutex
+	userID int
+	err    *types.Error
+}
+
+// UserID returns the authenticated user of the request, 0 when anonymous
+func (r *Request) UserID() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.userID
+}
+
+// Err returns the error the request was answered with, if any
+func (r *Request) Err() *types.Error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// SetError records the error the request is answered with
+func (r *Request) SetError(err types.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.err = &err
+}
+
+// WithRequest returns a copy of the context holding the request fields
+func WithRequest(ctx context.Context, r *Request) context.Context {
+	return context.WithValue(ctx, keyRequest, r)
+}
+
+// RequestFromContext returns the request fields of the context, nil outside of a request
+func RequestFromContext(ctx context.Context) *Request {
+	r, _ := ctx.Value(keyRequest).(*Request)
+	return r
+}
+
+// WithUserID records the authenticated user of the request, on its access log line
+// and on every line logged with the returned context
+func WithUserID(ctx context.Context, userID int) context.Context {
+	if r := RequestFromContext(ctx); r != nil {
+		r.mu.Lock()
+		r.userID = userID
+		r.mu.Unlock()
+	}
+	return WithLogger(ctx, FromContext(ctx).With().Int("user_id", userID).Logger())
+}
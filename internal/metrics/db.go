@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"database/sql"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// StatsGetter is a connection pool, *sql.DB or *sqlx.DB
+type StatsGetter interface {
+	Stats() sql.DBStats
+}
+
+// DBStatsCollector collects the connection pool stats of a database, read on every scrape
+type DBStatsCollector struct {
+	db StatsGetter
+
+	maxOpenConnections *prometheus.Desc
+	openConnections    *prometheus.Desc
+	inUse              *prometheus.Desc
+	idle               *prometheus.Desc
+	waitCount          *prometheus.Desc
+	waitDuration       *prometheus.Desc
+	maxIdleClosed      *prometheus.Desc
+	maxIdleTimeClosed  *prometheus.Desc
+	maxLifetimeClosed  *prometheus.Desc
+}
+
+// NewDBStatsCollector creates the collector of the pool stats of the database, labelled with its name
+func NewDBStatsCollector(name string, db StatsGetter) *DBStatsCollector {
+	labels := prometheus.Labels{"db_name": name}
+	desc := func(metric string, help string) *prometheus.Desc {
+		return prometheus.NewDesc("db_"+metric, help, nil, labels)
+	}
+	return &DBStatsCollector{
+		db:                 db,
+		maxOpenConnections: desc("max_open_connections", "Maximum number of open connections to the database."),
+		openConnections:    desc("open_connections", "The number of established connections both in use and idle."),
+		inUse:              desc("in_use_connections", "The number of connections currently in use."),
+		idle:               desc("idle_connections", "The number of idle connections."),
+		waitCount:          desc("wait_count_total", "The total number of connections waited for."),
+		waitDuration:       desc("wait_duration_seconds_total", "The total time blocked waiting for a new connection."),
+		maxIdleClosed:      desc("max_idle_closed_total", "The total number of connections closed due to SetMaxIdleConns."),
+		maxIdleTimeClosed:  desc("max_idle_time_closed_total", "The total number of connections closed due to SetConnMaxIdleTime."),
+		maxLifetimeClosed:  desc("max_lifetime_closed_total", "The total number of connections closed due to SetConnMaxLifetime."),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *DBStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.maxOpenConnections
+	ch <- c.openConnections
+	ch <- c.inUse
+	ch <- c.idle
+	ch <- c.waitCount
+	ch <- c.waitDuration
+	ch <- c.maxIdleClosed
+	ch <- c.maxIdleTimeClosed
+	ch <- c.maxLifetimeClosed
+}
+
+// Collect implements prometheus.Collector
+func (c *DBStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.db.Stats()
+	ch <- prometheus.MustNewConstMetric(c.maxOpenConnections, prometheus.GaugeValue, float64(stats.MaxOpenConnections))
+	ch <- prometheus.MustNewConstMetric(c.openConnections, prometheus.GaugeValue, float64(stats.OpenConnections))
+	ch <- prometheus.MustNewConstMetric(c.inUse, prometheus.GaugeValue, float64(stats.InUse))
+	ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(stats.Idle))
+	ch <- prometheus.MustNewConstMetric(c.waitCount, prometheus.CounterValue, float64(stats.WaitCount))
+	ch <- prometheus.MustNewConstMetric(c.waitDuration, prometheus.CounterValue, stats.WaitDuration.Seconds())
+	ch <- prometheus.MustNewConstMetric(c.maxIdleClosed, prometheus.CounterValue, float64(stats.MaxIdleClosed))
+	ch <- prometheus.MustNewConstMetric(c.maxIdleTimeClosed, prometheus.CounterValue, float64(stats.MaxIdleTimeClosed))
+	ch <- prometheus.MustNewConstMetric(c.maxLifetimeClosed, prometheus.CounterValue, float64(stats.MaxLifetimeClosed))
+}
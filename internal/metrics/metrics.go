@@ -0,0 +1,38 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// login results, the values of the result label of Logins
+const (
+	LoginSuccess = "success"
+	LoginFailure = "failure"
+	LoginLimited = "limited"
+)
+
+var (
+	// CharactersCreated counts the committed character creations, bulk ones included
+	CharactersCreated = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "evos_characters_created_total",
+			Help: "A counter for the created characters.",
+		},
+	)
+	// Logins counts the login attempts by result
+	Logins = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "evos_logins_total",
+			Help: "A counter for the login attempts, by result: success, failure (wrong credentials) or limited (rate limited or locked out).",
+		},
+		[]string{"result"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(CharactersCreated, Logins)
+	// the results are exported from the start, even before any login
+	for _, result := range []string{LoginSuccess, LoginFailure, LoginLimited} {
+		Logins.WithLabelValues(result)
+	}
+}
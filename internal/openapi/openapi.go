@@ -0,0 +1,170 @@
+package openapi
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Version is the version of the OpenAPI specification the documents follow
+const Version = "3.0.3"
+
+// Document is an OpenAPI document, describing the operations of an API
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+	// schemas maps the go types to the name of their component schema
+	schemas map[reflect.Type]string
+}
+
+// Info describes the API
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// PathItem holds the operations of a path, by their lowercase method
+type PathItem map[string]*Operation
+
+// Operation describes an operation of the API
+type Operation struct {
+	OperationID string                `json:"operationId,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []*Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+// Parameter describes a path, query or header parameter of an operation
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody describes the body of a request, by its media type
+type RequestBody struct {
+	Description string                `json:"description,omitempty"`
+	Required    bool                  `json:"required,omitempty"`
+	Content     map[string]*MediaType `json:"content"`
+}
+
+// Response describes a response of an operation, or refers to a shared one
+type Response struct {
+	Ref         string                `json:"$ref,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Headers     map[string]*Header    `json:"headers,omitempty"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// Header describes a header of a response
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// MediaType describes a body of a media type
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Components holds the schemas, responses & security schemes shared by the operations
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	Responses       map[string]*Response       `json:"responses,omitempty"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme describes how the operations are authenticated
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+// pathParameter matches the parameters of a route pattern, e.g. {characterId}
+var pathParameter = regexp.MustCompile(`{([^}:]+)(:[^}]*)?}`)
+
+// New creates an empty document
+func New(info Info) *Document {
+	return &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   map[string]PathItem{},
+		Components: Components{
+			Schemas:         map[string]*Schema{},
+			Responses:       map[string]*Response{},
+			SecuritySchemes: map[string]*SecurityScheme{},
+		},
+		schemas: map[reflect.Type]string{},
+	}
+}
+
+// Add describes the operation of a route, the parameters of its pattern it does not
+// declare are added as required strings
+func (d *Document) Add(method string, pattern string, op *Operation) {
+	declared := map[string]bool{}
+	for _, p := range op.Parameters {
+		if p.In == "path" {
+			declared[p.Name] = true
+		}
+	}
+	for _, match := range pathParameter.FindAllStringSubmatch(pattern, -1) {
+		if !declared[match[1]] {
+			op.Parameters = append(op.Parameters, PathParameter(match[1], "", &Schema{Type: "string"}))
+		}
+	}
+
+	path := pathParameter.ReplaceAllString(pattern, "{$1}")
+	if d.Paths[path] == nil {
+		d.Paths[path] = PathItem{}
+	}
+	d.Paths[path][strings.ToLower(method)] = op
+}
+
+// Content describes a body of a single media type holding a value like v
+func (d *Document) Content(mediaType string, v interface{}) map[string]*MediaType {
+	return map[string]*MediaType{
+		mediaType: {Schema: d.Schema(v)},
+	}
+}
+
+// PathParameter describes a parameter of the path
+func PathParameter(name string, description string, schema *Schema) *Parameter {
+	return &Parameter{
+		Name:        name,
+		In:          "path",
+		Description: description,
+		Required:    true,
+		Schema:      schema,
+	}
+}
+
+// QueryParameter describes an optional parameter of the query
+func QueryParameter(name string, description string, schema *Schema) *Parameter {
+	return &Parameter{
+		Name:        name,
+		In:          "query",
+		Description: description,
+		Schema:      schema,
+	}
+}
+
+// HeaderParameter describes an optional header of the request
+func HeaderParameter(name string, description string, schema *Schema) *Parameter {
+	return &Parameter{
+		Name:        name,
+		In:          "header",
+		Description: description,
+		Schema:      schema,
+	}
+}
@@ -0,0 +1,71 @@
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/go-chi/chi"
+)
+
+// anyMethod are the methods a route handling any method is walked with
+var anyMethod = []string{
+	http.MethodConnect, http.MethodDelete, http.MethodGet, http.MethodHead, http.MethodOptions,
+	http.MethodPatch, http.MethodPost, http.MethodPut, http.MethodTrace,
+}
+
+// Check tells whether the document describes the routes of the router: it fails listing the
+// routes without an operation & the operations without a route. A route handling any method
+// is described by an operation of any method on its path.
+func (d *Document) Check(routes chi.Routes) error {
+	methods := map[string]map[string]bool{}
+	errWalk := chi.Walk(routes, func(method string, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		path := pathParameter.ReplaceAllString(route, "{$1}")
+		if methods[path] == nil {
+			methods[path] = map[string]bool{}
+		}
+		methods[path][strings.ToLower(method)] = true
+		return nil
+	})
+	if errWalk != nil {
+		return errWalk
+	}
+
+	problems := []string{}
+	for path, routed := range methods {
+		if handlesAnyMethod(routed) {
+			if len(d.Paths[path]) == 0 {
+				problems = append(problems, "no operation for the route * "+path)
+			}
+			continue
+		}
+		for method := range routed {
+			if d.Paths[path][method] == nil {
+				problems = append(problems, "no operation for the route "+strings.ToUpper(method)+" "+path)
+			}
+		}
+	}
+	for path, item := range d.Paths {
+		routed := methods[path]
+		for method := range item {
+			if !routed[method] {
+				problems = append(problems, "no route for the operation "+strings.ToUpper(method)+" "+path)
+			}
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("the OpenAPI document does not match the router: %s", strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+func handlesAnyMethod(routed map[string]bool) bool {
+	for _, method := range anyMethod {
+		if !routed[strings.ToLower(method)] {
+			return false
+		}
+	}
+	return true
+}
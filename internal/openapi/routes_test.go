@@ -0,0 +1,65 @@
+package openapi
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+)
+
+func newRouter() chi.Router {
+	r := chi.NewRouter()
+	noop := func(w http.ResponseWriter, r *http.Request) {}
+	r.Get("/character/{characterId:[0-9]+}", noop)
+	r.Post("/character", noop)
+	r.HandleFunc("/metrics", noop)
+	return r
+}
+
+func newDocument() *Document {
+	d := New(Info{Title: "test", Version: "1"})
+	d.Add(http.MethodGet, "/character/{characterId:[0-9]+}", &Operation{})
+	d.Add(http.MethodPost, "/character", &Operation{})
+	d.Add(http.MethodGet, "/metrics", &Operation{})
+	return d
+}
+
+func TestCheck(t *testing.T) {
+	if err := newDocument().Check(newRouter()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckMissingOperation(t *testing.T) {
+	r := newRouter()
+	r.Delete("/character/{characterId}", func(w http.ResponseWriter, r *http.Request) {})
+
+	err := newDocument().Check(r)
+	if err == nil || !strings.Contains(err.Error(), "no operation for the route DELETE /character/{characterId}") {
+		t.Fatalf("got %v", err)
+	}
+}
+
+func TestCheckMissingRoute(t *testing.T) {
+	d := newDocument()
+	d.Add(http.MethodPut, "/character/{characterId}", &Operation{})
+
+	err := d.Check(newRouter())
+	if err == nil || !strings.Contains(err.Error(), "no route for the operation PUT /character/{characterId}") {
+		t.Fatalf("got %v", err)
+	}
+}
+
+func TestAddPathParameters(t *testing.T) {
+	d := New(Info{Title: "test", Version: "1"})
+	op := &Operation{}
+	d.Add(http.MethodGet, "/character/{characterId:[0-9]+}", op)
+
+	if d.Paths["/character/{characterId}"]["get"] != op {
+		t.Fatal("the operation is not added under the path without its pattern")
+	}
+	if len(op.Parameters) != 1 || op.Parameters[0].Name != "characterId" || !op.Parameters[0].Required {
+		t.Errorf("got parameters %v", op.Parameters)
+	}
+}
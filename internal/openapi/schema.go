@@ -0,0 +1,200 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema describes a value, or refers to a component schema
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// componentSchemas prefixes the references to the component schemas
+const componentSchemas = "#/components/schemas/"
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// Schema describes a value like v, as the json encoding writes it.
+// The named structs are described once, as component schemas named after their
+// package & type, e.g. controller.CharacterList, and referred to.
+// The validate tags of the fields add their constraints: required, min, max & oneof.
+func (d *Document) Schema(v interface{}) *Schema {
+	return d.schemaOf(reflect.TypeOf(v))
+}
+
+// Partial describes a value like v, none of its fields being required, as a partial update
+// takes it. A component schema is described again, named after it with a Partial suffix.
+func (d *Document) Partial(v interface{}) *Schema {
+	s := d.Schema(v)
+	if s.Ref == "" {
+		s.Required = nil
+		return s
+	}
+	name := strings.TrimPrefix(s.Ref, componentSchemas)
+	if _, ok := d.Components.Schemas[name+"Partial"]; !ok {
+		partial := *d.Components.Schemas[name]
+		partial.Required = nil
+		d.Components.Schemas[name+"Partial"] = &partial
+	}
+	return &Schema{Ref: componentSchemas + name + "Partial"}
+}
+
+func (d *Document) schemaOf(t reflect.Type) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case rawMessageType:
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		s := d.schemaOf(t.Elem())
+		if s.Ref == "" {
+			s.Nullable = true
+		}
+		return s
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: d.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return d.structSchema(t)
+		}
+		return d.component(t)
+	}
+	// interfaces hold any value
+	return &Schema{}
+}
+
+// component refers to the component schema of a named struct, describing it the first time
+func (d *Document) component(t reflect.Type) *Schema {
+	name, ok := d.schemas[t]
+	if !ok {
+		name = t.String()
+		// registered first, a struct may refer to itself
+		d.schemas[t] = name
+		d.Components.Schemas[name] = d.structSchema(t)
+	}
+	return &Schema{Ref: componentSchemas + name}
+}
+
+func (d *Document) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	d.addFields(s, t)
+	return s
+}
+
+// addFields describes the exported fields of a struct, the ones of its embedded structs included
+func (d *Document) addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				d.addFields(s, embedded)
+				continue
+			}
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := d.schemaOf(field.Type)
+		if constrain(property, field.Tag.Get("validate")) {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = property
+	}
+}
+
+// constrain adds the constraints of a validate tag to the schema of a field,
+// telling whether the field is required
+func constrain(s *Schema, tag string) (required bool) {
+	for _, rule := range strings.Split(tag, ",") {
+		parts := strings.SplitN(rule, "=", 2)
+		if parts[0] == "required" {
+			required = true
+		}
+		// the constraints can't be added next to a reference
+		if len(parts) < 2 || s.Ref != "" {
+			continue
+		}
+		switch parts[0] {
+		case "min", "max":
+			bound, err := strconv.ParseFloat(parts[1], 64)
+			if err != nil {
+				continue
+			}
+			switch {
+			case s.Type == "string" && parts[0] == "min":
+				length := int(bound)
+				s.MinLength = &length
+			case s.Type == "string":
+				length := int(bound)
+				s.MaxLength = &length
+			case (s.Type == "integer" || s.Type == "number") && parts[0] == "min":
+				s.Minimum = &bound
+			case s.Type == "integer" || s.Type == "number":
+				s.Maximum = &bound
+			}
+		case "oneof":
+			if s.Type != "string" {
+				continue
+			}
+			for _, value := range strings.Fields(parts[1]) {
+				s.Enum = append(s.Enum, value)
+			}
+		}
+	}
+	return required
+}
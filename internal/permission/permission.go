@@ -0,0 +1,113 @@
+package permission
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/riskiramdan/evos/internal/types"
+)
+
+// Permission names checked by the http layer
+const (
+	CharacterRead      = "character:read"
+	CharacterWrite     = "character:write"
+	CharacterDelete    = "character:delete"
+	CharacterRestore   = "character:restore"
+	CharacterTypeRead  = "character-type:read"
+	CharacterTypeWrite = "character-type:write"
+	UserRead           = "user:read"
+	UserWrite          = "user:write"
+	AuditRead          = "audit:read"
+)
+
+// Permissions permission
+type Permissions struct {
+	ID          int        `json:"id" db:"id"`
+	Name        string     `json:"name" db:"name"`
+	Description *string    `json:"description" db:"description"`
+	CreatedAt   time.Time  `json:"createdAt" db:"createdAt"`
+	UpdatedAt   *time.Time `json:"updatedAt" db:"updatedAt"`
+}
+
+// Storage represents the permission storage interface
+type Storage interface {
+	FindByRoleID(ctx context.Context, roleID int) ([]*Permissions, *types.Error)
+}
+
+// ServiceInterface represents the permission service interface
+type ServiceInterface interface {
+	ListRolePermissions(ctx context.Context, roleID int) ([]*Permissions, *types.Error)
+	HasPermission(ctx context.Context, roleID int, name string) (bool, *types.Error)
+}
+
+// Service is the domain logic implementation of permission Service interface
+type Service struct {
+	permissionStorage Storage
+	// ttl is how long the permissions of a role are kept in memory, they are checked
+	// on every request and only change with the seeder
+	ttl time.Duration
+
+	mu    sync.Mutex
+	roles map[int]*rolePermissions
+}
+
+// rolePermissions are the permissions of a role kept in memory
+type rolePermissions struct {
+	permissions []*Permissions
+	expiresAt   time.Time
+}
+
+// ListRolePermissions is listing the permissions granted to a role
+func (s *Service) ListRolePermissions(ctx context.Context, roleID int) ([]*Permissions, *types.Error) {
+	s.mu.Lock()
+	cached, ok := s.roles[roleID]
+	s.mu.Unlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.permissions, nil
+	}
+
+	permissions, err := s.permissionStorage.FindByRoleID(ctx, roleID)
+	if err != nil {
+		err.Path = ".PermissionService->ListRolePermissions()" + err.Path
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.roles[roleID] = &rolePermissions{
+		permissions: permissions,
+		expiresAt:   time.Now().Add(s.ttl),
+	}
+	s.mu.Unlock()
+
+	return permissions, nil
+}
+
+// HasPermission checks whether the role is granted the named permission
+func (s *Service) HasPermission(ctx context.Context, roleID int, name string) (bool, *types.Error) {
+	permissions, err := s.ListRolePermissions(ctx, roleID)
+	if err != nil {
+		err.Path = ".PermissionService->HasPermission()" + err.Path
+		return false, err
+	}
+
+	for _, p := range permissions {
+		if p.Name == name {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// NewService creates a new permission AppService
+func NewService(
+	permissionStorage Storage,
+	ttl time.Duration,
+) *Service {
+	return &Service{
+		permissionStorage: permissionStorage,
+		ttl:               ttl,
+		roles:             map[int]*rolePermissions{},
+	}
+}
@@ -0,0 +1,55 @@
+package permission
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/riskiramdan/evos/internal/types"
+)
+
+type countingStorage struct {
+	calls int
+}
+
+func (s *countingStorage) FindByRoleID(ctx context.Context, roleID int) ([]*Permissions, *types.Error) {
+	s.calls++
+	return []*Permissions{{ID: 1, Name: CharacterRead}}, nil
+}
+
+func TestListRolePermissionsCached(t *testing.T) {
+	ctx := context.Background()
+	storage := &countingStorage{}
+	s := NewService(storage, time.Minute)
+
+	for i := 0; i < 3; i++ {
+		permissions, err := s.ListRolePermissions(ctx, 1)
+		if err != nil {
+			t.Fatal(err.Error)
+		}
+		if len(permissions) != 1 || permissions[0].Name != CharacterRead {
+			t.Fatalf("got %v", permissions)
+		}
+	}
+	if storage.calls != 1 {
+		t.Fatalf("the storage is queried %d times, want 1", storage.calls)
+	}
+
+	s.ListRolePermissions(ctx, 2)
+	if storage.calls != 2 {
+		t.Fatalf("the storage is queried %d times for another role, want 2", storage.calls)
+	}
+}
+
+func TestListRolePermissionsExpired(t *testing.T) {
+	ctx := context.Background()
+	storage := &countingStorage{}
+	s := NewService(storage, time.Nanosecond)
+
+	s.ListRolePermissions(ctx, 1)
+	time.Sleep(time.Millisecond)
+	s.ListRolePermissions(ctx, 1)
+	if storage.calls != 2 {
+		t.Fatalf("the storage is queried %d times, want 2", storage.calls)
+	}
+}
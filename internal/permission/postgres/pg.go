@@ -0,0 +1,48 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/riskiramdan/evos/internal/data"
+	"github.com/riskiramdan/evos/internal/permission"
+	"github.com/riskiramdan/evos/internal/types"
+)
+
+// Storage implements the permission storage service interface
+type Storage struct {
+	Storage data.GenericStorage
+}
+
+// FindByRoleID find all permissions granted to the role
+func (s *Storage) FindByRoleID(ctx context.Context, roleID int) ([]*permission.Permissions, *types.Error) {
+	permissions := []*permission.Permissions{}
+	query := `
+	SELECT p."id", p."name", p."description", p."createdAt", p."updatedAt"
+	FROM "permissions" p
+	JOIN "rolePermissions" rp ON rp."permissionId" = p."id"
+	WHERE rp."roleId" = :roleId AND p."deletedAt" IS NULL
+	ORDER BY p."name" ASC`
+
+	err := s.Storage.SelectWithQuery(ctx, &permissions, query, map[string]interface{}{
+		"roleId": roleID,
+	})
+	if err != nil {
+		return nil, &types.Error{
+			Path:    ".PermissionStorage->FindByRoleID()",
+			Message: err.Error(),
+			Error:   err,
+			Type:    "pq-error",
+		}
+	}
+
+	return permissions, nil
+}
+
+// NewPostgresStorage creates new permission repository service
+func NewPostgresStorage(
+	storage data.GenericStorage,
+) *Storage {
+	return &Storage{
+		Storage: storage,
+	}
+}
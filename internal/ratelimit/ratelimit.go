@@ -0,0 +1,143 @@
+package ratelimit
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Limiter limits the number of hits of a key in fixed time windows
+type Limiter struct {
+	store Store
+}
+
+// Allow counts a hit of the key and tells whether it is within the limit of the window.
+// Over the limit, the time left until the window resets is returned.
+func (l *Limiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, time.Duration, error) {
+	hits, remaining, err := l.store.Incr(ctx, "evos:ratelimit:"+key, window)
+	if err != nil {
+		return false, 0, err
+	}
+	if hits > int64(limit) {
+		return false, remaining, nil
+	}
+	return true, 0, nil
+}
+
+// NewLimiter creates a rate limiter counting the hits in the store
+func NewLimiter(store Store) *Limiter {
+	return &Limiter{
+		store: store,
+	}
+}
+
+const (
+	failuresPrefix = "evos:lockout:failures:"
+	lockedPrefix   = "evos:lockout:locked:"
+	// failureWindow is how long the failed attempts are remembered after the first one
+	failureWindow = 24 * time.Hour
+)
+
+// LockoutState is the failed attempts & the lock of an account
+type LockoutState struct {
+	ID          string     `json:"id"`
+	Failures    int64      `json:"failures"`
+	LockedUntil *time.Time `json:"lockedUntil"`
+}
+
+// Lockout locks an account out after too many failed attempts. The lock lasts Duration
+// once Threshold attempts failed, and doubles with every further failure up to MaxDuration.
+type Lockout struct {
+	store       Store
+	threshold   int
+	duration    time.Duration
+	maxDuration time.Duration
+}
+
+// Locked returns the time left on the lock of the account, 0 when it is not locked
+func (l *Lockout) Locked(ctx context.Context, id string) (time.Duration, error) {
+	locked, remaining, err := l.store.Get(ctx, lockedPrefix+id)
+	if err != nil || locked == 0 {
+		return 0, err
+	}
+	return remaining, nil
+}
+
+// Fail counts a failed attempt on the account, and returns how long it got locked for, if it did
+func (l *Lockout) Fail(ctx context.Context, id string) (time.Duration, error) {
+	failures, _, err := l.store.Incr(ctx, failuresPrefix+id, failureWindow)
+	if err != nil {
+		return 0, err
+	}
+	if failures < int64(l.threshold) {
+		return 0, nil
+	}
+
+	lock := l.duration
+	for i := int64(l.threshold); i < failures && lock < l.maxDuration; i++ {
+		lock *= 2
+	}
+	if lock > l.maxDuration {
+		lock = l.maxDuration
+	}
+	err = l.store.Set(ctx, lockedPrefix+id, 1, lock)
+	if err != nil {
+		return 0, err
+	}
+	return lock, nil
+}
+
+// Reset forgets the failed attempts on the account and unlocks it
+func (l *Lockout) Reset(ctx context.Context, id string) error {
+	return l.store.Del(ctx, failuresPrefix+id, lockedPrefix+id)
+}
+
+// List lists the accounts with failed attempts, the locked ones first
+func (l *Lockout) List(ctx context.Context) ([]*LockoutState, error) {
+	keys, err := l.store.Keys(ctx, failuresPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	states := []*LockoutState{}
+	for _, key := range keys {
+		state := &LockoutState{ID: strings.TrimPrefix(key, failuresPrefix)}
+		state.Failures, _, err = l.store.Get(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if state.Failures == 0 {
+			// expired since listed
+			continue
+		}
+		remaining, err := l.Locked(ctx, state.ID)
+		if err != nil {
+			return nil, err
+		}
+		if remaining > 0 {
+			lockedUntil := now.Add(remaining)
+			state.LockedUntil = &lockedUntil
+		}
+		states = append(states, state)
+	}
+
+	sort.Slice(states, func(i, j int) bool {
+		if (states[i].LockedUntil != nil) != (states[j].LockedUntil != nil) {
+			return states[i].LockedUntil != nil
+		}
+		return states[i].ID < states[j].ID
+	})
+	return states, nil
+}
+
+// NewLockout creates the lockout counting the failed attempts in the store
+func NewLockout(store Store, threshold int, duration time.Duration, maxDuration time.Duration) *Lockout {
+	return &Lockout{
+		store:       store,
+		threshold:   threshold,
+		duration:    duration,
+		maxDuration: maxDuration,
+	}
+}
@@ -0,0 +1,291 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/riskiramdan/evos/internal/logging"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Store holds expiring counters
+type Store interface {
+	// Incr increments the counter of the key and returns its new value, a new
+	// counter expires after ttl. The remaining time to live is returned too.
+	Incr(ctx context.Context, key string, ttl time.Duration) (int64, time.Duration, error)
+	// Get returns the counter of the key and its remaining time to live, 0 when it doesn't exist
+	Get(ctx context.Context, key string) (int64, time.Duration, error)
+	// Set sets the counter of the key, expiring after ttl
+	Set(ctx context.Context, key string, value int64, ttl time.Duration) error
+	// Del deletes the counters of the keys
+	Del(ctx context.Context, keys ...string) error
+	// Keys lists the keys starting with the prefix
+	Keys(ctx context.Context, prefix string) ([]string, error)
+}
+
+// RedisStore keeps the counters in redis, shared by every instance
+type RedisStore struct {
+	client *redis.Client
+}
+
+// incrScript increments a counter, setting the expiration of a new one, atomically
+var incrScript = redis.NewScript(`
+local value = redis.call("INCR", KEYS[1])
+if redis.call("PTTL", KEYS[1]) < 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return {value, redis.call("PTTL", KEYS[1])}
+`)
+
+// Incr increments the counter of the key
+func (s *RedisStore) Incr(ctx context.Context, key string, ttl time.Duration) (int64, time.Duration, error) {
+	res, err := incrScript.Run(ctx, s.client, []string{key}, ttl.Milliseconds()).Result()
+	if err != nil {
+		return 0, 0, err
+	}
+	values, ok := res.([]interface{})
+	if !ok || len(values) != 2 {
+		return 0, 0, fmt.Errorf("unexpected incr result %v", res)
+	}
+	value, _ := values[0].(int64)
+	pttl, _ := values[1].(int64)
+	return value, time.Duration(pttl) * time.Millisecond, nil
+}
+
+// Get returns the counter of the key
+func (s *RedisStore) Get(ctx context.Context, key string) (int64, time.Duration, error) {
+	pipe := s.client.Pipeline()
+	get := pipe.Get(ctx, key)
+	pttl := pipe.PTTL(ctx, key)
+	_, err := pipe.Exec(ctx)
+	if err == redis.Nil {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	value, err := get.Int64()
+	if err != nil {
+		return 0, 0, err
+	}
+	return value, pttl.Val(), nil
+}
+
+// Set sets the counter of the key
+func (s *RedisStore) Set(ctx context.Context, key string, value int64, ttl time.Duration) error {
+	return s.client.Set(ctx, key, value, ttl).Err()
+}
+
+// Del deletes the counters of the keys
+func (s *RedisStore) Del(ctx context.Context, keys ...string) error {
+	return s.client.Del(ctx, keys...).Err()
+}
+
+// Keys lists the keys starting with the prefix
+func (s *RedisStore) Keys(ctx context.Context, prefix string) ([]string, error) {
+	keys := []string{}
+	iter := s.client.Scan(ctx, 0, prefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	return keys, iter.Err()
+}
+
+// NewRedisStore creates a store keeping the counters in redis
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{
+		client: client,
+	}
+}
+
+type counter struct {
+	value     int64
+	expiredAt time.Time
+}
+
+// MemoryStore keeps the counters in memory, they are local to the instance
+type MemoryStore struct {
+	mu       sync.Mutex
+	counters map[string]*counter
+	sweptAt  time.Time
+}
+
+// get returns the live counter of the key, it must be called with the lock held
+func (s *MemoryStore) get(key string, now time.Time) *counter {
+	// the expired counters are swept at most once a minute
+	if now.Sub(s.sweptAt) > time.Minute {
+		for k, c := range s.counters {
+			if !now.Before(c.expiredAt) {
+				delete(s.counters, k)
+			}
+		}
+		s.sweptAt = now
+	}
+
+	c, ok := s.counters[key]
+	if !ok || !now.Before(c.expiredAt) {
+		return nil
+	}
+	return c
+}
+
+// Incr increments the counter of the key
+func (s *MemoryStore) Incr(ctx context.Context, key string, ttl time.Duration) (int64, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	c := s.get(key, now)
+	if c == nil {
+		c = &counter{expiredAt: now.Add(ttl)}
+		s.counters[key] = c
+	}
+	c.value++
+	return c.value, c.expiredAt.Sub(now), nil
+}
+
+// Get returns the counter of the key
+func (s *MemoryStore) Get(ctx context.Context, key string) (int64, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	c := s.get(key, now)
+	if c == nil {
+		return 0, 0, nil
+	}
+	return c.value, c.expiredAt.Sub(now), nil
+}
+
+// Set sets the counter of the key
+func (s *MemoryStore) Set(ctx context.Context, key string, value int64, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.counters[key] = &counter{value: value, expiredAt: time.Now().Add(ttl)}
+	return nil
+}
+
+// Del deletes the counters of the keys
+func (s *MemoryStore) Del(ctx context.Context, keys ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range keys {
+		delete(s.counters, key)
+	}
+	return nil
+}
+
+// Keys lists the keys starting with the prefix
+func (s *MemoryStore) Keys(ctx context.Context, prefix string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	keys := []string{}
+	for key, c := range s.counters {
+		if strings.HasPrefix(key, prefix) && now.Before(c.expiredAt) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// NewMemoryStore creates a store keeping the counters in memory
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		counters: map[string]*counter{},
+	}
+}
+
+// downFor is how long the primary store is skipped after an error, so an outage
+// doesn't add its timeouts to every request
+const downFor = 5 * time.Second
+
+// FallbackStore uses the primary store, and the fallback one while the primary fails
+type FallbackStore struct {
+	primary  Store
+	fallback Store
+
+	mu        sync.Mutex
+	downUntil time.Time
+}
+
+func (s *FallbackStore) store() Store {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if time.Now().Before(s.downUntil) {
+		return s.fallback
+	}
+	return s.primary
+}
+
+func (s *FallbackStore) failed(ctx context.Context, err error) {
+	logging.FromContext(ctx).Warn().Err(err).Msg("rate limit store unavailable, using the in-memory one")
+	s.mu.Lock()
+	s.downUntil = time.Now().Add(downFor)
+	s.mu.Unlock()
+}
+
+// Incr increments the counter of the key
+func (s *FallbackStore) Incr(ctx context.Context, key string, ttl time.Duration) (int64, time.Duration, error) {
+	value, remaining, err := s.store().Incr(ctx, key, ttl)
+	if err != nil {
+		s.failed(ctx, err)
+		return s.fallback.Incr(ctx, key, ttl)
+	}
+	return value, remaining, nil
+}
+
+// Get returns the counter of the key
+func (s *FallbackStore) Get(ctx context.Context, key string) (int64, time.Duration, error) {
+	value, remaining, err := s.store().Get(ctx, key)
+	if err != nil {
+		s.failed(ctx, err)
+		return s.fallback.Get(ctx, key)
+	}
+	return value, remaining, nil
+}
+
+// Set sets the counter of the key
+func (s *FallbackStore) Set(ctx context.Context, key string, value int64, ttl time.Duration) error {
+	err := s.store().Set(ctx, key, value, ttl)
+	if err != nil {
+		s.failed(ctx, err)
+		return s.fallback.Set(ctx, key, value, ttl)
+	}
+	return nil
+}
+
+// Del deletes the counters of the keys from both stores
+func (s *FallbackStore) Del(ctx context.Context, keys ...string) error {
+	s.fallback.Del(ctx, keys...)
+	err := s.primary.Del(ctx, keys...)
+	if err != nil {
+		s.failed(ctx, err)
+	}
+	return nil
+}
+
+// Keys lists the keys starting with the prefix
+func (s *FallbackStore) Keys(ctx context.Context, prefix string) ([]string, error) {
+	keys, err := s.store().Keys(ctx, prefix)
+	if err != nil {
+		s.failed(ctx, err)
+		return s.fallback.Keys(ctx, prefix)
+	}
+	return keys, nil
+}
+
+// NewFallbackStore creates a store falling back to the fallback store while the primary fails
+func NewFallbackStore(primary Store, fallback Store) *FallbackStore {
+	return &FallbackStore{
+		primary:  primary,
+		fallback: fallback,
+	}
+}
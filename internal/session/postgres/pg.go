@@ -0,0 +1,136 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/riskiramdan/evos/internal/data"
+	"github.com/riskiramdan/evos/internal/session"
+	"github.com/riskiramdan/evos/internal/types"
+)
+
+// Storage implements the session storage service interface
+type Storage struct {
+	Storage data.GenericStorage
+}
+
+// FindAll find all sessions
+func (s *Storage) FindAll(ctx context.Context, params *session.FindAllSessionsParams) ([]*session.Sessions, *types.Error) {
+
+	sessions := []*session.Sessions{}
+	where := `true`
+
+	if params.ID != 0 {
+		where += ` AND "id" = :id`
+	}
+	if params.UserID != 0 {
+		where += ` AND "userId" = :userId`
+	}
+	if params.RefreshTokenHash != "" {
+		where += ` AND "refreshTokenHash" = :refreshTokenHash`
+	}
+	if params.PreviousRefreshTokenHash != "" {
+		where += ` AND "previousRefreshTokenHash" = :previousRefreshTokenHash`
+	}
+	if params.OnlyActive {
+		where += ` AND "revokedAt" IS NULL AND "expiredAt" > :now`
+	}
+	where += ` ORDER BY "createdAt" DESC`
+
+	err := s.Storage.Where(ctx, &sessions, where, map[string]interface{}{
+		"id":                       params.ID,
+		"userId":                   params.UserID,
+		"refreshTokenHash":         params.RefreshTokenHash,
+		"previousRefreshTokenHash": params.PreviousRefreshTokenHash,
+		"now":                      time.Now(),
+	})
+	if err != nil {
+		return nil, &types.Error{
+			Path:    ".SessionStorage->FindAll()",
+			Message: err.Error(),
+			Error:   err,
+			Type:    "pq-error",
+		}
+	}
+
+	return sessions, nil
+}
+
+func (s *Storage) findOne(ctx context.Context, path string, params *session.FindAllSessionsParams) (*session.Sessions, *types.Error) {
+	sessions, err := s.FindAll(ctx, params)
+	if err != nil {
+		err.Path = path + err.Path
+		return nil, err
+	}
+
+	if len(sessions) < 1 {
+		return nil, &types.Error{
+			Path:    path,
+			Message: data.ErrNotFound.Error(),
+			Error:   data.ErrNotFound,
+			Type:    "pq-error",
+		}
+	}
+
+	return sessions[0], nil
+}
+
+// FindByID find session by its id
+func (s *Storage) FindByID(ctx context.Context, sessionID int) (*session.Sessions, *types.Error) {
+	return s.findOne(ctx, ".SessionStorage->FindByID()", &session.FindAllSessionsParams{
+		ID: sessionID,
+	})
+}
+
+// FindByRefreshTokenHash find session by the hash of its current refresh token
+func (s *Storage) FindByRefreshTokenHash(ctx context.Context, hash string) (*session.Sessions, *types.Error) {
+	return s.findOne(ctx, ".SessionStorage->FindByRefreshTokenHash()", &session.FindAllSessionsParams{
+		RefreshTokenHash: hash,
+	})
+}
+
+// FindByPreviousRefreshTokenHash find session by the hash of its already rotated refresh token
+func (s *Storage) FindByPreviousRefreshTokenHash(ctx context.Context, hash string) (*session.Sessions, *types.Error) {
+	return s.findOne(ctx, ".SessionStorage->FindByPreviousRefreshTokenHash()", &session.FindAllSessionsParams{
+		PreviousRefreshTokenHash: hash,
+	})
+}
+
+// Insert insert session
+func (s *Storage) Insert(ctx context.Context, session *session.Sessions) (*session.Sessions, *types.Error) {
+	err := s.Storage.Insert(ctx, session)
+	if err != nil {
+		return nil, &types.Error{
+			Path:    ".SessionStorage->Insert()",
+			Message: err.Error(),
+			Error:   err,
+			Type:    "pq-error",
+		}
+	}
+
+	return session, nil
+}
+
+// Update update session
+func (s *Storage) Update(ctx context.Context, session *session.Sessions) (*session.Sessions, *types.Error) {
+	err := s.Storage.Update(ctx, session)
+	if err != nil {
+		return nil, &types.Error{
+			Path:    ".SessionStorage->Update()",
+			Message: err.Error(),
+			Error:   err,
+			Type:    "pq-error",
+		}
+	}
+
+	return session, nil
+}
+
+// NewPostgresStorage creates new session repository service
+func NewPostgresStorage(
+	storage data.GenericStorage,
+) *Storage {
+	return &Storage{
+		Storage: storage,
+	}
+}
@@ -0,0 +1,47 @@
+package session
+
+import (
+	"context"
+	"time"
+
+	"github.com/riskiramdan/evos/internal/types"
+)
+
+// Sessions session, one per logged-in device
+type Sessions struct {
+	ID                       int        `json:"id" db:"id"`
+	UserID                   int        `json:"userId" db:"userId"`
+	RefreshTokenHash         string     `json:"-" db:"refreshTokenHash"`
+	PreviousRefreshTokenHash *string    `json:"-" db:"previousRefreshTokenHash"`
+	UserAgent                *string    `json:"userAgent" db:"userAgent"`
+	IPAddress                *string    `json:"ipAddress" db:"ipAddress"`
+	ExpiredAt                time.Time  `json:"expiredAt" db:"expiredAt"`
+	LastUsedAt               *time.Time `json:"lastUsedAt" db:"lastUsedAt"`
+	RevokedAt                *time.Time `json:"revokedAt" db:"revokedAt"`
+	CreatedAt                time.Time  `json:"createdAt" db:"createdAt"`
+	UpdatedAt                *time.Time `json:"updatedAt" db:"updatedAt"`
+}
+
+// Active reports whether the session is neither revoked nor expired
+func (s *Sessions) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiredAt)
+}
+
+// FindAllSessionsParams params for find all
+type FindAllSessionsParams struct {
+	ID                       int    `json:"id"`
+	UserID                   int    `json:"userId"`
+	RefreshTokenHash         string `json:"-"`
+	PreviousRefreshTokenHash string `json:"-"`
+	OnlyActive               bool   `json:"onlyActive"`
+}
+
+// Storage represents the session storage interface
+type Storage interface {
+	FindAll(ctx context.Context, params *FindAllSessionsParams) ([]*Sessions, *types.Error)
+	FindByID(ctx context.Context, sessionID int) (*Sessions, *types.Error)
+	FindByRefreshTokenHash(ctx context.Context, hash string) (*Sessions, *types.Error)
+	FindByPreviousRefreshTokenHash(ctx context.Context, hash string) (*Sessions, *types.Error)
+	Insert(ctx context.Context, session *Sessions) (*Sessions, *types.Error)
+	Update(ctx context.Context, session *Sessions) (*Sessions, *types.Error)
+}
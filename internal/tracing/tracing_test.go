@@ -0,0 +1,45 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/riskiramdan/evos/internal/tracing/tracingtest"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+)
+
+var errExpected = errors.New("not found")
+
+func TestEnd(t *testing.T) {
+	spans := tracingtest.Record()
+	tracer := otel.Tracer("test")
+
+	_, ok := tracer.Start(context.Background(), "ok")
+	End(ok, nil)
+	_, failed := tracer.Start(context.Background(), "failed")
+	End(failed, errors.New("connection refused"))
+	_, expected := tracer.Start(context.Background(), "expected")
+	End(expected, fmt.Errorf("character 9999: %w", errExpected), errExpected)
+
+	ended := spans()
+	for name, want := range map[string]codes.Code{
+		"ok":       codes.Unset,
+		"failed":   codes.Error,
+		"expected": codes.Unset,
+	} {
+		span := tracingtest.Find(ended, name)
+		if span == nil {
+			t.Fatalf("span %s not ended", name)
+		}
+		if span.Status().Code != want {
+			t.Errorf("span %s got status %v, want %v", name, span.Status().Code, want)
+		}
+	}
+	if events := tracingtest.Find(ended, "failed").Events(); len(events) != 1 || events[0].Name != "exception" {
+		t.Errorf("got events %v, want the recorded error", events)
+	}
+}
@@ -0,0 +1,48 @@
+// Package tracingtest records the spans of the tests
+package tracingtest
+
+import (
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var (
+	recorder = tracetest.NewSpanRecorder()
+	once     sync.Once
+)
+
+// Record sets the global tracer provider up to record the spans, once, as the tracers
+// of the packages are only bound to the first one set. The returned function lists
+// the spans ended since, the tests recording spans must not run in parallel.
+func Record() func() []sdktrace.ReadOnlySpan {
+	once.Do(func() {
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	})
+	start := len(recorder.Ended())
+	return func() []sdktrace.ReadOnlySpan {
+		return recorder.Ended()[start:]
+	}
+}
+
+// Find returns the first span of the name, nil if none
+func Find(spans []sdktrace.ReadOnlySpan, name string) sdktrace.ReadOnlySpan {
+	for _, span := range spans {
+		if span.Name() == name {
+			return span
+		}
+	}
+	return nil
+}
+
+// Attribute returns the value of the attribute of the span, empty if not set
+func Attribute(span sdktrace.ReadOnlySpan, key string) string {
+	for _, kv := range span.Attributes() {
+		if string(kv.Key) == key {
+			return kv.Value.Emit()
+		}
+	}
+	return ""
+}
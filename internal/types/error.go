@@ -1,11 +1,56 @@
 package types
 
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
 //Error represents customized error object
-//swagger:model
 type Error struct {
 	Path     string
 	Message  string
 	Error    error
 	Type     string
 	IsIgnore bool
+	// Kind & Code classify the error, when not set they are the ones of the DomainError it wraps
+	Kind Kind
+	Code string
+}
+
+// Classify returns the kind & the machine-readable code of the error: the ones set on it,
+// else the ones of the DomainError it wraps, else the ones of an internal error
+func (e Error) Classify() (Kind, string) {
+	kind, code := e.Kind, e.Code
+	var domainErr *DomainError
+	if errors.As(e.Error, &domainErr) {
+		if kind == KindInternal {
+			kind = domainErr.Kind
+		}
+		// a code of another kind would contradict the status
+		if code == "" && kind == domainErr.Kind {
+			code = domainErr.Code
+		}
+	}
+	if code == "" {
+		code = kind.String()
+	}
+	return kind, code
+}
+
+// MarshalZerologObject logs the error as structured fields
+func (e Error) MarshalZerologObject(ev *zerolog.Event) {
+	if e.Error != nil {
+		ev.Str("error", e.Error.Error())
+	}
+	kind, code := e.Classify()
+	ev.Str("path", e.Path).
+		Str("type", e.Type).
+		Str("kind", kind.String()).
+		Str("code", code).
+		Str("message", e.Message)
+	if st, ok := e.Error.(interface{ StackTrace() errors.StackTrace }); ok && len(st.StackTrace()) > 0 {
+		ev.Str("at", fmt.Sprintf("%+v", st.StackTrace()[0]))
+	}
 }
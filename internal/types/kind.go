@@ -0,0 +1,58 @@
+package types
+
+// Kind classifies an error, it decides the http status the error is answered with
+type Kind int
+
+// Kinds of errors, an error of no kind is internal
+const (
+	KindInternal Kind = iota
+	KindBadRequest
+	KindValidation
+	KindNotFound
+	KindConflict
+	KindUnauthorized
+	KindForbidden
+	KindTooManyRequests
+)
+
+// String returns the name of the kind, also the code of the errors of the kind without their own
+func (k Kind) String() string {
+	switch k {
+	case KindBadRequest:
+		return "BadRequest"
+	case KindValidation:
+		return "ValidationError"
+	case KindNotFound:
+		return "NotFound"
+	case KindConflict:
+		return "Conflict"
+	case KindUnauthorized:
+		return "Unauthorized"
+	case KindForbidden:
+		return "Forbidden"
+	case KindTooManyRequests:
+		return "TooManyRequests"
+	}
+	return "InternalError"
+}
+
+// DomainError is a sentinel error of a domain, carrying its kind & its machine-readable code.
+// It is found with errors.Is / errors.As when wrapped.
+type DomainError struct {
+	Kind    Kind
+	Code    string
+	Message string
+}
+
+func (e *DomainError) Error() string {
+	return e.Message
+}
+
+// NewDomainError creates a sentinel error of a domain
+func NewDomainError(kind Kind, code string, message string) error {
+	return &DomainError{
+		Kind:    kind,
+		Code:    code,
+		Message: message,
+	}
+}
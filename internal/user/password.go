@@ -0,0 +1,87 @@
+package user
+
+import (
+	"crypto/subtle"
+	"strings"
+	"sync"
+
+	"github.com/riskiramdan/evos/internal/types"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// passwordCost is the bcrypt cost used for newly hashed passwords.
+// Stored hashes with a lower cost are re-hashed on the next login.
+const passwordCost = 12
+
+// minPasswordLength is the minimum length accepted for new passwords
+const minPasswordLength = 8
+
+func hashPassword(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), passwordCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
+}
+
+// newPasswordHash checks the length of a new password and hashes it
+func newPasswordHash(password string) (string, *types.Error) {
+	if len(password) < minPasswordLength {
+		return "", &types.Error{
+			Path:    ".newPasswordHash()",
+			Message: ErrInvalidPassword.Error(),
+			Error:   ErrInvalidPassword,
+			Type:    "validation-error",
+		}
+	}
+	hashed, err := hashPassword(password)
+	if err != nil {
+		return "", &types.Error{
+			Path:    ".newPasswordHash()",
+			Message: err.Error(),
+			Error:   err,
+			Type:    "golang-error",
+		}
+	}
+	return hashed, nil
+}
+
+func isHashedPassword(stored string) bool {
+	for _, prefix := range []string{"$2a$", "$2b$", "$2y$"} {
+		if strings.HasPrefix(stored, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyPassword compares the password against the stored one in constant time.
+// The stored password may still be a legacy plaintext one, in that case
+// rehash is true on match so the caller can store the hashed password instead.
+func verifyPassword(stored string, password string) (match bool, rehash bool) {
+	if !isHashedPassword(stored) {
+		match = subtle.ConstantTimeCompare([]byte(stored), []byte(password)) == 1
+		return match, match
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)) != nil {
+		return false, false
+	}
+	cost, err := bcrypt.Cost([]byte(stored))
+	return true, err == nil && cost < passwordCost
+}
+
+var (
+	dummyHash     []byte
+	dummyHashOnce sync.Once
+)
+
+// verifyDummyPassword spends the time of a password verification,
+// so an unknown phone can't be told apart from a wrong password by timing
+func verifyDummyPassword(password string) {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), passwordCost)
+	})
+	bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+}
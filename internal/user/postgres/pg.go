@@ -14,10 +14,12 @@ type Storage struct {
 	Storage data.GenericStorage
 }
 
-// FindAll find all users
-func (s *Storage) FindAll(ctx context.Context, params *user.FindAllUsersParams) ([]*user.Users, *types.Error) {
+// sortColumns maps the sort fields to their column
+var sortColumns = map[string]string{
+	"createdAt": `"createdAt"`,
+}
 
-	users := []*user.Users{}
+func filter(params *user.FindAllUsersParams) (string, map[string]interface{}) {
 	where := `"deletedAt" IS NULL`
 
 	if params.ID != 0 {
@@ -29,23 +31,39 @@ func (s *Storage) FindAll(ctx context.Context, params *user.FindAllUsersParams)
 	if params.Name != "" {
 		where += ` AND "name" ILIKE :name`
 	}
-	if params.Token != "" {
-		where += ` AND "token" = :token`
+
+	return where, map[string]interface{}{
+		"id":    params.ID,
+		"phone": params.Phone,
+		"name":  "%" + params.Name + "%",
+	}
+}
+
+// FindAll find all users
+func (s *Storage) FindAll(ctx context.Context, params *user.FindAllUsersParams) ([]*user.Users, *types.Error) {
+
+	users := []*user.Users{}
+	where, arg := filter(params)
+
+	if params.After != nil {
+		after, err := data.DefaultSort.After(sortColumns, params.After, arg)
+		if err != nil {
+			return nil, &types.Error{
+				Path:    ".UserStorage->FindAll()",
+				Message: err.Error(),
+				Error:   err,
+				Type:    "validation-error",
+			}
+		}
+		where += ` AND ` + after
 	}
-	if params.Page != 0 && params.Limit != 0 {
-		where = fmt.Sprintf(`%s ORDER BY "createdAt" DESC LIMIT :limit OFFSET :offset`, where)
-	} else {
-		where = fmt.Sprintf(`%s ORDER BY "createdAt" DESC`, where)
+	where = fmt.Sprintf(`%s %s`, where, data.DefaultSort.Order(sortColumns))
+	if params.Limit != 0 {
+		where += ` LIMIT :limit`
+		arg["limit"] = params.Limit
 	}
 
-	err := s.Storage.Where(ctx, &users, where, map[string]interface{}{
-		"id":     params.ID,
-		"limit":  params.Limit,
-		"phone":  params.Phone,
-		"name":   "%" + params.Name + "%",
-		"offset": ((params.Page - 1) * params.Limit),
-		"token":  params.Token,
-	})
+	err := s.Storage.Where(ctx, &users, where, arg)
 	if err != nil {
 		return nil, &types.Error{
 			Path:    ".UserStorage->FindAll()",
@@ -58,6 +76,23 @@ func (s *Storage) FindAll(ctx context.Context, params *user.FindAllUsersParams)
 	return users, nil
 }
 
+// Count count the users matching the params, ignoring the pagination
+func (s *Storage) Count(ctx context.Context, params *user.FindAllUsersParams) (int, *types.Error) {
+	where, arg := filter(params)
+
+	count, err := s.Storage.Count(ctx, where, arg)
+	if err != nil {
+		return 0, &types.Error{
+			Path:    ".UserStorage->Count()",
+			Message: err.Error(),
+			Error:   err,
+			Type:    "pq-error",
+		}
+	}
+
+	return count, nil
+}
+
 // FindByID find user by its id
 func (s *Storage) FindByID(ctx context.Context, userID int) (*user.Users, *types.Error) {
 	users, err := s.FindAll(ctx, &user.FindAllUsersParams{
@@ -102,28 +137,6 @@ func (s *Storage) FindByPhone(ctx context.Context, phone string) (*user.Users, *
 	return users[0], nil
 }
 
-// FindByToken find user by its token
-func (s *Storage) FindByToken(ctx context.Context, token string) (*user.Users, *types.Error) {
-	users, err := s.FindAll(ctx, &user.FindAllUsersParams{
-		Token: token,
-	})
-	if err != nil {
-		err.Path = ".UserStorage->FindByToken()" + err.Path
-		return nil, err
-	}
-
-	if len(users) < 1 || (users[0].Token != nil && *users[0].Token != token) {
-		return nil, &types.Error{
-			Path:    ".UserStorage->FindByToken()",
-			Message: data.ErrNotFound.Error(),
-			Error:   data.ErrNotFound,
-			Type:    "pq-error",
-		}
-	}
-
-	return users[0], nil
-}
-
 // Insert insert user
 func (s *Storage) Insert(ctx context.Context, user *user.Users) (*user.Users, *types.Error) {
 	err := s.Storage.Insert(ctx, user)
@@ -155,8 +168,8 @@ func (s *Storage) Update(ctx context.Context, user *user.Users) (*user.Users, *t
 }
 
 // Delete delete a user
-func (s *Storage) Delete(ctx context.Context, userID int) *types.Error {
-	err := s.Storage.Delete(ctx, userID)
+func (s *Storage) Delete(ctx context.Context, userID int, deletedBy string) *types.Error {
+	err := s.Storage.Delete(ctx, userID, deletedBy)
 	if err != nil {
 		return &types.Error{
 			Path:    ".UserStorage->Delete()",
@@ -0,0 +1,365 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/riskiramdan/evos/internal/data"
+	"github.com/riskiramdan/evos/internal/logging"
+	"github.com/riskiramdan/evos/internal/metrics"
+	"github.com/riskiramdan/evos/internal/session"
+	"github.com/riskiramdan/evos/internal/types"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func newRefreshToken() (token string, hash string, err error) {
+	b := make([]byte, 32)
+	_, err = rand.Read(b)
+	if err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, hashRefreshToken(token), nil
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// Login login, it starts a new session for the device.
+// An unknown phone and a wrong password fail alike with ErrInvalidCredentials.
+func (s *Service) Login(ctx context.Context, params *LoginParams) (*LoginResponse, *types.Error) {
+	ctx, span := tracer.Start(ctx, "UserService.Login")
+	defer span.End()
+
+	invalid := &types.Error{
+		Path:    ".UserService->Login()",
+		Message: ErrInvalidCredentials.Error(),
+		Error:   ErrInvalidCredentials,
+		Type:    "validation-error",
+	}
+
+	users, err := s.userStorage.FindAll(ctx, &FindAllUsersParams{
+		Phone: params.Phone,
+	})
+	if err != nil {
+		err.Path = ".UserService->Login()" + err.Path
+		return nil, err
+	}
+	if len(users) < 1 {
+		verifyDummyPassword(params.Password)
+		metrics.Logins.WithLabelValues(metrics.LoginFailure).Inc()
+		return nil, invalid
+	}
+
+	user := users[0]
+	match, rehash := verifyPassword(user.Password, params.Password)
+	if !match {
+		metrics.Logins.WithLabelValues(metrics.LoginFailure).Inc()
+		return nil, invalid
+	}
+	if rehash {
+		hashed, errHash := hashPassword(params.Password)
+		if errHash != nil {
+			return nil, &types.Error{
+				Path:    ".UserService->Login()",
+				Message: errHash.Error(),
+				Error:   errHash,
+				Type:    "golang-error",
+			}
+		}
+		now := time.Now()
+		user.Password = hashed
+		user.UpdatedAt = &now
+
+		user, err = s.userStorage.Update(ctx, user)
+		if err != nil {
+			err.Path = ".UserService->Login()" + err.Path
+			return nil, err
+		}
+	}
+
+	refreshToken, refreshTokenHash, errToken := newRefreshToken()
+	if errToken != nil {
+		return nil, &types.Error{
+			Path:    ".UserService->Login()",
+			Message: errToken.Error(),
+			Error:   errToken,
+			Type:    "golang-error",
+		}
+	}
+
+	now := time.Now()
+	sess, err := s.sessionStorage.Insert(ctx, &session.Sessions{
+		UserID:           user.ID,
+		RefreshTokenHash: refreshTokenHash,
+		UserAgent:        optionalString(params.UserAgent),
+		IPAddress:        optionalString(params.IPAddress),
+		ExpiredAt:        now.Add(s.refreshTokenTTL),
+		LastUsedAt:       &now,
+		CreatedAt:        now,
+		UpdatedAt:        &now,
+	})
+	if err != nil {
+		err.Path = ".UserService->Login()" + err.Path
+		return nil, err
+	}
+
+	resp, err := s.issueTokens(user, sess, refreshToken)
+	if err != nil {
+		err.Path = ".UserService->Login()" + err.Path
+		return nil, err
+	}
+	data.AfterCommit(ctx, metrics.Logins.WithLabelValues(metrics.LoginSuccess).Inc)
+
+	return resp, nil
+}
+
+// Refresh rotates the refresh token of a session and issues a new access token.
+// Presenting an already rotated refresh token revokes the session, as it means
+// the token has leaked. It should not run inside a transaction, so the
+// revocation is kept even though an error is returned.
+func (s *Service) Refresh(ctx context.Context, params *RefreshParams) (*LoginResponse, *types.Error) {
+	ctx, span := tracer.Start(ctx, "UserService.Refresh")
+	defer span.End()
+
+	invalid := &types.Error{
+		Path:    ".UserService->Refresh()",
+		Message: ErrInvalidRefresh.Error(),
+		Error:   ErrInvalidRefresh,
+		Type:    "validation-error",
+	}
+
+	hash := hashRefreshToken(params.RefreshToken)
+	sess, err := s.sessionStorage.FindByRefreshTokenHash(ctx, hash)
+	if err != nil {
+		if !errors.Is(err.Error, data.ErrNotFound) {
+			err.Path = ".UserService->Refresh()" + err.Path
+			return nil, err
+		}
+
+		reused, errReused := s.sessionStorage.FindByPreviousRefreshTokenHash(ctx, hash)
+		if errReused == nil {
+			logging.FromContext(ctx).Warn().
+				Int("session_id", reused.ID).
+				Int("session_user_id", reused.UserID).
+				Msg("rotated refresh token presented, revoking the session")
+			errReused = s.revoke(ctx, reused)
+		}
+		if errReused != nil && !errors.Is(errReused.Error, data.ErrNotFound) {
+			errReused.Path = ".UserService->Refresh()" + errReused.Path
+			return nil, errReused
+		}
+		return nil, invalid
+	}
+
+	now := time.Now()
+	if !sess.Active(now) {
+		return nil, invalid
+	}
+
+	user, err := s.userStorage.FindByID(ctx, sess.UserID)
+	if err != nil {
+		if errors.Is(err.Error, data.ErrNotFound) {
+			return nil, invalid
+		}
+		err.Path = ".UserService->Refresh()" + err.Path
+		return nil, err
+	}
+
+	refreshToken, refreshTokenHash, errToken := newRefreshToken()
+	if errToken != nil {
+		return nil, &types.Error{
+			Path:    ".UserService->Refresh()",
+			Message: errToken.Error(),
+			Error:   errToken,
+			Type:    "golang-error",
+		}
+	}
+
+	previousHash := sess.RefreshTokenHash
+	sess.PreviousRefreshTokenHash = &previousHash
+	sess.RefreshTokenHash = refreshTokenHash
+	sess.ExpiredAt = now.Add(s.refreshTokenTTL)
+	sess.LastUsedAt = &now
+	sess.UpdatedAt = &now
+	if params.UserAgent != "" {
+		sess.UserAgent = optionalString(params.UserAgent)
+	}
+	if params.IPAddress != "" {
+		sess.IPAddress = optionalString(params.IPAddress)
+	}
+
+	sess, err = s.sessionStorage.Update(ctx, sess)
+	if err != nil {
+		err.Path = ".UserService->Refresh()" + err.Path
+		return nil, err
+	}
+
+	resp, err := s.issueTokens(user, sess, refreshToken)
+	if err != nil {
+		err.Path = ".UserService->Refresh()" + err.Path
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// Logout revokes a single session
+func (s *Service) Logout(ctx context.Context, sessionID int) *types.Error {
+	ctx, span := tracer.Start(ctx, "UserService.Logout")
+	defer span.End()
+
+	sess, err := s.sessionStorage.FindByID(ctx, sessionID)
+	if err != nil {
+		err.Path = ".UserService->Logout()" + err.Path
+		return err
+	}
+
+	err = s.revoke(ctx, sess)
+	if err != nil {
+		err.Path = ".UserService->Logout()" + err.Path
+		return err
+	}
+
+	return nil
+}
+
+// LogoutAll revokes every active session of the user
+func (s *Service) LogoutAll(ctx context.Context, userID int) *types.Error {
+	ctx, span := tracer.Start(ctx, "UserService.LogoutAll")
+	defer span.End()
+
+	sessions, err := s.sessionStorage.FindAll(ctx, &session.FindAllSessionsParams{
+		UserID:     userID,
+		OnlyActive: true,
+	})
+	if err != nil {
+		err.Path = ".UserService->LogoutAll()" + err.Path
+		return err
+	}
+
+	for _, sess := range sessions {
+		err = s.revoke(ctx, sess)
+		if err != nil {
+			err.Path = ".UserService->LogoutAll()" + err.Path
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Authenticate validates the access token against the session store,
+// and returns the user & the session it belongs to
+func (s *Service) Authenticate(ctx context.Context, accessToken string) (*Users, *session.Sessions, *types.Error) {
+	ctx, span := tracer.Start(ctx, "UserService.Authenticate")
+	defer span.End()
+
+	invalid := &types.Error{
+		Path:    ".UserService->Authenticate()",
+		Message: ErrInvalidToken.Error(),
+		Error:   ErrInvalidToken,
+		Type:    "Invalid Token",
+	}
+
+	token, errParse := s.keyring.Parse(accessToken)
+	if errParse != nil || !token.Valid {
+		return nil, nil, invalid
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, nil, invalid
+	}
+	sid, ok := claims["sid"].(float64)
+	if !ok {
+		return nil, nil, invalid
+	}
+
+	sess, err := s.sessionStorage.FindByID(ctx, int(sid))
+	if err != nil {
+		if errors.Is(err.Error, data.ErrNotFound) {
+			return nil, nil, invalid
+		}
+		err.Path = ".UserService->Authenticate()" + err.Path
+		return nil, nil, err
+	}
+	if !sess.Active(time.Now()) {
+		return nil, nil, invalid
+	}
+
+	user, err := s.userStorage.FindByID(ctx, sess.UserID)
+	if err != nil {
+		if errors.Is(err.Error, data.ErrNotFound) {
+			return nil, nil, invalid
+		}
+		err.Path = ".UserService->Authenticate()" + err.Path
+		return nil, nil, err
+	}
+
+	return user, sess, nil
+}
+
+func (s *Service) revoke(ctx context.Context, sess *session.Sessions) *types.Error {
+	if sess.RevokedAt != nil {
+		return nil
+	}
+
+	now := time.Now()
+	sess.RevokedAt = &now
+	sess.UpdatedAt = &now
+
+	_, err := s.sessionStorage.Update(ctx, sess)
+	if err != nil {
+		err.Path = ".UserService->revoke()" + err.Path
+		return err
+	}
+
+	return nil
+}
+
+func (s *Service) issueTokens(user *Users, sess *session.Sessions, refreshToken string) (*LoginResponse, *types.Error) {
+	now := time.Now()
+	tokenExpiredAt := now.Add(s.accessTokenTTL)
+
+	tClaims := jwt.MapClaims{}
+	tClaims["uid"] = user.ID
+	tClaims["sid"] = sess.ID
+	tClaims["name"] = user.Name
+	tClaims["phone"] = user.Phone
+	tClaims["roleId"] = user.RoleID
+	tClaims["timestamp"] = tokenExpiredAt
+	tClaims["iat"] = now.Unix()
+	tClaims["exp"] = tokenExpiredAt.Unix()
+	t, errToken := s.keyring.Sign(tClaims)
+	if errToken != nil {
+		return nil, &types.Error{
+			Path:    ".UserService->issueTokens()",
+			Message: errToken.Error(),
+			Error:   errToken,
+			Type:    "golang-error",
+		}
+	}
+
+	return &LoginResponse{
+		SessionID:             t,
+		ExpiredAt:             tokenExpiredAt,
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiredAt: sess.ExpiredAt,
+		Claims:                tClaims,
+	}, nil
+}
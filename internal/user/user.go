@@ -3,62 +3,112 @@ package user
 import (
 	"context"
 	"errors"
+	"strconv"
 	"time"
 
-	"github.com/riskiramdan/evos/internal/constants"
+	"github.com/riskiramdan/evos/internal/appcontext"
+	"github.com/riskiramdan/evos/internal/audit"
+	"github.com/riskiramdan/evos/internal/data"
+	"github.com/riskiramdan/evos/internal/keyring"
+	"github.com/riskiramdan/evos/internal/session"
 	"github.com/riskiramdan/evos/internal/types"
 
-	"github.com/dgrijalva/jwt-go"
+	"go.opentelemetry.io/otel"
 )
 
 // Errors
 var (
-	ErrWrongPassword      = errors.New("wrong password")
-	ErrWrongPhone         = errors.New("wrong phone")
-	ErrPhoneAlreadyExists = errors.New("Phone Already Exists")
+	ErrWrongPassword      = types.NewDomainError(types.KindValidation, "WrongPassword", "wrong password")
+	ErrInvalidCredentials = types.NewDomainError(types.KindUnauthorized, "InvalidCredentials", "Invalid phone or password")
+	ErrInvalidToken       = types.NewDomainError(types.KindUnauthorized, "InvalidToken", "Invalid token")
+	ErrInvalidRefresh     = types.NewDomainError(types.KindUnauthorized, "InvalidRefreshToken", "Invalid refresh token")
+	ErrPhoneAlreadyExists = types.NewDomainError(types.KindConflict, "PhoneAlreadyExists", "Phone Already Exists")
+	ErrInvalidPassword    = types.NewDomainError(types.KindValidation, "InvalidPassword", "Password must be at least 8 characters")
+	ErrInvalidRole        = types.NewDomainError(types.KindValidation, "InvalidRole", "Invalid role")
+	ErrRoleChangeDenied   = types.NewDomainError(types.KindForbidden, "RoleChangeDenied", "Only admins can change roles")
 )
 
+var tracer = otel.Tracer("github.com/riskiramdan/evos/internal/user")
+
+// Roles seeded in the roles table
+const (
+	RoleAdmin    = 1
+	RoleOperator = 2
+	RoleGuest    = 3
+)
+
+func validRole(roleID int) bool {
+	return roleID == RoleAdmin || roleID == RoleOperator || roleID == RoleGuest
+}
+
 // Users user
 type Users struct {
-	ID             int        `json:"id" db:"id"`
-	RoleID         int        `json:"roleId" db:"roleId"`
-	Name           string     `json:"name" db:"name"`
-	Phone          string     `json:"phone" db:"phone"`
-	Password       string     `json:"password" db:"password"`
-	Token          *string    `json:"token" db:"token"`
-	TokenExpiredAt *time.Time `json:"tokenExpiredAt" db:"tokenExpiredAt"`
-	CreatedAt      time.Time  `json:"createdAt" db:"createdAt"`
-	UpdatedAt      *time.Time `json:"updatedAt" db:"updatedAt"`
+	ID        int        `json:"id" db:"id"`
+	RoleID    int        `json:"roleId" db:"roleId"`
+	Name      string     `json:"name" db:"name"`
+	Phone     string     `json:"phone" db:"phone"`
+	Password  string     `json:"-" db:"password"`
+	CreatedAt time.Time  `json:"createdAt" db:"createdAt"`
+	UpdatedAt *time.Time `json:"updatedAt" db:"updatedAt"`
+	Version   int        `json:"version" db:"version"`
 }
 
 //FindAllUsersParams params for find all
 type FindAllUsersParams struct {
-	ID    int    `json:"id"`
-	Page  int    `json:"page"`
-	Limit int    `json:"limit"`
-	Phone string `json:"phone"`
-	Name  string `json:"name"`
-	Token string `json:"token"`
+	ID        int          `json:"id"`
+	Limit     int          `json:"limit"`
+	After     *data.Cursor `json:"after"`
+	WithTotal bool         `json:"withTotal"`
+	Phone     string       `json:"phone"`
+	Name      string       `json:"name"`
 }
 
 // TransactionParams params for transaction
 type TransactionParams struct {
-	RoleID   int    `json:"roleId"`
-	Name     string `json:"name"`
-	Phone    string `json:"phone"`
-	Password string
+	RoleID   int    `json:"roleId" validate:"omitempty,min=1,max=3"`
+	Name     string `json:"name" validate:"required,min=2,max=80"`
+	Phone    string `json:"phone" validate:"required,phone"`
+	Password string `json:"password" validate:"required,min=8,max=72"`
+}
+
+// UpdateParams params for updating a user, only the given fields are changed
+type UpdateParams struct {
+	RoleID *int    `json:"roleId,omitempty" validate:"omitempty,min=1,max=3"`
+	Name   *string `json:"name,omitempty" validate:"omitempty,min=2,max=80"`
+	Phone  *string `json:"phone,omitempty" validate:"omitempty,phone"`
+	// Version is the version the update is based on, it fails with data.ErrConflict on any other
+	Version *int `json:"version,omitempty" validate:"omitempty,min=1"`
+}
+
+// ChangePasswordParams params for changing the password of a user
+type ChangePasswordParams struct {
+	CurrentPassword string `json:"currentPassword" validate:"required"`
+	NewPassword     string `json:"newPassword" validate:"required,min=8,max=72"`
 }
 
 // LoginParams represent the http request data for login user
 type LoginParams struct {
-	Phone    string `json:"phone"`
-	Password string `json:"password"`
+	Phone     string `json:"phone" validate:"required,max=80"`
+	Password  string `json:"password" validate:"required,max=72"`
+	UserAgent string `json:"-"`
+	IPAddress string `json:"-"`
 }
 
-// LoginResponse represents the response of login function
+// RefreshParams represent the http request data for refreshing the session tokens
+type RefreshParams struct {
+	RefreshToken string `json:"refreshToken" validate:"required"`
+	UserAgent    string `json:"-"`
+	IPAddress    string `json:"-"`
+}
+
+// LoginResponse represents the response of login & refresh functions.
+// SessionID holds the short-lived access token, it keeps its historical name.
 type LoginResponse struct {
-	SessionID string      `json:"sessionId"`
-	Claims    interface{} `json:"claims"`
+	SessionID             string      `json:"sessionId"`
+	ExpiredAt             time.Time   `json:"expiredAt"`
+	RefreshToken          string      `json:"refreshToken"`
+	RefreshTokenExpiredAt time.Time   `json:"refreshTokenExpiredAt"`
+	Claims                interface{} `json:"claims"`
 }
 
 // VerifyParams  ..
@@ -69,48 +119,81 @@ type VerifyParams struct {
 // Storage represents the user storage interface
 type Storage interface {
 	FindAll(ctx context.Context, params *FindAllUsersParams) ([]*Users, *types.Error)
+	Count(ctx context.Context, params *FindAllUsersParams) (int, *types.Error)
 	FindByID(ctx context.Context, userID int) (*Users, *types.Error)
 	FindByPhone(ctx context.Context, phone string) (*Users, *types.Error)
-	FindByToken(ctx context.Context, token string) (*Users, *types.Error)
 	Insert(ctx context.Context, user *Users) (*Users, *types.Error)
 	Update(ctx context.Context, user *Users) (*Users, *types.Error)
-	Delete(ctx context.Context, userID int) *types.Error
+	Delete(ctx context.Context, userID int, deletedBy string) *types.Error
 }
 
 // ServiceInterface represents the user service interface
 type ServiceInterface interface {
-	ListUsers(ctx context.Context, params *FindAllUsersParams) ([]*Users, int, *types.Error)
+	ListUsers(ctx context.Context, params *FindAllUsersParams) ([]*Users, *data.Page, *types.Error)
 	GetUser(ctx context.Context, userID int) (*Users, *types.Error)
 	CreateUser(ctx context.Context, params *TransactionParams) (*Users, *types.Error)
-	Login(ctx context.Context, phone string, password string) (*LoginResponse, *types.Error)
-	GetByToken(ctx context.Context, token string) (*Users, *types.Error)
+	UpdateUser(ctx context.Context, userID int, params *UpdateParams) (*Users, *types.Error)
+	DeleteUser(ctx context.Context, userID int) *types.Error
+	ChangePassword(ctx context.Context, userID int, params *ChangePasswordParams) *types.Error
+	Login(ctx context.Context, params *LoginParams) (*LoginResponse, *types.Error)
+	Refresh(ctx context.Context, params *RefreshParams) (*LoginResponse, *types.Error)
+	Logout(ctx context.Context, sessionID int) *types.Error
+	LogoutAll(ctx context.Context, userID int) *types.Error
+	Authenticate(ctx context.Context, accessToken string) (*Users, *session.Sessions, *types.Error)
 }
 
 // Service is the domain logic implementation of user Service interface
 type Service struct {
-	userStorage Storage
+	userStorage    Storage
+	sessionStorage session.Storage
+	keyring        *keyring.Keyring
+	auditService   audit.ServiceInterface
+	// accessTokenTTL & refreshTokenTTL are the lifetimes of the issued tokens
+	accessTokenTTL  time.Duration
+	refreshTokenTTL time.Duration
 }
 
-// ListUsers is listing users
-func (s *Service) ListUsers(ctx context.Context, params *FindAllUsersParams) ([]*Users, int, *types.Error) {
+// ListUsers is listing users, a page after the params cursor.
+// The total is only counted when asked for.
+func (s *Service) ListUsers(ctx context.Context, params *FindAllUsersParams) ([]*Users, *data.Page, *types.Error) {
+	ctx, span := tracer.Start(ctx, "UserService.ListUsers")
+	defer span.End()
+
+	limit := params.Limit
+	if limit > 0 {
+		// one more row tells whether there is a next page
+		params.Limit = limit + 1
+	}
 	users, err := s.userStorage.FindAll(ctx, params)
+	params.Limit = limit
 	if err != nil {
 		err.Path = ".UserService->ListUsers()" + err.Path
-		return nil, 0, err
+		return nil, nil, err
 	}
-	params.Page = 0
-	params.Limit = 0
-	allUsers, err := s.userStorage.FindAll(ctx, params)
-	if err != nil {
-		err.Path = ".UserService->ListUsers()" + err.Path
-		return nil, 0, err
+
+	page := &data.Page{}
+	if limit > 0 && len(users) > limit {
+		users = users[:limit]
+		last := users[limit-1]
+		page.NextCursor = data.NewCursor(data.DefaultSort, []interface{}{last.CreatedAt}, last.ID).Encode()
+	}
+	if params.WithTotal {
+		count, err := s.userStorage.Count(ctx, params)
+		if err != nil {
+			err.Path = ".UserService->ListUsers()" + err.Path
+			return nil, nil, err
+		}
+		page.Total = &count
 	}
 
-	return users, len(allUsers), nil
+	return users, page, nil
 }
 
 // GetUser is get user
 func (s *Service) GetUser(ctx context.Context, userID int) (*Users, *types.Error) {
+	ctx, span := tracer.Start(ctx, "UserService.GetUser")
+	defer span.End()
+
 	user, err := s.userStorage.FindByID(ctx, userID)
 	if err != nil {
 		err.Path = ".UserService->GetUser()" + err.Path
@@ -122,6 +205,9 @@ func (s *Service) GetUser(ctx context.Context, userID int) (*Users, *types.Error
 
 // CreateUser create user
 func (s *Service) CreateUser(ctx context.Context, params *TransactionParams) (*Users, *types.Error) {
+	ctx, span := tracer.Start(ctx, "UserService.CreateUser")
+	defer span.End()
+
 	users, _, errType := s.ListUsers(ctx, &FindAllUsersParams{
 		Phone: params.Phone,
 	})
@@ -138,17 +224,30 @@ func (s *Service) CreateUser(ctx context.Context, params *TransactionParams) (*U
 		}
 	}
 
+	if !validRole(params.RoleID) {
+		return nil, &types.Error{
+			Path:    ".UserService->CreateUser()",
+			Message: ErrInvalidRole.Error(),
+			Error:   ErrInvalidRole,
+			Type:    "validation-error",
+		}
+	}
+
+	hashed, errType := newPasswordHash(params.Password)
+	if errType != nil {
+		errType.Path = ".UserService->CreateUser()" + errType.Path
+		return nil, errType
+	}
+
 	now := time.Now()
 
 	user := &Users{
-		Name:           params.Name,
-		RoleID:         params.RoleID,
-		Phone:          params.Phone,
-		Password:       params.Password,
-		Token:          nil,
-		TokenExpiredAt: nil,
-		CreatedAt:      now,
-		UpdatedAt:      &now,
+		Name:      params.Name,
+		RoleID:    params.RoleID,
+		Phone:     params.Phone,
+		Password:  hashed,
+		CreatedAt: now,
+		UpdatedAt: &now,
 	}
 
 	user, errType = s.userStorage.Insert(ctx, user)
@@ -156,90 +255,232 @@ func (s *Service) CreateUser(ctx context.Context, params *TransactionParams) (*U
 		errType.Path = ".UserService->CreateUser()" + errType.Path
 		return nil, errType
 	}
-	user.Password = params.Password
+
+	errType = s.auditService.Record(ctx, &audit.Change{
+		Entity:   audit.EntityUser,
+		EntityID: user.ID,
+		Action:   audit.ActionCreate,
+		After:    user,
+	})
+	if errType != nil {
+		errType.Path = ".UserService->CreateUser()" + errType.Path
+		return nil, errType
+	}
 
 	return user, nil
 }
 
-// Login login
-func (s *Service) Login(ctx context.Context, phone string, password string) (*LoginResponse, *types.Error) {
-	users, err := s.userStorage.FindAll(ctx, &FindAllUsersParams{
-		Phone: phone,
-	})
+// UpdateUser updates the given fields of a user.
+// Changing the role is only allowed to admins.
+func (s *Service) UpdateUser(ctx context.Context, userID int, params *UpdateParams) (*Users, *types.Error) {
+	ctx, span := tracer.Start(ctx, "UserService.UpdateUser")
+	defer span.End()
+
+	user, err := s.userStorage.FindByID(ctx, userID)
 	if err != nil {
-		err.Path = ".UserService->Login()" + err.Path
+		err.Path = ".UserService->UpdateUser()" + err.Path
 		return nil, err
 	}
-	if len(users) < 1 {
+	if params.Version != nil && *params.Version != user.Version {
 		return nil, &types.Error{
-			Path:    ".UserService->Login()",
-			Message: ErrWrongPhone.Error(),
-			Error:   ErrWrongPhone,
+			Path:    ".UserService->UpdateUser()",
+			Message: data.ErrConflict.Error(),
+			Error:   data.ErrConflict,
 			Type:    "validation-error",
 		}
 	}
+	before := *user
+
+	if params.RoleID != nil && *params.RoleID != user.RoleID {
+		if appcontext.RoleID(ctx) != RoleAdmin {
+			return nil, &types.Error{
+				Path:    ".UserService->UpdateUser()",
+				Message: ErrRoleChangeDenied.Error(),
+				Error:   ErrRoleChangeDenied,
+				Type:    "validation-error",
+			}
+		}
+		if !validRole(*params.RoleID) {
+			return nil, &types.Error{
+				Path:    ".UserService->UpdateUser()",
+				Message: ErrInvalidRole.Error(),
+				Error:   ErrInvalidRole,
+				Type:    "validation-error",
+			}
+		}
+		user.RoleID = *params.RoleID
+	}
+	if params.Phone != nil && *params.Phone != user.Phone {
+		_, err = s.userStorage.FindByPhone(ctx, *params.Phone)
+		if err == nil {
+			return nil, &types.Error{
+				Path:    ".UserService->UpdateUser()",
+				Message: ErrPhoneAlreadyExists.Error(),
+				Error:   ErrPhoneAlreadyExists,
+				Type:    "validation-error",
+			}
+		}
+		if !errors.Is(err.Error, data.ErrNotFound) {
+			err.Path = ".UserService->UpdateUser()" + err.Path
+			return nil, err
+		}
+		user.Phone = *params.Phone
+	}
+	if params.Name != nil {
+		user.Name = *params.Name
+	}
 
-	user := users[0]
-	if user.Password != password {
-		return nil, &types.Error{
+	now := time.Now()
+	user.UpdatedAt = &now
+
+	user, err = s.userStorage.Update(ctx, user)
+	if err != nil {
+		err.Path = ".UserService->UpdateUser()" + err.Path
+		return nil, err
+	}
+
+	err = s.auditService.Record(ctx, &audit.Change{
+		Entity:   audit.EntityUser,
+		EntityID: user.ID,
+		Action:   audit.ActionUpdate,
+		Before:   &before,
+		After:    user,
+	})
+	if err != nil {
+		err.Path = ".UserService->UpdateUser()" + err.Path
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// DeleteUser soft deletes a user and revokes all of its sessions
+func (s *Service) DeleteUser(ctx context.Context, userID int) *types.Error {
+	ctx, span := tracer.Start(ctx, "UserService.DeleteUser")
+	defer span.End()
+
+	user, err := s.userStorage.FindByID(ctx, userID)
+	if err != nil {
+		err.Path = ".UserService->DeleteUser()" + err.Path
+		return err
+	}
+
+	err = s.userStorage.Delete(ctx, userID, appcontext.Actor(ctx))
+	if err != nil {
+		err.Path = ".UserService->DeleteUser()" + err.Path
+		return err
+	}
+
+	err = s.auditService.Record(ctx, &audit.Change{
+		Entity:   audit.EntityUser,
+		EntityID: userID,
+		Action:   audit.ActionDelete,
+		Before:   user,
+	})
+	if err != nil {
+		err.Path = ".UserService->DeleteUser()" + err.Path
+		return err
+	}
+
+	err = s.LogoutAll(ctx, userID)
+	if err != nil {
+		err.Path = ".UserService->DeleteUser()" + err.Path
+		return err
+	}
+
+	return nil
+}
+
+// ChangePassword changes the password of a user after checking the current one.
+// Every other session of the user is revoked.
+func (s *Service) ChangePassword(ctx context.Context, userID int, params *ChangePasswordParams) *types.Error {
+	ctx, span := tracer.Start(ctx, "UserService.ChangePassword")
+	defer span.End()
+
+	user, err := s.userStorage.FindByID(ctx, userID)
+	if err != nil {
+		err.Path = ".UserService->ChangePassword()" + err.Path
+		return err
+	}
+
+	match, _ := verifyPassword(user.Password, params.CurrentPassword)
+	if !match {
+		return &types.Error{
 			Path:    ".UserService->ChangePassword()",
 			Message: ErrWrongPassword.Error(),
 			Error:   ErrWrongPassword,
-			Type:    "golang-error",
+			Type:    "validation-error",
 		}
 	}
 
-	now := time.Now()
-	tokenExpiredAt := time.Now().Add(constants.ExpireTime)
-
-	Token := jwt.New(constants.SigningMethod)
-	tClaims := Token.Claims.(jwt.MapClaims)
-	tClaims["uid"] = user.ID
-	tClaims["name"] = user.Name
-	tClaims["phone"] = user.Phone
-	tClaims["roleId"] = user.RoleID
-	tClaims["timestamp"] = tokenExpiredAt
-	tClaims["iat"] = time.Now().Unix()
-	tClaims["exp"] = time.Now().Add(constants.ExpireTime).Unix()
-	t, errToken := Token.SignedString(constants.SignatureKey)
+	hashed, err := newPasswordHash(params.NewPassword)
 	if err != nil {
-		err.Path = ".UserService->Login()" + err.Path
-		err.Message = errToken.Error()
-		return nil, err
+		err.Path = ".UserService->ChangePassword()" + err.Path
+		return err
 	}
 
-	user.Token = &t
-	user.TokenExpiredAt = &tokenExpiredAt
+	before := *user
+	now := time.Now()
+	user.Password = hashed
 	user.UpdatedAt = &now
 
-	user, err = s.userStorage.Update(ctx, user)
+	_, err = s.userStorage.Update(ctx, user)
 	if err != nil {
-		err.Path = ".UserService->CreateUser()" + err.Path
-		return nil, err
+		err.Path = ".UserService->ChangePassword()" + err.Path
+		return err
 	}
 
-	return &LoginResponse{
-		SessionID: t,
-		Claims:    tClaims,
-	}, nil
-}
+	// the password is never serialized, the entry only shows when it changed
+	err = s.auditService.Record(ctx, &audit.Change{
+		Entity:   audit.EntityUser,
+		EntityID: userID,
+		Action:   audit.ActionUpdate,
+		Before:   &before,
+		After:    user,
+	})
+	if err != nil {
+		err.Path = ".UserService->ChangePassword()" + err.Path
+		return err
+	}
 
-// GetByToken get user by its token
-func (s *Service) GetByToken(ctx context.Context, token string) (*Users, *types.Error) {
-	user, err := s.userStorage.FindByToken(ctx, token)
+	sessions, err := s.sessionStorage.FindAll(ctx, &session.FindAllSessionsParams{
+		UserID:     userID,
+		OnlyActive: true,
+	})
 	if err != nil {
-		err.Path = ".UserService->GetByToken()" + err.Path
-		return nil, err
+		err.Path = ".UserService->ChangePassword()" + err.Path
+		return err
+	}
+	current := appcontext.SessionID(ctx)
+	for _, sess := range sessions {
+		if current != nil && strconv.Itoa(sess.ID) == *current {
+			continue
+		}
+		err = s.revoke(ctx, sess)
+		if err != nil {
+			err.Path = ".UserService->ChangePassword()" + err.Path
+			return err
+		}
 	}
 
-	return user, nil
+	return nil
 }
 
 // NewService creates a new user AppService
 func NewService(
 	userStorage Storage,
+	sessionStorage session.Storage,
+	keyring *keyring.Keyring,
+	accessTokenTTL time.Duration,
+	refreshTokenTTL time.Duration,
+	auditService audit.ServiceInterface,
 ) *Service {
 	return &Service{
-		userStorage: userStorage,
+		userStorage:     userStorage,
+		sessionStorage:  sessionStorage,
+		keyring:         keyring,
+		auditService:    auditService,
+		accessTokenTTL:  accessTokenTTL,
+		refreshTokenTTL: refreshTokenTTL,
 	}
 }
@@ -5,16 +5,13 @@ import (
 	"fmt"
 
 	"github.com/riskiramdan/evos/config"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
 // SeedUp seeding the database
-func SeedUp() error {
-	cfg, err := config.GetConfiguration()
-	if err != nil {
-		return fmt.Errorf("error when getting configuration: %s", err)
-	}
-
-	db, err := sql.Open("postgres", cfg.DBConnectionString)
+func SeedUp(cfg *config.Config) error {
+	db, err := sql.Open("postgres", cfg.DB.ConnectionString())
 	if err != nil {
 		return fmt.Errorf("error when open postgres connection: %s", err)
 	}
@@ -24,23 +21,68 @@ func SeedUp() error {
 	_, err = db.Exec(`
 	INSERT INTO public."roles"
 	(id, "name")
-	VALUES(1, 'Admin');
+	VALUES(1, 'Admin') ON CONFLICT DO NOTHING;
 	INSERT INTO public."roles"
 	(id, "name")
-	VALUES(2, 'Operator');
+	VALUES(2, 'Operator') ON CONFLICT DO NOTHING;
 	INSERT INTO public."roles"
 	(id, "name")
-	VALUES(3, 'Guest');
+	VALUES(3, 'Guest') ON CONFLICT DO NOTHING;
 	`)
 	if err != nil {
 		return err
 	}
 
 	//User
+	adminPassword, err := bcrypt.GenerateFromPassword([]byte("jLov"), 12)
+	if err != nil {
+		return err
+	}
 	_, err = db.Exec(`
 	INSERT INTO public.users
-	(id, "roleId", name, phone, "password", "token", "tokenExpiredAt")
-	VALUES(9999, 1, 'admin', '082101010101', 'jLov', NULL, NULL);
+	(id, "roleId", name, phone, "password")
+	VALUES(9999, 1, 'admin', '082101010101', $1) ON CONFLICT DO NOTHING;
+	`, string(adminPassword))
+	if err != nil {
+		return err
+	}
+
+	//Permissions
+	_, err = db.Exec(`
+	INSERT INTO public."permissions"
+	("name", description)
+	VALUES
+	('character:read', 'List & view characters'),
+	('character:write', 'Create & update characters'),
+	('character:delete', 'Delete characters'),
+	('character:restore', 'List & restore deleted characters'),
+	('character-type:read', 'List & view character types'),
+	('character-type:write', 'Create & update character types'),
+	('user:read', 'List & view users'),
+	('user:write', 'Manage users'),
+	('audit:read', 'View the audit trail')
+	ON CONFLICT DO NOTHING;
+	`)
+	if err != nil {
+		return err
+	}
+
+	//Role Permissions
+	_, err = db.Exec(`
+	INSERT INTO public."rolePermissions"
+	("roleId", "permissionId")
+	SELECT 1, p.id FROM public."permissions" p
+	ON CONFLICT DO NOTHING;
+	INSERT INTO public."rolePermissions"
+	("roleId", "permissionId")
+	SELECT 2, p.id FROM public."permissions" p
+	WHERE p."name" IN ('character:read', 'character:write', 'character:delete', 'character-type:read')
+	ON CONFLICT DO NOTHING;
+	INSERT INTO public."rolePermissions"
+	("roleId", "permissionId")
+	SELECT 3, p.id FROM public."permissions" p
+	WHERE p."name" IN ('character:read', 'character-type:read')
+	ON CONFLICT DO NOTHING;
 	`)
 	if err != nil {
 		return err
@@ -49,14 +91,21 @@ func SeedUp() error {
 	//Character Type
 	_, err = db.Exec(`
 	INSERT INTO public."charactersType"
-	(id, name, code)
-	VALUES(1, 'Wizard', 1);
+	(id, name, code, multiplier, "flatBonus", thresholds)
+	VALUES(1, 'Wizard', 1, 150, 0, '[]') ON CONFLICT DO NOTHING;
 	INSERT INTO public."charactersType"
-	(id, name, code)
-	VALUES(2, 'Elf', 2);
+	(id, name, code, multiplier, "flatBonus", thresholds)
+	VALUES(2, 'Elf', 2, 110, 2, '[]') ON CONFLICT DO NOTHING;
 	INSERT INTO public."charactersType"
-	(id, name, code)
-	VALUES(3, 'Hobbit', 3);
+	(id, name, code, multiplier, "flatBonus", thresholds)
+	VALUES(3, 'Hobbit', 3, 300, 0, '[{"powerBelow": 20, "multiplier": 200}]') ON CONFLICT DO NOTHING;
+	`)
+	if err != nil {
+		return err
+	}
+	// the ids are explicit, the sequence must be moved past them for the created character types
+	_, err = db.Exec(`
+	SELECT setval(pg_get_serial_sequence('public."charactersType"', 'id'), (SELECT MAX("id") FROM public."charactersType"));
 	`)
 	if err != nil {
 		return err
@@ -66,13 +115,13 @@ func SeedUp() error {
 	_, err = db.Exec(`
 	INSERT INTO public.characters
 	(id, "characterTypeID", name, power)
-	VALUES(9999, 1, 'Gandalf', 100);
+	VALUES(9999, 1, 'Gandalf', 100) ON CONFLICT DO NOTHING;
 	INSERT INTO public.characters
 	(id, "characterTypeID", name, power)
-	VALUES(9998, 2, 'Legolas', 60);
+	VALUES(9998, 2, 'Legolas', 60) ON CONFLICT DO NOTHING;
 	INSERT INTO public.characters
 	(id, "characterTypeID", name, power)
-	VALUES(9997, 3, 'Frodo', 10);	
+	VALUES(9997, 3, 'Frodo', 10) ON CONFLICT DO NOTHING;	
 	`)
 	if err != nil {
 		return err